@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestNormalizeAPIKeyScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "one", scopes: []string{scopeUpload}, want: []string{scopeUpload}},
+		{
+			name:   "sorted without duplicates",
+			scopes: []string{scopeVideosWrite, scopeUpload, scopeVideosWrite, scopeVideosRead},
+			want:   []string{scopeUpload, scopeVideosRead, scopeVideosWrite},
+		},
+		{name: "none", scopes: nil, wantErr: true},
+		{name: "unknown", scopes: []string{scopeVideosRead, "admin"}, wantErr: true},
+		{name: "wrong case", scopes: []string{"Upload"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested := slices.Clone(tt.scopes)
+			got, err := normalizeAPIKeyScopes(tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(tt.scopes, requested) {
+				t.Errorf("changed the caller's scopes to %q", tt.scopes)
+			}
+		})
+	}
+}
+
+func TestAPIKeyActive(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		apiKey database.APIKey
+		want   bool
+	}{
+		{name: "not found", apiKey: database.APIKey{}},
+		{name: "no expiry", apiKey: database.APIKey{ID: uuid.New()}, want: true},
+		{name: "expires later", apiKey: database.APIKey{ID: uuid.New(), ExpiresAt: &future}, want: true},
+		{name: "expired", apiKey: database.APIKey{ID: uuid.New(), ExpiresAt: &past}},
+		{name: "revoked", apiKey: database.APIKey{ID: uuid.New(), RevokedAt: &past}},
+	}
+	for _, tt := range tests {
+		if got := apiKeyActive(tt.apiKey); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := testUser(t, cfg, "keys@example.com")
+	_, accessToken := testLogin(t, cfg, user)
+
+	createKey := func(t *testing.T, scopes []string, expiresAt *time.Time) (database.APIKey, string) {
+		key, err := auth.MakeAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		apiKey, err := cfg.db.CreateAPIKey(database.CreateAPIKeyParams{Key: key, UserID: user.ID, Name: "test", Scopes: scopes, ExpiresAt: expiresAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return apiKey, key
+	}
+
+	tests := []struct {
+		name string
+		// header builds the request's Authorization header.
+		header     func(t *testing.T) string
+		wantStatus int
+	}{
+		{
+			name: "key with the scope",
+			header: func(t *testing.T) string {
+				_, key := createKey(t, []string{scopeVideosRead, scopeVideosWrite}, nil)
+				return "ApiKey " + key
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "key without the scope",
+			header: func(t *testing.T) string {
+				_, key := createKey(t, []string{scopeVideosRead}, nil)
+				return "ApiKey " + key
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "expired key",
+			header: func(t *testing.T) string {
+				_, key := createKey(t, []string{scopeVideosWrite}, ptr(time.Now().Add(-time.Minute)))
+				return "ApiKey " + key
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "revoked key",
+			header: func(t *testing.T) string {
+				apiKey, key := createKey(t, []string{scopeVideosWrite}, nil)
+				err := cfg.db.RevokeAPIKey(apiKey.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return "ApiKey " + key
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown key",
+			header:     func(t *testing.T) string { return "ApiKey tubely_unknown" },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			// Access tokens can do everything.
+			name:       "access token",
+			header:     func(t *testing.T) string { return "Bearer " + accessToken },
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/videos", nil)
+			req.Header.Set("Authorization", tt.header(t))
+			rec := httptest.NewRecorder()
+			userID, ok := cfg.authenticate(rec, req, scopeVideosWrite)
+			if ok != (tt.wantStatus == http.StatusOK) || ok && userID != user.ID {
+				t.Fatalf("got user %s and %v", userID, ok)
+			}
+			if !ok && rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
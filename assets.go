@@ -1,7 +1,12 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func (cfg apiConfig) ensureAssetsDir() error {
@@ -10,3 +15,23 @@ func (cfg apiConfig) ensureAssetsDir() error {
 	}
 	return nil
 }
+
+func randomAssetName() (string, error) {
+	bytes := make([]byte, 32)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(bytes), nil
+}
+
+// localAssetPath returns the file under assetsRoot that an /assets/ URL
+// serves.
+func (cfg *apiConfig) localAssetPath(assetURL string) (string, bool) {
+	prefix := fmt.Sprintf("http://localhost:%s/assets/", cfg.port)
+	if !strings.HasPrefix(assetURL, prefix) {
+		return "", false
+	}
+	name := filepath.Base(strings.TrimPrefix(assetURL, prefix))
+	return filepath.Join(cfg.assetsRoot, name), true
+}
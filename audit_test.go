@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{remoteAddr: "[2001:db8::1]:443", want: "2001:db8::1"},
+		{remoteAddr: "@", want: "@"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header.Set("X-Forwarded-For", "203.0.113.9")
+		if got := clientIP(r); got != tt.want {
+			t.Errorf("clientIP with RemoteAddr %q = %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
+
+func TestParseListAuditEventsParams(t *testing.T) {
+	cursor := database.AuditEventCursor{CreatedAt: "2024-03-01 12:00:00", ID: uuid.New()}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    database.ListAuditEventsParams
+		wantErr bool
+	}{
+		{name: "defaults", want: database.ListAuditEventsParams{Limit: defaultAuditEvents}},
+		{
+			name:  "everything",
+			query: "action=user.login&limit=200&cursor=" + cursor.Encode(),
+			want:  database.ListAuditEventsParams{Action: "user.login", Limit: 200, After: &cursor},
+		},
+		{name: "limit zero", query: "limit=0", wantErr: true},
+		{name: "limit too large", query: "limit=201", wantErr: true},
+		{name: "invalid cursor", query: "cursor=abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseListAuditEventsParams(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Action != tt.want.Action || got.Limit != tt.want.Limit ||
+				(got.After == nil) != (tt.want.After == nil) || got.After != nil && *got.After != *tt.want.After {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/google/uuid"
+)
+
+// authenticateUser is authenticate for routes that take only JWTs.
+func (cfg *apiConfig) authenticateUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	identity, ok := cfg.authenticateSession(w, r)
+	return identity.UserID, ok
+}
+
+// authenticateSession returns the identity in a request's JWT. Tokens keep
+// their claims until they expire, so the session and user are looked up as
+// well: tokens of a session that has been logged out, or of a user who has
+// since been deleted or disabled, are turned away.
+func (cfg *apiConfig) authenticateSession(w http.ResponseWriter, r *http.Request) (auth.Identity, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return auth.Identity{}, false
+	}
+	identity, err := auth.ValidateAccessJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return auth.Identity{}, false
+	}
+	if !cfg.requireActiveSession(w, identity) || !cfg.requireActiveUser(w, identity.UserID) {
+		return auth.Identity{}, false
+	}
+	return identity, true
+}
+
+// requireActiveSession checks that the session an access token was issued
+// to hasn't been revoked or expired. Tokens from before sessions existed
+// don't name one and are let through. If the check fails, it writes the
+// error response and returns false.
+func (cfg *apiConfig) requireActiveSession(w http.ResponseWriter, identity auth.Identity) bool {
+	if identity.SessionID == uuid.Nil {
+		return true
+	}
+	session, err := cfg.db.GetSession(identity.SessionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get session", err)
+		return false
+	}
+	if session.ID == uuid.Nil || session.UserID != identity.UserID {
+		respondWithError(w, http.StatusUnauthorized, "Session has been logged out or has expired", nil)
+		return false
+	}
+	return true
+}
+
+// requireActiveUser checks that a user still exists and isn't disabled. If
+// not, it writes the error response and returns false.
+func (cfg *apiConfig) requireActiveUser(w http.ResponseWriter, userID uuid.UUID) bool {
+	user, err := cfg.db.GetUser(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return false
+	}
+	if user == nil {
+		respondWithError(w, http.StatusUnauthorized, "User no longer exists", nil)
+		return false
+	}
+	if user.DisabledAt != nil {
+		respondWithError(w, http.StatusForbidden, "This account has been disabled", nil)
+		return false
+	}
+	return true
+}
+
+// activeSession is requireActiveSession for viewer, which doesn't respond.
+func (cfg *apiConfig) activeSession(identity auth.Identity) bool {
+	if identity.SessionID == uuid.Nil {
+		return true
+	}
+	session, err := cfg.db.GetSession(identity.SessionID)
+	if err != nil {
+		log.Printf("Error getting session %s: %v", identity.SessionID, err)
+		return false
+	}
+	return session.ID != uuid.Nil && session.UserID == identity.UserID
+}
+
+// activeUser is requireActiveUser for viewer, which doesn't respond.
+func (cfg *apiConfig) activeUser(userID uuid.UUID) bool {
+	user, err := cfg.db.GetUser(userID)
+	if err != nil {
+		log.Printf("Error getting user %s: %v", userID, err)
+		return false
+	}
+	return user != nil && user.DisabledAt == nil
+}
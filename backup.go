@@ -0,0 +1,312 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// Backups are tar archives compressed with the zstd command. The database
+// snapshot and assets come first, then manifest.json with the checksum of
+// every other entry, so the archive can be written in one pass.
+const (
+	backupFormatVersion = 1
+	backupDatabaseName  = "tubely.db"
+	backupAssetsDir     = "assets"
+	backupObjectsName   = "objects.json"
+	backupManifestName  = "manifest.json"
+)
+
+type backupManifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Migration is the newest migration applied to the snapshot.
+	Migration int          `json:"migration"`
+	Files     []backupFile `json:"files"`
+}
+
+type backupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// backupObject is an entry of objects.json. Objects stay in the bucket;
+// the listing records what the database expected to find there.
+type backupObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	withObjects := flags.Bool("objects", false, "include a listing of the bucket's objects")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+	archivePath := flags.Arg(0)
+
+	databaseURL, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+	assetsRoot := os.Getenv("ASSETS_ROOT")
+	if assetsRoot == "" {
+		return errors.New("ASSETS_ROOT environment variable is not set")
+	}
+
+	db, err := database.Open(databaseURL)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to database: %w", err)
+	}
+	defer db.Close()
+
+	tempDir, err := os.MkdirTemp("", "tubely-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The snapshot is taken before the assets are copied, so files deleted
+	// in between may be missing; files added in between are harmless extras.
+	snapshotPath := filepath.Join(tempDir, backupDatabaseName)
+	err = db.Snapshot(snapshotPath)
+	if err != nil {
+		return fmt.Errorf("couldn't snapshot database: %w", err)
+	}
+	manifest := backupManifest{
+		FormatVersion: backupFormatVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			manifest.Migration = max(manifest.Migration, s.Version)
+		}
+	}
+
+	var objectsPath string
+	if *withObjects {
+		objectsPath = filepath.Join(tempDir, backupObjectsName)
+		count, err := writeObjectListing(context.Background(), objectsPath)
+		if err != nil {
+			return fmt.Errorf("couldn't list bucket objects: %w", err)
+		}
+		fmt.Printf("listed %d objects\n", count)
+	}
+
+	partialPath := archivePath + ".partial"
+	out, err := os.Create(partialPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partialPath)
+	defer out.Close()
+
+	zstd := exec.Command("zstd", "-q", "-c", "-T0")
+	zstd.Stdout = out
+	zstd.Stderr = os.Stderr
+	zstdIn, err := zstd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	err = zstd.Start()
+	if err != nil {
+		return fmt.Errorf("couldn't run zstd: %w", err)
+	}
+
+	tw := tar.NewWriter(zstdIn)
+	err = writeBackupEntries(tw, &manifest, snapshotPath, objectsPath, assetsRoot)
+	if err == nil {
+		err = tw.Close()
+	}
+	zstdIn.Close()
+	if waitErr := zstd.Wait(); err == nil && waitErr != nil {
+		err = fmt.Errorf("zstd failed: %w", waitErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = out.Sync()
+	if err != nil {
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(partialPath, archivePath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s with %d files\n", archivePath, len(manifest.Files))
+	return nil
+}
+
+func writeBackupEntries(tw *tar.Writer, manifest *backupManifest, snapshotPath, objectsPath, assetsRoot string) error {
+	add := func(name, path string) error {
+		file, err := addBackupFile(tw, name, path)
+		if err != nil {
+			return fmt.Errorf("couldn't add %s: %w", name, err)
+		}
+		manifest.Files = append(manifest.Files, file)
+		return nil
+	}
+
+	err := add(backupDatabaseName, snapshotPath)
+	if err != nil {
+		return err
+	}
+	if objectsPath != "" {
+		err = add(backupObjectsName, objectsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = filepath.WalkDir(assetsRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			if !d.IsDir() {
+				fmt.Printf("skipping %s: not a regular file\n", path)
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(assetsRoot, path)
+		if err != nil {
+			return err
+		}
+		return add(backupAssetsDir+"/"+filepath.ToSlash(rel), path)
+	})
+	if err != nil {
+		return err
+	}
+
+	dat, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    backupManifestName,
+		Mode:    0o644,
+		Size:    int64(len(dat)),
+		ModTime: manifest.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(dat)
+	return err
+}
+
+// addBackupFile copies a file into the archive, checksumming it on the way.
+func addBackupFile(tw *tar.Writer, name, path string) (backupFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return backupFile{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return backupFile{}, err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return backupFile{}, err
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tw, hash), f)
+	if err != nil {
+		return backupFile{}, err
+	}
+	if n != info.Size() {
+		return backupFile{}, fmt.Errorf("file changed size while being copied")
+	}
+	return backupFile{Path: name, Size: n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// writeObjectListing lists every object in S3_BUCKET into a JSON file.
+func writeObjectListing(ctx context.Context, path string) (int, error) {
+	client, bucket, err := loadBucket(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	objects := []backupObject{}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: &bucket})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		for _, obj := range page.Contents {
+			object := backupObject{Key: *obj.Key}
+			if obj.Size != nil {
+				object.Size = *obj.Size
+			}
+			if obj.ETag != nil {
+				object.ETag = *obj.ETag
+			}
+			if obj.LastModified != nil {
+				object.LastModified = *obj.LastModified
+			}
+			objects = append(objects, object)
+		}
+	}
+
+	dat, err := json.MarshalIndent(objects, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return len(objects), os.WriteFile(path, dat, 0o600)
+}
+
+// loadBucket connects to S3_BUCKET for commands that run without the
+// server's configuration.
+func loadBucket(ctx context.Context) (*s3.Client, string, error) {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, "", errors.New("S3_BUCKET environment variable is not set")
+	}
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		return nil, "", errors.New("S3_REGION environment variable is not set")
+	}
+	awsConfig, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't load AWS config: %w", err)
+	}
+	return s3.NewFromConfig(awsConfig), bucket, nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeZstd compresses an uncompressed archive the way runBackup does.
+func writeZstd(t *testing.T, tarball []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "backup.tar.zst")
+	zstd := exec.Command("zstd", "-q", "-c")
+	zstd.Stdin = bytes.NewReader(tarball)
+	out, err := zstd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, out, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd isn't installed")
+	}
+
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "snapshot.db")
+	assetsRoot := filepath.Join(dir, "assets")
+	for path, content := range map[string]string{
+		snapshotPath:                                    "database",
+		filepath.Join(assetsRoot, "thumb.png"):          "png",
+		filepath.Join(assetsRoot, "hls", "a", "v.m3u8"): "#EXTM3U",
+	} {
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	manifest := backupManifest{FormatVersion: backupFormatVersion, CreatedAt: time.Now().UTC()}
+	err := writeBackupEntries(tw, &manifest, snapshotPath, "", assetsRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbStage, assetsStage := t.TempDir(), t.TempDir()
+	got, err := extractBackup(writeZstd(t, tarball.Bytes()), dbStage, assetsStage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Files) != 3 {
+		t.Errorf("got %d files in the manifest, want 3", len(got.Files))
+	}
+	for path, want := range map[string]string{
+		filepath.Join(dbStage, backupDatabaseName):       "database",
+		filepath.Join(assetsStage, "thumb.png"):          "png",
+		filepath.Join(assetsStage, "hls", "a", "v.m3u8"): "#EXTM3U",
+	} {
+		dat, err := os.ReadFile(path)
+		if err != nil || string(dat) != want {
+			t.Errorf("%s: got %q and error %v, want %q", path, dat, err, want)
+		}
+	}
+}
+
+func TestExtractBackupRejects(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd isn't installed")
+	}
+
+	type entry struct{ name, body string }
+	valid := []entry{
+		{backupDatabaseName, "database"},
+		{"assets/thumb.png", "png"},
+	}
+
+	tests := []struct {
+		name    string
+		entries []entry
+		// tamper changes the manifest, which starts out matching entries.
+		tamper     func(*backupManifest)
+		noManifest bool
+		wantErr    string
+	}{
+		{
+			name:    "checksum mismatch",
+			entries: valid,
+			tamper:  func(m *backupManifest) { m.Files[1].SHA256 = strings.Repeat("0", 64) },
+			wantErr: "doesn't match its checksum",
+		},
+		{
+			name:    "size mismatch",
+			entries: valid,
+			tamper:  func(m *backupManifest) { m.Files[0].Size++ },
+			wantErr: "doesn't match its checksum",
+		},
+		{
+			name:    "file missing from the archive",
+			entries: valid,
+			tamper: func(m *backupManifest) {
+				m.Files = append(m.Files, backupFile{Path: "assets/gone.png", SHA256: m.Files[1].SHA256, Size: 3})
+			},
+			wantErr: "missing from the backup",
+		},
+		{
+			name:    "file missing from the manifest",
+			entries: valid,
+			tamper:  func(m *backupManifest) { m.Files = m.Files[:1] },
+			wantErr: "not in its manifest",
+		},
+		{name: "no manifest", entries: valid, noManifest: true, wantErr: "no manifest"},
+		{
+			name:    "newer format",
+			entries: valid,
+			tamper:  func(m *backupManifest) { m.FormatVersion++ },
+			wantErr: "unsupported backup format",
+		},
+		{name: "parent directory", entries: []entry{{"../escape.db", "x"}}, wantErr: "unsafe path"},
+		{name: "parent directory inside assets", entries: []entry{{"assets/../../escape.png", "x"}}, wantErr: "unsafe path"},
+		{name: "absolute path", entries: []entry{{"/etc/passwd", "x"}}, wantErr: "unsafe path"},
+		{name: "unknown entry", entries: []entry{{"notes.txt", "x"}}, wantErr: "unexpected entry"},
+		{name: "duplicate entry", entries: append(valid, valid[1]), wantErr: "appears twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tarball bytes.Buffer
+			tw := tar.NewWriter(&tarball)
+			manifest := backupManifest{FormatVersion: backupFormatVersion}
+			write := func(name string, body []byte) {
+				err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body))})
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = tw.Write(body)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, e := range tt.entries {
+				write(e.name, []byte(e.body))
+				sum := sha256.Sum256([]byte(e.body))
+				manifest.Files = append(manifest.Files, backupFile{Path: e.name, Size: int64(len(e.body)), SHA256: hex.EncodeToString(sum[:])})
+			}
+			if tt.tamper != nil {
+				tt.tamper(&manifest)
+			}
+			if !tt.noManifest {
+				dat, err := json.Marshal(manifest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				write(backupManifestName, dat)
+			}
+			err := tw.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stage := t.TempDir()
+			dbStage, assetsStage := filepath.Join(stage, "db"), filepath.Join(stage, "assets")
+			_, err = extractBackup(writeZstd(t, tarball.Bytes()), dbStage, assetsStage)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(stage, "escape.db")); err == nil {
+				t.Error("an entry was written outside the staging directories")
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+const usage = `usage: tubely [command]
+
+Without a command the HTTP server is started.
+
+commands:
+  migrate up          apply all pending migrations
+  migrate down [n]    roll back the last n migrations (default 1)
+  migrate status      list migrations and whether they are applied
+  backup [-objects] <file>
+                      write a snapshot of the SQLite database and the assets
+                      to a .tar.zst archive; -objects adds a listing of the
+                      bucket's objects
+  restore [-check-objects] <file>
+                      verify a backup and swap its database and assets in,
+                      keeping the current ones as *.pre-restore-*; stop the
+                      server first
+  promote <email> [role]
+                      give the user with email a role (default admin);
+                      use it to create the first admin, who can then manage
+                      roles through the API`
+
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	case "backup":
+		return runBackup(args[1:])
+	case "restore":
+		return runRestore(args[1:])
+	case "promote":
+		return runPromote(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+// getDatabaseURL returns DB_URL, falling back to DB_PATH for SQLite
+// deployments configured before DB_URL existed.
+func getDatabaseURL() (string, error) {
+	if databaseURL := os.Getenv("DB_URL"); databaseURL != "" {
+		return databaseURL, nil
+	}
+	if pathToDB := os.Getenv("DB_PATH"); pathToDB != "" {
+		return pathToDB, nil
+	}
+	return "", errors.New("DB_URL or DB_PATH must be set")
+}
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	databaseURL, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+	db, err := database.Open(databaseURL)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to database: %w", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		rolledBack, err := db.MigrateDown(steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("no migrations to roll back")
+		}
+		return nil
+
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], usage)
+	}
+}
+
+func runPromote(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New(usage)
+	}
+	email, role := args[0], database.RoleAdmin
+	if len(args) == 2 {
+		role = args[1]
+	}
+	if !validRole(role) {
+		return fmt.Errorf("unknown role %q; use user, moderator or admin", role)
+	}
+
+	databaseURL, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+	db, err := database.NewClient(databaseURL)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to database: %w", err)
+	}
+	defer db.Close()
+
+	user, err := db.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user.ID == uuid.Nil {
+		return fmt.Errorf("no user with email %q", email)
+	}
+	err = db.SetUserRole(user.ID, role)
+	if err != nil {
+		return err
+	}
+
+	event := userEvent(nil, auditUserRoleChange, user.ID, database.AuditOutcomeSuccess)
+	event.Detail = user.Role + " -> " + role + " from the command line"
+	_, err = db.CreateAuditEvent(event)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s; their next login or refresh picks up the role\n", email, role)
+	return nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// exportRetention is how long a finished archive stays downloadable.
+	exportRetention       = 7 * 24 * time.Hour
+	exportLinkExpiry      = time.Hour
+	exportCleanupInterval = time.Hour
+)
+
+// exportProfile is the part of a user's account included in an export.
+// The password hash stays out.
+type exportProfile struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email"`
+}
+
+type exportVideo struct {
+	database.Video
+	Versions []database.VideoVersion `json:"versions"`
+}
+
+// runExport builds a user's export archive and records the outcome. It
+// runs in the background after the request that started it returns.
+func (cfg *apiConfig) runExport(ctx context.Context, export database.Export) {
+	export.Status = database.ExportStatusRunning
+	err := cfg.db.UpdateExport(export)
+	if err != nil {
+		log.Printf("Couldn't start export %s: %v", export.ID, err)
+		return
+	}
+
+	key, size, err := cfg.buildExport(ctx, export)
+	now := time.Now().UTC()
+	export.CompletedAt = &now
+	if err != nil {
+		log.Printf("Export %s failed: %v", export.ID, err)
+		message := "Couldn't build the export; please try again"
+		export.Status = database.ExportStatusFailed
+		export.Error = &message
+	} else {
+		expiresAt := now.Add(exportRetention)
+		export.Status = database.ExportStatusReady
+		export.ObjectKey = &key
+		export.SizeBytes = &size
+		export.ExpiresAt = &expiresAt
+	}
+
+	err = cfg.db.UpdateExport(export)
+	if err != nil {
+		log.Printf("Couldn't save export %s: %v", export.ID, err)
+	}
+}
+
+// buildExport writes the archive to a temporary file and uploads it,
+// returning its key and size.
+func (cfg *apiConfig) buildExport(ctx context.Context, export database.Export) (string, int64, error) {
+	f, err := os.CreateTemp("", "tubely-export-*.zip")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	err = cfg.writeExport(ctx, zw, export.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	err = zw.Close()
+	if err != nil {
+		return "", 0, err
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", 0, err
+	}
+	key := fmt.Sprintf("exports/%s/%s.zip", export.UserID, export.ID)
+	err = cfg.putObject(ctx, key, f, "application/zip")
+	if err != nil {
+		return "", 0, err
+	}
+	return key, size, nil
+}
+
+// writeExport adds the user's profile and, for every video including those
+// in the trash, its metadata, thumbnail, uploaded versions and captions.
+func (cfg *apiConfig) writeExport(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+	user, err := cfg.db.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %s not found", userID)
+	}
+	err = writeExportJSON(zw, "profile.json", exportProfile{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Email:     user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	videos, err := cfg.db.GetVideos(userID)
+	if err != nil {
+		return err
+	}
+	trashed, err := cfg.db.GetDeletedVideos(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, video := range append(videos, trashed...) {
+		dir := "videos/" + video.ID.String() + "/"
+		versions, err := cfg.db.GetVideoVersions(video.ID)
+		if err != nil {
+			return err
+		}
+		err = writeExportJSON(zw, dir+"metadata.json", exportVideo{Video: video, Versions: versions})
+		if err != nil {
+			return err
+		}
+
+		if video.ThumbnailURL != nil {
+			if assetPath, ok := cfg.localAssetPath(*video.ThumbnailURL); ok {
+				err = writeExportFile(zw, dir+"thumbnail"+path.Ext(assetPath), assetPath)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// HLS playlists point at segments elsewhere, so only files stored
+		// whole in the bucket are included.
+		for _, version := range versions {
+			key, ok := cfg.objectKey(version.VideoURL)
+			if !ok || strings.HasSuffix(key, ".m3u8") {
+				continue
+			}
+			name := fmt.Sprintf("%sversions/v%d%s", dir, version.Version, path.Ext(key))
+			err = cfg.writeExportObject(ctx, zw, name, key)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, track := range video.SubtitleTracks {
+			key, ok := cfg.objectKey(track.URL)
+			if !ok {
+				continue
+			}
+			name := fmt.Sprintf("%ssubtitles/%s-%s.vtt", dir, track.Language, track.ID)
+			err = cfg.writeExportObject(ctx, zw, name, key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeExportJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeExportFile(zw *zip.Writer, name, filePath string) error {
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// writeExportObject copies a bucket object into the archive. Media is
+// already compressed, so it is stored as is.
+func (cfg *apiConfig) writeExportObject(ctx context.Context, zw *zip.Writer, name, key string) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	err = cfg.downloadObject(ctx, key, w)
+	if err != nil {
+		return fmt.Errorf("couldn't download %s: %w", key, err)
+	}
+	return nil
+}
+
+// presignExport returns a link to download a ready export and when it
+// stops working, which is never after the export expires.
+func (cfg *apiConfig) presignExport(ctx context.Context, export database.Export) (string, time.Time, error) {
+	expiry := exportLinkExpiry
+	if export.ExpiresAt != nil {
+		expiry = min(expiry, time.Until(*export.ExpiresAt))
+	}
+	disposition := fmt.Sprintf(`attachment; filename="tubely-export-%s.zip"`, export.CreatedAt.Format("2006-01-02"))
+	req, err := s3.NewPresignClient(cfg.s3Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     &cfg.s3Bucket,
+		Key:                        export.ObjectKey,
+		ResponseContentDisposition: &disposition,
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return req.URL, time.Now().Add(expiry).UTC(), nil
+}
+
+// runExportCleaner deletes expired export archives now and then every
+// exportCleanupInterval until ctx is done.
+func (cfg *apiConfig) runExportCleaner(ctx context.Context) {
+	ticker := time.NewTicker(exportCleanupInterval)
+	defer ticker.Stop()
+	for {
+		err := cfg.deleteExpiredExports(ctx)
+		if err != nil {
+			log.Printf("Error deleting expired exports: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) deleteExpiredExports(ctx context.Context) error {
+	exports, err := cfg.db.GetExportsExpiredBefore(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if export.ObjectKey != nil {
+			err := cfg.deleteObject(ctx, *export.ObjectKey)
+			if err != nil {
+				return fmt.Errorf("export %s: %w", export.ID, err)
+			}
+		}
+		err = cfg.db.DeleteExport(export.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
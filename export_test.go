@@ -0,0 +1,180 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func TestWriteExport(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.assetsRoot = t.TempDir()
+	cfg.port = "8091"
+	cfg.s3CfDistribution = "cdn.example.com"
+	user := testUser(t, cfg, "export@example.com")
+	other := testUser(t, cfg, "other@example.com")
+
+	err := os.WriteFile(filepath.Join(cfg.assetsRoot, "thumb.png"), []byte("png"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createVideo := func(title string, owner *database.User, thumbnailURL *string) database.Video {
+		video, err := cfg.db.CreateVideo(database.CreateVideoParams{Title: title, UserID: owner.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		video.ThumbnailURL = thumbnailURL
+		err = cfg.db.UpdateVideo(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return video
+	}
+	withThumbnail := createVideo("thumbnail", user, ptr("http://localhost:8091/assets/thumb.png"))
+	// A thumbnail that's gone from disk is left out rather than failing.
+	missingThumbnail := createVideo("missing thumbnail", user, ptr("http://localhost:8091/assets/gone.png"))
+	trashed := createVideo("trashed", user, nil)
+	err = cfg.db.TrashVideo(trashed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Versions outside the bucket can't be downloaded, so they're only listed.
+	_, err = cfg.db.CreateVideoVersion(database.CreateVideoVersionParams{VideoID: trashed.ID, VideoURL: "https://elsewhere.example.com/v.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createVideo("someone else's", other, nil)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err = cfg.writeExport(context.Background(), zw, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		got = append(got, f.Name)
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dat, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[f.Name] = string(dat)
+	}
+	slices.Sort(got)
+
+	want := []string{
+		"profile.json",
+		"videos/" + missingThumbnail.ID.String() + "/metadata.json",
+		"videos/" + withThumbnail.ID.String() + "/metadata.json",
+		"videos/" + withThumbnail.ID.String() + "/thumbnail.png",
+		"videos/" + trashed.ID.String() + "/metadata.json",
+	}
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("got entries %q, want %q", got, want)
+	}
+
+	if profile := contents["profile.json"]; !strings.Contains(profile, user.Email) || strings.Contains(profile, "password") {
+		t.Errorf("unexpected profile %s", profile)
+	}
+	if contents["videos/"+withThumbnail.ID.String()+"/thumbnail.png"] != "png" {
+		t.Error("thumbnail wasn't copied")
+	}
+	if metadata := contents["videos/"+trashed.ID.String()+"/metadata.json"]; !strings.Contains(metadata, "elsewhere.example.com/v.mp4") {
+		t.Errorf("trashed video's metadata doesn't list its version: %s", metadata)
+	}
+}
+
+func TestNewExportResponse(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.s3Bucket = "tubely"
+	cfg.s3Client = s3.New(s3.Options{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+		}),
+	})
+
+	key := "exports/user/export.zip"
+	tests := []struct {
+		name       string
+		export     database.Export
+		wantLink   bool
+		wantExpiry time.Duration
+	}{
+		{name: "pending", export: database.Export{Status: database.ExportStatusPending}},
+		{name: "failed", export: database.Export{Status: database.ExportStatusFailed}},
+		{
+			name:       "ready",
+			export:     database.Export{Status: database.ExportStatusReady, ObjectKey: &key, ExpiresAt: ptr(time.Now().Add(exportRetention))},
+			wantLink:   true,
+			wantExpiry: exportLinkExpiry,
+		},
+		{
+			name:       "about to expire",
+			export:     database.Export{Status: database.ExportStatusReady, ObjectKey: &key, ExpiresAt: ptr(time.Now().Add(10 * time.Minute))},
+			wantLink:   true,
+			wantExpiry: 10 * time.Minute,
+		},
+		{
+			name:   "expired",
+			export: database.Export{Status: database.ExportStatusReady, ObjectKey: &key, ExpiresAt: ptr(time.Now().Add(-time.Minute))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := cfg.newExportResponse(context.Background(), tt.export)
+			if (response.DownloadURL != nil) != tt.wantLink {
+				t.Fatalf("got download link %v, want one: %v", response.DownloadURL, tt.wantLink)
+			}
+			if !tt.wantLink {
+				return
+			}
+
+			link, err := url.Parse(*response.DownloadURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seconds, err := strconv.Atoi(link.Query().Get("X-Amz-Expires"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Allow for the time the test takes.
+			if expiry := time.Duration(seconds) * time.Second; expiry > tt.wantExpiry || expiry < tt.wantExpiry-time.Minute {
+				t.Errorf("link lasts %s, want %s", expiry, tt.wantExpiry)
+			}
+			if !strings.Contains(link.Path, key) || !strings.Contains(link.Query().Get("response-content-disposition"), "attachment") {
+				t.Errorf("unexpected link %s", link)
+			}
+		})
+	}
+}
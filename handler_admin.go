@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+// adminUserResponse is a user as admins see it, without the password hash.
+type adminUserResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+func newAdminUserResponse(user database.User) adminUserResponse {
+	return adminUserResponse{
+		ID:         user.ID,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+		Email:      user.Email,
+		Role:       user.Role,
+		DisabledAt: user.DisabledAt,
+	}
+}
+
+// handlerAdminUsersList lists every account.
+func (cfg *apiConfig) handlerAdminUsersList(w http.ResponseWriter, r *http.Request) {
+	users, err := cfg.db.GetUsers()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve users", err)
+		return
+	}
+
+	responses := []adminUserResponse{}
+	for _, user := range users {
+		responses = append(responses, newAdminUserResponse(user))
+	}
+	respondWithJSON(w, http.StatusOK, responses)
+}
+
+// handlerAdminUserUpdate changes a user's role or disables or re-enables
+// their account. Admins can't do either to themselves, so there is always
+// an admin left.
+func (cfg *apiConfig) handlerAdminUserUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role     *string `json:"role"`
+		Disabled *bool   `json:"disabled"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	admin := requestUser(r)
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Role != nil && !validRole(*params.Role) {
+		respondWithError(w, http.StatusBadRequest, "Role must be user, moderator or admin", nil)
+		return
+	}
+	if userID == admin.ID {
+		respondWithError(w, http.StatusForbidden, "You can't change your own role or disable yourself", nil)
+		return
+	}
+
+	user, err := cfg.db.GetUser(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	if user == nil {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+
+	if params.Role != nil && *params.Role != user.Role {
+		err = cfg.db.SetUserRole(user.ID, *params.Role)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't change role", err)
+			return
+		}
+		event := userEvent(&admin.ID, auditUserRoleChange, user.ID, database.AuditOutcomeSuccess)
+		event.Detail = user.Role + " -> " + *params.Role
+		cfg.audit(r, event)
+	}
+	if params.Disabled != nil && *params.Disabled != (user.DisabledAt != nil) {
+		action := auditUserEnable
+		if *params.Disabled {
+			action = auditUserDisable
+			err = cfg.db.DisableUser(user.ID)
+		} else {
+			err = cfg.db.EnableUser(user.ID)
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update account", err)
+			return
+		}
+		cfg.audit(r, userEvent(&admin.ID, action, user.ID, database.AuditOutcomeSuccess))
+	}
+
+	user, err = cfg.db.GetUser(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newAdminUserResponse(*user))
+}
+
+// handlerAdminVideoGet shows any user's video, including one in the trash,
+// with its owner and versions.
+func (cfg *apiConfig) handlerAdminVideoGet(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		database.Video
+		Owner    *adminUserResponse      `json:"owner"`
+		Versions []database.VideoVersion `json:"versions"`
+	}
+
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err == nil && video.ID == uuid.Nil {
+		video, err = cfg.db.GetDeletedVideo(videoID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return
+	}
+
+	owner, err := cfg.db.GetUser(video.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get owner", err)
+		return
+	}
+	versions, err := cfg.db.GetVideoVersions(video.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve versions", err)
+		return
+	}
+
+	resp := response{Video: video, Versions: versions}
+	if owner != nil {
+		o := newAdminUserResponse(*owner)
+		resp.Owner = &o
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
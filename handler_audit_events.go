@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditEvents = 50
+	maxAuditEvents     = 200
+)
+
+// handlerAuditEventsMine lists the events the user performed or that
+// targeted their account, newest first.
+func (cfg *apiConfig) handlerAuditEventsMine(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	params, err := parseListAuditEventsParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params.UserID = userID
+
+	cfg.respondWithAuditEvents(w, r, params)
+}
+
+// handlerAuditEventsAll lists every user's events for admins, optionally
+// narrowed to one user with ?user_id=.
+func (cfg *apiConfig) handlerAuditEventsAll(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListAuditEventsParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		params.UserID, err = uuid.Parse(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid user_id", err)
+			return
+		}
+	}
+
+	cfg.respondWithAuditEvents(w, r, params)
+}
+
+func parseListAuditEventsParams(query url.Values) (database.ListAuditEventsParams, error) {
+	params := database.ListAuditEventsParams{
+		Action: query.Get("action"),
+		Limit:  defaultAuditEvents,
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditEvents {
+			return params, fmt.Errorf("limit must be between 1 and %d", maxAuditEvents)
+		}
+		params.Limit = limit
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := database.DecodeAuditEventCursor(value)
+		if err != nil {
+			return params, err
+		}
+		params.After = &cursor
+	}
+	return params, nil
+}
+
+func (cfg *apiConfig) respondWithAuditEvents(w http.ResponseWriter, r *http.Request, params database.ListAuditEventsParams) {
+	events, next, err := cfg.db.ListAuditEvents(params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve audit events", err)
+		return
+	}
+	if next != nil {
+		setNextPageHeaders(w, r, next.Encode())
+	}
+	respondWithJSON(w, http.StatusOK, events)
+}
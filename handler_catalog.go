@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerCatalog lists every user's public videos that have been uploaded.
+// It takes the same paging, sorting and filtering parameters as GET
+// /api/videos and needs no credentials.
+func (cfg *apiConfig) handlerCatalog(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListVideosParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if params.Visibility != "" && params.Visibility != database.VisibilityPublic {
+		respondWithError(w, http.StatusBadRequest, "The catalog only lists public videos", nil)
+		return
+	}
+	params.UserID = uuid.Nil
+	params.Visibility = database.VisibilityPublic
+	hasVideo := true
+	params.HasVideo = &hasVideo
+
+	videos, next, err := cfg.db.ListVideos(params)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve videos", err)
+		return
+	}
+
+	if next != nil {
+		setNextPageHeaders(w, r, next.Encode())
+	}
+	respondWithJSON(w, http.StatusOK, videos)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerClipCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Start       string `json:"start"`
+		End         string `json:"end"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		// Accurate re-encodes the clip so it starts and ends on the exact
+		// frames requested instead of the nearest keyframes.
+		Accurate bool `json:"accurate"`
+	}
+
+	videoIDString := r.PathValue("videoID")
+	videoID, err := uuid.Parse(videoIDString)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticate(w, r, scopeVideosWrite)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	start, err := parseClipTimestamp(params.Start)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid start timestamp", err)
+		return
+	}
+	end, err := parseClipTimestamp(params.End)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid end timestamp", err)
+		return
+	}
+	if end <= start {
+		respondWithError(w, http.StatusBadRequest, "Clip must end after it starts", nil)
+		return
+	}
+
+	source, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if source.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return
+	}
+	if source.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't clip this video", nil)
+		return
+	}
+	if source.VideoURL == nil {
+		respondWithError(w, http.StatusConflict, "Video has no uploaded media to clip", nil)
+		return
+	}
+	sourceKey, ok := cfg.objectKey(*source.VideoURL)
+	if !ok || !strings.HasSuffix(sourceKey, ".mp4") {
+		respondWithError(w, http.StatusConflict, "Video media can't be clipped", nil)
+		return
+	}
+
+	sourceFile, err := os.CreateTemp("", "tubely-clip-source-*.mp4")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating temp file", err)
+		return
+	}
+	defer os.Remove(sourceFile.Name())
+	defer sourceFile.Close()
+
+	err = cfg.downloadObject(r.Context(), sourceKey, sourceFile)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error downloading source video", err)
+		return
+	}
+
+	duration, err := getVideoDuration(sourceFile.Name())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting video duration", err)
+		return
+	}
+	if end > duration {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Clip ends after the video does (%.3fs)", duration.Seconds()), nil)
+		return
+	}
+
+	clipPath, err := cutVideo(sourceFile.Name(), start, end, params.Accurate)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error cutting clip", err)
+		return
+	}
+	defer os.Remove(clipPath)
+
+	aspectRatio, err := getVideoAspectRatio(clipPath)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting video aspect ratio", err)
+		return
+	}
+
+	clipFile, err := os.Open(clipPath)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error opening clip", err)
+		return
+	}
+	defer clipFile.Close()
+
+	fileName, err := randomAssetName()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating random data", err)
+		return
+	}
+	key := fmt.Sprintf("%s/%s.mp4", aspectRatio, fileName)
+
+	uow, err := cfg.db.BeginTx(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting upload", err)
+		return
+	}
+	defer rollback(uow)
+
+	err = cfg.stageObject(r.Context(), uow, key, clipFile, "video/mp4")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error uploading clip to S3", err)
+		return
+	}
+
+	title := params.Title
+	if title == "" {
+		title = fmt.Sprintf("%s (clip)", source.Title)
+	}
+	description := params.Description
+	if description == "" {
+		description = source.Description
+	}
+
+	clip, err := uow.CreateVideo(database.CreateVideoParams{
+		Title:       title,
+		Description: description,
+		UserID:      userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create clip video", err)
+		return
+	}
+
+	// Stream-copied clips snap to keyframes, so the probed duration can
+	// differ from end - start.
+	versionParams := database.CreateVideoVersionParams{
+		VideoID:     clip.ID,
+		VideoURL:    cfg.objectURL(key),
+		AspectRatio: &aspectRatio,
+	}
+	err = probeVideoVersion(clipPath, &versionParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error probing clip", err)
+		return
+	}
+	version, err := uow.CreateVideoVersion(versionParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording video version", err)
+		return
+	}
+	err = saveVideo(uow, r, &clip, func(clip *database.Video) {
+		setCurrentVersion(clip, version)
+		clip.ThumbnailURL = source.ThumbnailURL
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating video metadata", err)
+		return
+	}
+	err = uow.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating video metadata", err)
+		return
+	}
+
+	respondWithVideo(w, http.StatusCreated, clip)
+}
+
+// maxClipTimestamp is the longest timestamp, in seconds, that fits in a
+// time.Duration.
+const maxClipTimestamp = float64(math.MaxInt64 / time.Second)
+
+// parseClipTimestamp accepts plain seconds ("90.5") or clock notation
+// ("1:30.5", "00:01:30.500").
+func parseClipTimestamp(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("timestamp is required")
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	var total float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 || math.IsNaN(v) || v > maxClipTimestamp {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		isLast := i == len(parts)-1
+		if !isLast && v != float64(int(v)) {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		if i > 0 && v >= 60 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		total = total*60 + v
+	}
+	if total > maxClipTimestamp {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// cutVideo extracts [start, end) from the video. Without accurate, streams
+// are copied and the cut snaps to keyframes; with it, the clip is
+// re-encoded so it begins on the exact frame.
+func cutVideo(filePath string, start, end time.Duration, accurate bool) (string, error) {
+	outputPath := fmt.Sprintf("%s.clip.mp4", filePath)
+
+	args := []string{
+		"-ss", formatFFmpegDuration(start),
+		"-i", filePath,
+		"-t", formatFFmpegDuration(end - start),
+	}
+	if accurate {
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-c:a", "aac")
+	} else {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	}
+	args = append(args, "-movflags", "faststart", "-f", "mp4", outputPath)
+
+	cmd := exec.Command("ffmpeg", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to run ffmpeg: %w\n%s", err, string(output))
+	}
+
+	return outputPath, nil
+}
+
+func formatFFmpegDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClipTimestamp(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "0", want: 0},
+		{input: "90.5", want: 90*time.Second + 500*time.Millisecond},
+		{input: " 12 ", want: 12 * time.Second},
+		{input: "1:30.5", want: 90*time.Second + 500*time.Millisecond},
+		{input: "00:01:30.500", want: 90*time.Second + 500*time.Millisecond},
+		{input: "2:00:00", want: 2 * time.Hour},
+		{input: "75", want: 75 * time.Second},
+		{input: "", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "-1", wantErr: true},
+		{input: "1:60", wantErr: true},
+		{input: "1:-5", wantErr: true},
+		{input: "1.5:30", wantErr: true},
+		{input: "1:2:3:4", wantErr: true},
+		{input: "1::30", wantErr: true},
+		{input: "NaN", wantErr: true},
+		{input: "Inf", wantErr: true},
+		{input: "1e300", wantErr: true},
+		{input: "1e300:00", wantErr: true},
+		{input: "2562047:47:16", want: 2562047*time.Hour + 47*time.Minute + 16*time.Second},
+		{input: "2562047:47:17", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseClipTimestamp(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFFmpegDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0.000"},
+		{1500 * time.Millisecond, "1.500"},
+		{time.Hour + time.Millisecond, "3600.001"},
+	}
+	for _, tt := range tests {
+		if got := formatFFmpegDuration(tt.d); got != tt.want {
+			t.Errorf("formatFFmpegDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
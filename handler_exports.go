@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+type exportResponse struct {
+	database.Export
+	DownloadURL       *string    `json:"download_url"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at"`
+}
+
+// handlerExportCreate starts building an archive of the user's data. If an
+// export is already under way, it is returned instead of starting another.
+func (cfg *apiConfig) handlerExportCreate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	exports, err := cfg.db.GetExports(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve exports", err)
+		return
+	}
+	for _, export := range exports {
+		if export.Status == database.ExportStatusPending || export.Status == database.ExportStatusRunning {
+			w.Header().Set("Location", "/api/users/me/exports/"+export.ID.String())
+			cfg.respondWithExport(w, r, http.StatusAccepted, export)
+			return
+		}
+	}
+
+	export, err := cfg.db.CreateExport(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create export", err)
+		return
+	}
+	cfg.audit(r, userEvent(&userID, auditUserExport, userID, database.AuditOutcomeSuccess))
+
+	// The job outlives the request, so it doesn't use the request's context.
+	go cfg.runExport(context.Background(), export)
+
+	w.Header().Set("Location", "/api/users/me/exports/"+export.ID.String())
+	cfg.respondWithExport(w, r, http.StatusAccepted, export)
+}
+
+func (cfg *apiConfig) handlerExportsList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	exports, err := cfg.db.GetExports(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve exports", err)
+		return
+	}
+
+	responses := []exportResponse{}
+	for _, export := range exports {
+		responses = append(responses, cfg.newExportResponse(r.Context(), export))
+	}
+	respondWithJSON(w, http.StatusOK, responses)
+}
+
+// handlerExportGet reports an export's status, with a fresh download link
+// once it is ready.
+func (cfg *apiConfig) handlerExportGet(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	export, err := cfg.db.GetExport(exportID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get export", err)
+		return
+	}
+	if export.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Export not found", nil)
+		return
+	}
+	if export.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't access this export", nil)
+		return
+	}
+
+	cfg.respondWithExport(w, r, http.StatusOK, export)
+}
+
+func (cfg *apiConfig) respondWithExport(w http.ResponseWriter, r *http.Request, code int, export database.Export) {
+	respondWithJSON(w, code, cfg.newExportResponse(r.Context(), export))
+}
+
+// newExportResponse adds a download link to ready exports that haven't
+// expired. A link that can't be signed is left out rather than failing the
+// request.
+func (cfg *apiConfig) newExportResponse(ctx context.Context, export database.Export) exportResponse {
+	response := exportResponse{Export: export}
+	if export.Status != database.ExportStatusReady || export.ObjectKey == nil {
+		return response
+	}
+	if export.ExpiresAt != nil && !time.Now().Before(*export.ExpiresAt) {
+		return response
+	}
+
+	url, expiresAt, err := cfg.presignExport(ctx, export)
+	if err != nil {
+		log.Printf("Couldn't sign download link for export %s: %v", export.ID, err)
+		return response
+	}
+	response.DownloadURL = &url
+	response.DownloadExpiresAt = &expiresAt
+	return response
+}
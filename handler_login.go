@@ -7,12 +7,15 @@ import (
 
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
+		// DeviceName optionally names the session in the list of sessions.
+		DeviceName string `json:"device_name"`
 	}
 	type response struct {
 		database.User
@@ -36,17 +39,23 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 
 	err = auth.CheckPasswordHash(params.Password, user.Password)
 	if err != nil {
+		if user.ID == uuid.Nil {
+			cfg.audit(r, database.CreateAuditEventParams{
+				Action:  auditUserLogin,
+				Outcome: database.AuditOutcomeFailure,
+				Detail:  "unknown email",
+			})
+		} else {
+			cfg.audit(r, userEvent(nil, auditUserLogin, user.ID, database.AuditOutcomeFailure))
+		}
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
-
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.jwtSecret,
-		time.Hour*24*30,
-	)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
+	if user.DisabledAt != nil {
+		event := userEvent(nil, auditUserLogin, user.ID, database.AuditOutcomeFailure)
+		event.Detail = "disabled account"
+		cfg.audit(r, event)
+		respondWithError(w, http.StatusForbidden, "This account has been disabled", nil)
 		return
 	}
 
@@ -56,16 +65,31 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.db.CreateRefreshToken(database.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
+	session, err := cfg.db.CreateRefreshToken(database.CreateRefreshTokenParams{
+		UserID:     user.ID,
+		Token:      refreshToken,
+		ExpiresAt:  time.Now().UTC().Add(refreshTokenLifetime),
+		DeviceName: sessionDeviceName(r, params.DeviceName),
+		UserAgent:  r.UserAgent(),
+		IP:         clientIP(r),
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
 		return
 	}
 
+	accessToken, err := auth.MakeAccessJWT(
+		auth.Identity{UserID: user.ID, SessionID: session.FamilyID, Role: user.Role},
+		cfg.jwtSecret,
+		time.Hour*24*30,
+	)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
+		return
+	}
+
+	cfg.audit(r, userEvent(&user.ID, auditUserLogin, user.ID, database.AuditOutcomeSuccess))
+
 	respondWithJSON(w, http.StatusOK, response{
 		User:         user,
 		Token:        accessToken,
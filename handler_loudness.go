@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func (cfg *apiConfig) handlerLoudnessReport(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		TargetLUFS  float64          `json:"target_lufs"`
+		ToleranceLU float64          `json:"tolerance_lu"`
+		Videos      []database.Video `json:"videos"`
+	}
+
+	userID, ok := cfg.authenticate(w, r, scopeVideosRead)
+	if !ok {
+		return
+	}
+
+	target, tolerance, err := parseLoudnessReportParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	videos, err := cfg.db.GetVideosOutsideLoudness(userID, target-tolerance, target+tolerance)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve videos", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		TargetLUFS:  target,
+		ToleranceLU: tolerance,
+		Videos:      videos,
+	})
+}
+
+// parseLoudnessReportParams reads the report's target and tolerance, falling
+// back to the EBU R128 defaults.
+func parseLoudnessReportParams(query url.Values) (target, tolerance float64, err error) {
+	target, tolerance = loudnessTargetLUFS, loudnessToleranceLU
+	if value := query.Get("target"); value != "" {
+		target, err = strconv.ParseFloat(value, 64)
+		// The range check also turns away NaN and infinities.
+		if err != nil || !(target >= minLoudnessTargetLUFS && target <= maxLoudnessTargetLUFS) {
+			return 0, 0, fmt.Errorf("target must be between %.0f and %.0f LUFS", minLoudnessTargetLUFS, maxLoudnessTargetLUFS)
+		}
+	}
+	if value := query.Get("tolerance"); value != "" {
+		tolerance, err = strconv.ParseFloat(value, 64)
+		if err != nil || !(tolerance >= 0) || math.IsInf(tolerance, 1) {
+			return 0, 0, errors.New("tolerance must be a non-negative number of LU")
+		}
+	}
+	return target, tolerance, nil
+}
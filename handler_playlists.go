@@ -0,0 +1,422 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxPlaylistTitleLength = 200
+
+func normalizePlaylistTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || utf8.RuneCountInString(title) > maxPlaylistTitleLength {
+		return "", fmt.Errorf("title must be between 1 and %d characters", maxPlaylistTitleLength)
+	}
+	return title, nil
+}
+
+func (cfg *apiConfig) handlerPlaylistCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	title, err := normalizePlaylistTitle(params.Title)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	playlist, err := cfg.db.CreatePlaylist(database.CreatePlaylistParams{
+		Title:       title,
+		Description: params.Description,
+		UserID:      userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create playlist", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, playlist)
+}
+
+func (cfg *apiConfig) handlerPlaylistsList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	playlists, err := cfg.db.GetPlaylists(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve playlists", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, playlists)
+}
+
+func (cfg *apiConfig) handlerPlaylistGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	playlist, ok := cfg.ownedPlaylist(w, r, userID)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, playlist)
+}
+
+func (cfg *apiConfig) handlerPlaylistUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	playlist, ok := cfg.ownedPlaylist(w, r, userID)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Title != nil {
+		title, err := normalizePlaylistTitle(*params.Title)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		playlist.Title = title
+	}
+	if params.Description != nil {
+		playlist.Description = *params.Description
+	}
+
+	err = cfg.db.UpdatePlaylist(playlist)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update playlist", err)
+		return
+	}
+
+	cfg.respondWithPlaylist(w, playlist.ID, http.StatusOK)
+}
+
+func (cfg *apiConfig) handlerPlaylistDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	playlist, ok := cfg.ownedPlaylist(w, r, userID)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.DeletePlaylist(playlist.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete playlist", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerPlaylistItemAdd appends a video to the playlist, or inserts it at
+// position when one is given.
+func (cfg *apiConfig) handlerPlaylistItemAdd(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		VideoID  uuid.UUID `json:"video_id"`
+		Position *int      `json:"position"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	playlist, ok := cfg.ownedPlaylist(w, r, userID)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Position != nil && *params.Position < 0 {
+		respondWithError(w, http.StatusBadRequest, "Position can't be negative", nil)
+		return
+	}
+
+	video, err := cfg.db.GetVideo(params.VideoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return
+	}
+	if video.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't add this video to a playlist", nil)
+		return
+	}
+
+	item, err := cfg.db.AddPlaylistItem(playlist.ID, video.ID)
+	if errors.Is(err, database.ErrVideoInPlaylist) {
+		respondWithError(w, http.StatusConflict, "Video is already in the playlist", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't add video to playlist", err)
+		return
+	}
+
+	if params.Position != nil {
+		err = cfg.db.MovePlaylistItem(item.ID, *params.Position)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't move playlist item", err)
+			return
+		}
+	}
+
+	cfg.respondWithPlaylist(w, playlist.ID, http.StatusCreated)
+}
+
+func (cfg *apiConfig) handlerPlaylistItemDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	playlist, ok := cfg.ownedPlaylist(w, r, userID)
+	if !ok {
+		return
+	}
+	item, ok := cfg.playlistItem(w, r, playlist)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.RemovePlaylistItem(item.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove playlist item", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerPlaylistItemMove(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Position int `json:"position"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	playlist, ok := cfg.ownedPlaylist(w, r, userID)
+	if !ok {
+		return
+	}
+	item, ok := cfg.playlistItem(w, r, playlist)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Position < 0 {
+		respondWithError(w, http.StatusBadRequest, "Position can't be negative", nil)
+		return
+	}
+
+	err = cfg.db.MovePlaylistItem(item.ID, params.Position)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't move playlist item", err)
+		return
+	}
+
+	cfg.respondWithPlaylist(w, playlist.ID, http.StatusOK)
+}
+
+// handlerPlaylistReorder replaces the order of the whole playlist. The body
+// must list every item ID exactly once.
+func (cfg *apiConfig) handlerPlaylistReorder(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ItemIDs []uuid.UUID `json:"item_ids"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	playlist, ok := cfg.ownedPlaylist(w, r, userID)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	err = cfg.db.ReorderPlaylist(playlist.ID, params.ItemIDs)
+	if errors.Is(err, database.ErrInvalidOrder) {
+		respondWithError(w, http.StatusBadRequest, "item_ids must list every item of the playlist exactly once", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reorder playlist", err)
+		return
+	}
+
+	cfg.respondWithPlaylist(w, playlist.ID, http.StatusOK)
+}
+
+func (cfg *apiConfig) handlerPlaylistM3U(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := cfg.feedPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", m3uContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(playlistM3U(playlist)))
+}
+
+func (cfg *apiConfig) handlerPlaylistJSONFeed(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := cfg.feedPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, playlistJSONFeed(playlist))
+}
+
+// feedPlaylist loads the playlist named in the path for its feeds, which
+// players and feed readers fetch without credentials. Like an unlisted
+// video, anyone with the playlist's ID can read them, but only the owner,
+// identified by a JWT or an API key, sees the private videos in it. If the
+// playlist can't be loaded, it writes the error response and returns false.
+func (cfg *apiConfig) feedPlaylist(w http.ResponseWriter, r *http.Request) (database.Playlist, bool) {
+	playlistID, err := uuid.Parse(r.PathValue("playlistID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid playlist ID", err)
+		return database.Playlist{}, false
+	}
+
+	playlist, err := cfg.db.GetPlaylist(playlistID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist", err)
+		return database.Playlist{}, false
+	}
+	if playlist.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Playlist not found", nil)
+		return database.Playlist{}, false
+	}
+	if cfg.viewer(r, scopeVideosRead) != playlist.UserID {
+		playlist = withoutPrivateVideos(playlist)
+	}
+	return playlist, true
+}
+
+// ownedPlaylist loads the playlist named in the path and checks that
+// userID owns it. If not, it writes the error response and returns false.
+func (cfg *apiConfig) ownedPlaylist(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Playlist, bool) {
+	playlistID, err := uuid.Parse(r.PathValue("playlistID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid playlist ID", err)
+		return database.Playlist{}, false
+	}
+
+	playlist, err := cfg.db.GetPlaylist(playlistID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist", err)
+		return database.Playlist{}, false
+	}
+	if playlist.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Playlist not found", nil)
+		return database.Playlist{}, false
+	}
+	if playlist.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't access this playlist", nil)
+		return database.Playlist{}, false
+	}
+	return playlist, true
+}
+
+// playlistItem loads the item named in the path and checks that it belongs
+// to playlist. If not, it writes the error response and returns false.
+func (cfg *apiConfig) playlistItem(w http.ResponseWriter, r *http.Request, playlist database.Playlist) (database.PlaylistItem, bool) {
+	itemID, err := uuid.Parse(r.PathValue("itemID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid item ID", err)
+		return database.PlaylistItem{}, false
+	}
+
+	item, err := cfg.db.GetPlaylistItem(itemID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist item", err)
+		return database.PlaylistItem{}, false
+	}
+	if item.ID == uuid.Nil || item.PlaylistID != playlist.ID {
+		respondWithError(w, http.StatusNotFound, "Playlist item not found", nil)
+		return database.PlaylistItem{}, false
+	}
+	return item, true
+}
+
+func (cfg *apiConfig) respondWithPlaylist(w http.ResponseWriter, playlistID uuid.UUID, code int) {
+	playlist, err := cfg.db.GetPlaylist(playlistID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get playlist", err)
+		return
+	}
+	respondWithJSON(w, code, playlist)
+}
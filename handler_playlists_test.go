@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// TestPlaylistFeedVisibility checks that the feeds, which are fetched
+// without credentials, only show private videos to the owner.
+func TestPlaylistFeedVisibility(t *testing.T) {
+	cfg := newTestConfig(t)
+	owner := testUser(t, cfg, "owner@example.com")
+	_, ownerToken := testLogin(t, cfg, owner)
+	_, otherToken := testLogin(t, cfg, testUser(t, cfg, "other@example.com"))
+
+	playlist, err := cfg.db.CreatePlaylist(database.CreatePlaylistParams{Title: "Mix", UserID: owner.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, params := range []database.CreateVideoParams{
+		{Title: "Secret", UserID: owner.ID, Visibility: database.VisibilityPrivate},
+		{Title: "Shared", UserID: owner.ID, Visibility: database.VisibilityUnlisted},
+	} {
+		video, err := cfg.db.CreateVideo(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		video.VideoURL = ptr("https://cdn.example.com/" + video.ID.String() + ".mp4")
+		err = cfg.db.UpdateVideo(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cfg.db.AddPlaylistItem(playlist.ID, video.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantSecret bool
+	}{
+		{name: "anonymous"},
+		{name: "another user", token: otherToken},
+		{name: "owner", token: ownerToken, wantSecret: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, feed := range []struct {
+				path    string
+				handler http.HandlerFunc
+			}{
+				{"playlist.m3u", cfg.handlerPlaylistM3U},
+				{"feed.json", cfg.handlerPlaylistJSONFeed},
+			} {
+				req := httptest.NewRequest(http.MethodGet, "/api/playlists/"+playlist.ID.String()+"/"+feed.path, nil)
+				if tt.token != "" {
+					req.Header.Set("Authorization", "Bearer "+tt.token)
+				}
+				req.SetPathValue("playlistID", playlist.ID.String())
+				rec := httptest.NewRecorder()
+				feed.handler(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("%s: got status %d: %s", feed.path, rec.Code, rec.Body)
+				}
+
+				body := rec.Body.String()
+				if !strings.Contains(body, "Shared") {
+					t.Errorf("%s doesn't list the unlisted video", feed.path)
+				}
+				if strings.Contains(body, "Secret") != tt.wantSecret {
+					t.Errorf("%s lists the private video: %v, want %v", feed.path, !tt.wantSecret, tt.wantSecret)
+				}
+			}
+		})
+	}
+}
@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
 )
 
+const (
+	// refreshTokenLifetime is how long a refresh token can be used. Each
+	// refresh issues a new token with the full lifetime.
+	refreshTokenLifetime        = 60 * 24 * time.Hour
+	refreshTokenCleanupInterval = time.Hour
+)
+
+// handlerRefresh exchanges a refresh token for an access token and a new
+// refresh token. The old refresh token stops working; presenting it again
+// revokes every token descended from the same login.
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
@@ -18,14 +34,74 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.db.GetUserByRefreshToken(refreshToken)
+	rt, err := cfg.db.GetRefreshToken(refreshToken)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get session", err)
+		return
+	}
+	rejected := ""
+	switch {
+	case rt.TokenHash == "":
+		rejected = "unknown token"
+	case rt.RotatedAt != nil:
+		cfg.revokeReusedRefreshToken(w, r, rt)
+		return
+	case rt.RevokedAt != nil:
+		rejected = "revoked token"
+	case !time.Now().Before(rt.ExpiresAt):
+		rejected = "expired token"
+	}
+	if rejected != "" {
+		event := database.CreateAuditEventParams{
+			Action:  auditTokenRefresh,
+			Outcome: database.AuditOutcomeFailure,
+			Detail:  rejected,
+		}
+		if rt.TokenHash != "" {
+			event = userEvent(&rt.UserID, auditTokenRefresh, rt.UserID, database.AuditOutcomeFailure)
+			event.Detail = rejected
+		}
+		cfg.audit(r, event)
+		respondWithError(w, http.StatusUnauthorized, "Refresh token is invalid, revoked or expired", nil)
+		return
+	}
+
+	// The new access token carries the user's current role.
+	user, err := cfg.db.GetUser(rt.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	if user == nil || user.DisabledAt != nil {
+		respondWithError(w, http.StatusUnauthorized, "Refresh token is invalid, revoked or expired", nil)
+		return
+	}
+
+	nextToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		return
+	}
+	_, err = cfg.db.RotateRefreshToken(refreshToken, database.CreateRefreshTokenParams{
+		Token:     nextToken,
+		UserID:    rt.UserID,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenLifetime),
+		FamilyID:  rt.FamilyID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	})
+	if errors.Is(err, database.ErrRefreshTokenRotated) {
+		// Another request rotated the token between the lookup and now.
+		cfg.revokeReusedRefreshToken(w, r, rt)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get user for refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rotate refresh token", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(
-		user.ID,
+	accessToken, err := auth.MakeAccessJWT(
+		auth.Identity{UserID: rt.UserID, SessionID: rt.FamilyID, Role: user.Role},
 		cfg.jwtSecret,
 		time.Hour,
 	)
@@ -34,11 +110,32 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg.audit(r, userEvent(&rt.UserID, auditTokenRefresh, rt.UserID, database.AuditOutcomeSuccess))
+
 	respondWithJSON(w, http.StatusOK, response{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: nextToken,
 	})
 }
 
+// revokeReusedRefreshToken handles a refresh token that was used after it
+// had been rotated. Either the client or an attacker holds a stolen copy,
+// and there's no telling which, so the whole family is revoked and both
+// have to log in again.
+func (cfg *apiConfig) revokeReusedRefreshToken(w http.ResponseWriter, r *http.Request, rt database.RefreshToken) {
+	err := cfg.db.RevokeRefreshTokenFamily(rt.FamilyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	event := userEvent(&rt.UserID, auditTokenReuse, rt.UserID, database.AuditOutcomeFailure)
+	event.Detail = "family " + rt.FamilyID.String() + " revoked"
+	cfg.audit(r, event)
+
+	respondWithError(w, http.StatusUnauthorized, "Refresh token is invalid, revoked or expired", nil)
+}
+
 func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -46,11 +143,48 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rt, err := cfg.db.GetRefreshToken(refreshToken)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get session", err)
+		return
+	}
+
 	err = cfg.db.RevokeRefreshToken(refreshToken)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
 		return
 	}
 
+	if rt.UserID == uuid.Nil {
+		cfg.audit(r, database.CreateAuditEventParams{
+			Action:  auditTokenRevoke,
+			Outcome: database.AuditOutcomeFailure,
+			Detail:  "unknown token",
+		})
+	} else {
+		cfg.audit(r, userEvent(&rt.UserID, auditTokenRevoke, rt.UserID, database.AuditOutcomeSuccess))
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// runRefreshTokenCleaner deletes expired and revoked refresh tokens now and
+// then every refreshTokenCleanupInterval until ctx is done.
+func (cfg *apiConfig) runRefreshTokenCleaner(ctx context.Context) {
+	ticker := time.NewTicker(refreshTokenCleanupInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := cfg.db.DeleteStaleRefreshTokens(time.Now())
+		if err != nil {
+			log.Printf("Error deleting stale refresh tokens: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired or revoked refresh tokens", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// refresh calls handlerRefresh with token and returns the response and the
+// refresh token it issued, if any.
+func refresh(t *testing.T, cfg *apiConfig, token string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	cfg.handlerRefresh(rec, req)
+	if rec.Code != http.StatusOK {
+		return rec, ""
+	}
+	var body struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	err := json.Unmarshal(rec.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.Token == "" || body.RefreshToken == "" || body.RefreshToken == token {
+		t.Fatalf("unexpected response %s", rec.Body)
+	}
+	return rec, body.RefreshToken
+}
+
+func TestHandlerRefresh(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	// Each setup returns the refresh token to present.
+	newToken := func(t *testing.T, user *database.User, expiresAt time.Time) string {
+		token, err := auth.MakeRefreshToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cfg.db.CreateRefreshToken(database.CreateRefreshTokenParams{Token: token, UserID: user.ID, ExpiresAt: expiresAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, user *database.User) string
+		wantStatus int
+	}{
+		{
+			name: "valid",
+			setup: func(t *testing.T, user *database.User) string {
+				return newToken(t, user, time.Now().Add(time.Hour))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "expired",
+			setup: func(t *testing.T, user *database.User) string {
+				return newToken(t, user, time.Now().Add(-time.Minute))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "revoked",
+			setup: func(t *testing.T, user *database.User) string {
+				token := newToken(t, user, time.Now().Add(time.Hour))
+				err := cfg.db.RevokeRefreshToken(token)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown",
+			setup: func(t *testing.T, user *database.User) string {
+				token, err := auth.MakeRefreshToken()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "disabled user",
+			setup: func(t *testing.T, user *database.User) string {
+				token := newToken(t, user, time.Now().Add(time.Hour))
+				err := cfg.db.DisableUser(user.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "no token",
+			setup:      func(t *testing.T, user *database.User) string { return "" },
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testUser(t, cfg, tt.name+"@example.com")
+			rec, _ := refresh(t, cfg, tt.setup(t, user))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+// TestRefreshTokenReuse checks that presenting a rotated refresh token
+// revokes its whole family, including the token it was rotated into and the
+// session's access tokens.
+func TestRefreshTokenReuse(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := testUser(t, cfg, "reuse@example.com")
+	other := testUser(t, cfg, "other@example.com")
+
+	first, err := auth.MakeRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := cfg.db.CreateRefreshToken(database.CreateRefreshTokenParams{Token: first, UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err := auth.MakeAccessJWT(auth.Identity{UserID: user.ID, SessionID: session.FamilyID}, cfg.jwtSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another login of the same user isn't affected.
+	_, otherAccessToken := testLogin(t, cfg, user)
+	_, unrelatedAccessToken := testLogin(t, cfg, other)
+
+	rec, second := refresh(t, cfg, first)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first refresh: got status %d: %s", rec.Code, rec.Body)
+	}
+	rec, third := refresh(t, cfg, second)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("second refresh: got status %d: %s", rec.Code, rec.Body)
+	}
+
+	// The stolen first token comes back.
+	rec, _ = refresh(t, cfg, first)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused token: got status %d, want 401", rec.Code)
+	}
+
+	tests := []struct {
+		name        string
+		accessToken string
+		refreshWith string
+		wantStatus  int
+	}{
+		{name: "latest token of the family", accessToken: accessToken, refreshWith: third, wantStatus: http.StatusUnauthorized},
+		{name: "same user's other session", accessToken: otherAccessToken, wantStatus: http.StatusOK},
+		{name: "other user", accessToken: unrelatedAccessToken, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			cfg.handlerSessionsList(rec, authorizedRequest(http.MethodGet, "/api/sessions", tt.accessToken, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("access token: got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.refreshWith != "" {
+				rec, _ := refresh(t, cfg, tt.refreshWith)
+				if rec.Code != tt.wantStatus {
+					t.Errorf("refresh token: got status %d, want %d", rec.Code, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
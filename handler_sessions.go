@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxDeviceNameLength = 100
+
+type sessionResponse struct {
+	database.Session
+	// Current is set on the session the request's access token belongs to.
+	Current bool `json:"current"`
+}
+
+// handlerSessionsList lists the devices the user is logged in on.
+func (cfg *apiConfig) handlerSessionsList(w http.ResponseWriter, r *http.Request) {
+	identity, ok := cfg.authenticateSession(w, r)
+	if !ok {
+		return
+	}
+	userID, sessionID := identity.UserID, identity.SessionID
+
+	sessions, err := cfg.db.GetSessions(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve sessions", err)
+		return
+	}
+
+	responses := []sessionResponse{}
+	for _, session := range sessions {
+		responses = append(responses, sessionResponse{
+			Session: session,
+			Current: sessionID != uuid.Nil && session.ID == sessionID,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, responses)
+}
+
+// handlerSessionRevoke logs one of the user's devices out. Its refresh
+// token and the access tokens already issued to it stop working.
+func (cfg *apiConfig) handlerSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := cfg.db.GetSession(sessionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get session", err)
+		return
+	}
+	if session.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Session not found", nil)
+		return
+	}
+	if session.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't revoke this session", nil)
+		return
+	}
+
+	err = cfg.db.RevokeRefreshTokenFamily(session.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+	cfg.audit(r, database.CreateAuditEventParams{
+		ActorID:    &userID,
+		Action:     auditSessionRevoke,
+		TargetType: auditTargetSession,
+		TargetID:   session.ID.String(),
+		Outcome:    database.AuditOutcomeSuccess,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerSessionsRevokeOthers logs the user out everywhere except the
+// session the request's access token belongs to.
+func (cfg *apiConfig) handlerSessionsRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Revoked int `json:"revoked"`
+	}
+
+	identity, ok := cfg.authenticateSession(w, r)
+	if !ok {
+		return
+	}
+	userID, sessionID := identity.UserID, identity.SessionID
+	if sessionID == uuid.Nil {
+		respondWithError(w, http.StatusBadRequest, "Access token doesn't belong to a session; log in again", nil)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeOtherSessions(userID, sessionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+	event := userEvent(&userID, auditSessionRevoke, userID, database.AuditOutcomeSuccess)
+	event.Detail = fmt.Sprintf("%d other sessions revoked", revoked)
+	cfg.audit(r, event)
+
+	respondWithJSON(w, http.StatusOK, response{Revoked: revoked})
+}
+
+// sessionDeviceName is the name a new session is listed under: the one the
+// client asked for, or else one made up from its User-Agent.
+func sessionDeviceName(r *http.Request, requested string) string {
+	name := strings.TrimSpace(requested)
+	if name == "" {
+		name = describeUserAgent(r.UserAgent())
+	}
+	if len(name) > maxDeviceNameLength {
+		name = strings.ToValidUTF8(name[:maxDeviceNameLength], "")
+	}
+	return name
+}
+
+// describeUserAgent turns a User-Agent into something like "Firefox on
+// Windows". Only common browsers and platforms are recognized.
+func describeUserAgent(userAgent string) string {
+	browser := ""
+	// Order matters: most browsers also claim to be the ones they're built
+	// on.
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	platform := ""
+	for _, p := range []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"CrOS", "ChromeOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
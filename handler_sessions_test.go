@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestRevokedSessionAccessToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// logOut ends sessions after the access token was issued.
+		logOut func(t *testing.T, cfg *apiConfig, user *database.User, session database.RefreshToken, otherToken string)
+		want   int
+	}{
+		{
+			name:   "active session",
+			logOut: func(*testing.T, *apiConfig, *database.User, database.RefreshToken, string) {},
+			want:   http.StatusOK,
+		},
+		{
+			name: "session revoked from another device",
+			logOut: func(t *testing.T, cfg *apiConfig, _ *database.User, session database.RefreshToken, otherToken string) {
+				req := httptest.NewRequest(http.MethodDelete, "/api/sessions/"+session.FamilyID.String(), nil)
+				req.SetPathValue("sessionID", session.FamilyID.String())
+				req.Header.Set("Authorization", "Bearer "+otherToken)
+				rec := httptest.NewRecorder()
+				cfg.handlerSessionRevoke(rec, req)
+				if rec.Code != http.StatusNoContent {
+					t.Fatalf("revoking session: got status %d: %s", rec.Code, rec.Body)
+				}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "other sessions revoked",
+			logOut: func(t *testing.T, cfg *apiConfig, user *database.User, _ database.RefreshToken, _ string) {
+				_, err := cfg.db.RevokeOtherSessions(user.ID, uuid.New())
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "refresh token reused",
+			logOut: func(t *testing.T, cfg *apiConfig, _ *database.User, session database.RefreshToken, _ string) {
+				err := cfg.db.RevokeRefreshTokenFamily(session.FamilyID)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			// Disabling a user also logs them out.
+			name: "user disabled",
+			logOut: func(t *testing.T, cfg *apiConfig, user *database.User, _ database.RefreshToken, _ string) {
+				err := cfg.db.DisableUser(user.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			user := testUser(t, cfg, "user@example.com")
+			session, accessToken := testLogin(t, cfg, user)
+			_, otherToken := testLogin(t, cfg, user)
+
+			tt.logOut(t, cfg, user, session, otherToken)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/sessions", nil)
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			rec := httptest.NewRecorder()
+			cfg.handlerSessionsList(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+
+			// Routes anyone can call treat the token as no credentials.
+			req = httptest.NewRequest(http.MethodGet, "/api/catalog", nil)
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			viewer := cfg.viewer(req, scopeVideosRead)
+			if active := tt.want == http.StatusOK; (viewer == user.ID) != active {
+				t.Errorf("viewer = %s, want the user: %v", viewer, active)
+			}
+		})
+	}
+}
+
+func TestDescribeUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			want:      "Firefox on Windows",
+		},
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0",
+			want:      "Edge on Windows",
+		},
+		{
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want:      "Chrome on macOS",
+		},
+		{
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			want:      "Safari on iPhone",
+		},
+		{
+			userAgent: "Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36 OPR/80.0.0.0",
+			want:      "Opera on Android",
+		},
+		{userAgent: "curl/8.5.0", want: "curl"},
+		{userAgent: "Mozilla/5.0 (X11; Linux x86_64)", want: "Linux"},
+		{userAgent: "", want: "Unknown device"},
+		{userAgent: "tubely-cli/1.0", want: "Unknown device"},
+	}
+	for _, tt := range tests {
+		if got := describeUserAgent(tt.userAgent); got != tt.want {
+			t.Errorf("describeUserAgent(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestSessionDeviceName(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		want      string
+	}{
+		{name: "requested", requested: "  Work laptop ", want: "Work laptop"},
+		{name: "from the User-Agent", requested: "", want: "curl"},
+		{name: "blank", requested: "   ", want: "curl"},
+		{name: "too long", requested: strings.Repeat("a", maxDeviceNameLength+10), want: strings.Repeat("a", maxDeviceNameLength)},
+		// Cutting through a character drops the partial character.
+		{name: "too long mid-character", requested: "a" + strings.Repeat("é", maxDeviceNameLength), want: "a" + strings.Repeat("é", (maxDeviceNameLength-1)/2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+			req.Header.Set("User-Agent", "curl/8.5.0")
+			got := sessionDeviceName(req, tt.requested)
+			if got != tt.want || !utf8.ValidString(got) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerSessions(t *testing.T) {
+	cfg := newTestConfig(t)
+	user := testUser(t, cfg, "user@example.com")
+	current, accessToken := testLogin(t, cfg, user)
+	other, _ := testLogin(t, cfg, user)
+	stranger, _ := testLogin(t, cfg, testUser(t, cfg, "stranger@example.com"))
+
+	rec := httptest.NewRecorder()
+	cfg.handlerSessionsList(rec, authorizedRequest(http.MethodGet, "/api/sessions", accessToken, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var sessions []sessionResponse
+	err := json.Unmarshal(rec.Body.Bytes(), &sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.Current != (session.ID == current.FamilyID) {
+			t.Errorf("session %s has current %v", session.DeviceName, session.Current)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		sessionID  string
+		wantStatus int
+	}{
+		{name: "invalid ID", sessionID: "abc", wantStatus: http.StatusBadRequest},
+		{name: "unknown session", sessionID: uuid.NewString(), wantStatus: http.StatusNotFound},
+		{name: "someone else's session", sessionID: stranger.FamilyID.String(), wantStatus: http.StatusForbidden},
+		{name: "own other session", sessionID: other.FamilyID.String(), wantStatus: http.StatusNoContent},
+		{name: "already revoked", sessionID: other.FamilyID.String(), wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := authorizedRequest(http.MethodDelete, "/api/sessions/"+tt.sessionID, accessToken, nil)
+			req.SetPathValue("sessionID", tt.sessionID)
+			rec := httptest.NewRecorder()
+			cfg.handlerSessionRevoke(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	session, err := cfg.db.GetSession(stranger.FamilyID)
+	if err != nil || session.ID != stranger.FamilyID {
+		t.Errorf("someone else's session was revoked: %v", err)
+	}
+}
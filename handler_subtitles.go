@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/subtitles"
+	"github.com/google/uuid"
+)
+
+const maxSubtitleLabelLength = 100
+
+var languageCodeRegexp = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+func (cfg *apiConfig) handlerSubtitleUpload(w http.ResponseWriter, r *http.Request) {
+	const maxMemory = 5 << 20 // 5 MB
+
+	videoIDString := r.PathValue("videoID")
+	videoID, err := uuid.Parse(videoIDString)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticate(w, r, scopeUpload)
+	if !ok {
+		return
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return
+	}
+	if video.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't add subtitles to this video", nil)
+		return
+	}
+	if !checkIfMatch(w, r, video) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMemory)
+	err = r.ParseMultipartForm(maxMemory)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error parsing form", err)
+		return
+	}
+
+	language := strings.TrimSpace(r.FormValue("language"))
+	if !languageCodeRegexp.MatchString(language) {
+		respondWithError(w, http.StatusBadRequest, "language must be a BCP 47 language code such as \"en\" or \"pt-BR\"", nil)
+		return
+	}
+	label := strings.TrimSpace(r.FormValue("label"))
+	if label == "" {
+		label = language
+	}
+	if utf8.RuneCountInString(label) > maxSubtitleLabelLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("label must be at most %d characters", maxSubtitleLabelLength), nil)
+		return
+	}
+
+	file, _, err := r.FormFile("subtitle")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error reading subtitle file", err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error reading subtitle file", err)
+		return
+	}
+
+	cues, err := subtitles.Parse(data)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid subtitle file: %v", err), err)
+		return
+	}
+
+	var vtt bytes.Buffer
+	err = subtitles.WriteVTT(&vtt, cues)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't convert subtitles to WebVTT", err)
+		return
+	}
+
+	fileName, err := randomAssetName()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating random data", err)
+		return
+	}
+	vttKey := fmt.Sprintf("subtitles/%s/%s.vtt", videoID, fileName)
+	playlistKey := fmt.Sprintf("subtitles/%s/%s.m3u8", videoID, fileName)
+
+	uow, err := cfg.db.BeginTx(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting upload", err)
+		return
+	}
+	defer rollback(uow)
+
+	err = cfg.stageObject(r.Context(), uow, vttKey, &vtt, "text/vtt")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error uploading subtitles to S3", err)
+		return
+	}
+	playlist := subtitlePlaylist(cfg.objectURL(vttKey), subtitles.Duration(cues))
+	err = cfg.stageObject(r.Context(), uow, playlistKey, strings.NewReader(playlist), hlsContentType)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error uploading subtitle playlist to S3", err)
+		return
+	}
+
+	// The master playlist is rewritten in place, so undoing means writing
+	// it again with the tracks the video had before. It is written before
+	// the track is recorded, so the transaction isn't held open across S3
+	// requests.
+	params := database.CreateSubtitleTrackParams{
+		VideoID:  videoID,
+		Language: language,
+		Label:    label,
+		URL:      cfg.objectURL(vttKey),
+	}
+	before := video
+	video.SubtitleTracks = append(slices.Clip(video.SubtitleTracks), database.SubtitleTrack{CreateSubtitleTrackParams: params})
+	err = cfg.syncHLSSubtitles(r.Context(), video)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't add subtitles to HLS playlist", err)
+		return
+	}
+	uow.Compensate(func() error {
+		return cfg.syncHLSSubtitles(context.WithoutCancel(r.Context()), before)
+	})
+
+	track, err := uow.CreateSubtitleTrack(params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save subtitle track", err)
+		return
+	}
+	err = uow.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save subtitle track", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, track)
+}
+
+func (cfg *apiConfig) handlerSubtitlesList(w http.ResponseWriter, r *http.Request) {
+	video, ok := cfg.viewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, video.SubtitleTracks)
+}
+
+func (cfg *apiConfig) handlerSubtitleDelete(w http.ResponseWriter, r *http.Request) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return
+	}
+	trackID, err := uuid.Parse(r.PathValue("trackID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid track ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticate(w, r, scopeVideosWrite)
+	if !ok {
+		return
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return
+	}
+	if video.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't delete subtitles from this video", nil)
+		return
+	}
+	if !checkIfMatch(w, r, video) {
+		return
+	}
+
+	track, err := cfg.db.GetSubtitleTrack(trackID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get subtitle track", err)
+		return
+	}
+	if track.ID == uuid.Nil || track.VideoID != videoID {
+		respondWithError(w, http.StatusNotFound, "Subtitle track not found", nil)
+		return
+	}
+
+	uow, err := cfg.db.BeginTx(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete subtitle track", err)
+		return
+	}
+	defer rollback(uow)
+
+	// As with uploads, the master playlist is rewritten before the row is
+	// touched, and written back with the track if the delete fails.
+	before := video
+	remaining := []database.SubtitleTrack{}
+	for _, t := range video.SubtitleTracks {
+		if t.ID != trackID {
+			remaining = append(remaining, t)
+		}
+	}
+	video.SubtitleTracks = remaining
+	err = cfg.syncHLSSubtitles(r.Context(), video)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove subtitles from HLS playlist", err)
+		return
+	}
+	uow.Compensate(func() error {
+		return cfg.syncHLSSubtitles(context.WithoutCancel(r.Context()), before)
+	})
+
+	err = uow.DeleteSubtitleTrack(trackID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete subtitle track", err)
+		return
+	}
+	err = uow.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete subtitle track", err)
+		return
+	}
+
+	cfg.deleteSubtitleObjects(r.Context(), track)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteSubtitleObjects removes a track's WebVTT file and its HLS wrapper.
+// Failures are only logged: the track row is already gone.
+func (cfg *apiConfig) deleteSubtitleObjects(ctx context.Context, track database.SubtitleTrack) {
+	vttKey, ok := cfg.objectKey(track.URL)
+	if !ok {
+		return
+	}
+	playlistKey := strings.TrimSuffix(vttKey, ".vtt") + ".m3u8"
+	for _, key := range []string{vttKey, playlistKey} {
+		if err := cfg.deleteObject(ctx, key); err != nil {
+			log.Printf("Couldn't delete subtitle object %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func TestHandlerVideoTagsAdd(t *testing.T) {
+	manyTags := []string{}
+	for i := range maxTagsPerVideo {
+		manyTags = append(manyTags, fmt.Sprintf("tag %d", i))
+	}
+
+	tests := []struct {
+		name     string
+		existing []string
+		body     string
+		byOwner  bool
+		want     int
+		wantTags []string
+	}{
+		{
+			name:     "adds normalized tags",
+			body:     `{"tags": ["Cooking  Tips", "pasta"]}`,
+			byOwner:  true,
+			want:     http.StatusOK,
+			wantTags: []string{"cooking tips", "pasta"},
+		},
+		{
+			name:     "merges with existing tags",
+			existing: []string{"pasta"},
+			body:     `{"tags": ["PASTA", "dinner"]}`,
+			byOwner:  true,
+			want:     http.StatusOK,
+			wantTags: []string{"dinner", "pasta"},
+		},
+		{
+			name:     "at the limit with duplicates",
+			existing: manyTags,
+			body:     `{"tags": ["Tag 0"]}`,
+			byOwner:  true,
+			want:     http.StatusOK,
+		},
+		{
+			name:     "over the limit",
+			existing: manyTags,
+			body:     `{"tags": ["one too many"]}`,
+			byOwner:  true,
+			want:     http.StatusBadRequest,
+		},
+		{
+			name:    "no tags",
+			body:    `{"tags": []}`,
+			byOwner: true,
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "empty tag",
+			body:    `{"tags": ["ok", "  "]}`,
+			byOwner: true,
+			want:    http.StatusBadRequest,
+		},
+		{
+			name: "someone else's video",
+			body: `{"tags": ["mine"]}`,
+			want: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			owner := testUser(t, cfg, "owner@example.com")
+			_, ownerToken := testLogin(t, cfg, owner)
+			_, otherToken := testLogin(t, cfg, testUser(t, cfg, "other@example.com"))
+
+			video, err := cfg.db.CreateVideo(database.CreateVideoParams{Title: "Pasta", UserID: owner.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.existing) > 0 {
+				err = cfg.db.AddVideoTags(video.ID, owner.ID, tt.existing)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			token := otherToken
+			if tt.byOwner {
+				token = ownerToken
+			}
+			req := authorizedRequest(http.MethodPost, "/api/videos/"+video.ID.String()+"/tags", token, strings.NewReader(tt.body))
+			req.SetPathValue("videoID", video.ID.String())
+			rec := httptest.NewRecorder()
+			cfg.handlerVideoTagsAdd(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.wantTags == nil {
+				return
+			}
+
+			var got database.Video
+			err = json.NewDecoder(rec.Body).Decode(&got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(got.Tags)
+			if !slices.Equal(got.Tags, tt.wantTags) {
+				t.Errorf("got tags %q, want %q", got.Tags, tt.wantTags)
+			}
+		})
+	}
+}
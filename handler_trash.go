@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerTrashList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	videos, err := cfg.db.GetDeletedVideos(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve trash", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, videos)
+}
+
+func (cfg *apiConfig) handlerTrashRestore(w http.ResponseWriter, r *http.Request) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	video, err := cfg.db.GetDeletedVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found in trash", nil)
+		return
+	}
+	if video.UserID != userID {
+		cfg.audit(r, videoEvent(userID, auditVideoRestore, videoID, database.AuditOutcomeFailure))
+		respondWithError(w, http.StatusForbidden, "You can't restore this video", nil)
+		return
+	}
+	if !checkIfMatch(w, r, video) {
+		return
+	}
+
+	err = cfg.db.RestoreVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore video", err)
+		return
+	}
+
+	cfg.audit(r, videoEvent(userID, auditVideoRestore, videoID, database.AuditOutcomeSuccess))
+
+	video, err = cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	respondWithVideo(w, http.StatusOK, video)
+}
+
+// handlerTrashPurge permanently deletes a trashed video without waiting for
+// the retention window.
+func (cfg *apiConfig) handlerTrashPurge(w http.ResponseWriter, r *http.Request) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	video, err := cfg.db.GetDeletedVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found in trash", nil)
+		return
+	}
+	if video.UserID != userID {
+		cfg.audit(r, videoEvent(userID, auditVideoPurge, videoID, database.AuditOutcomeFailure))
+		respondWithError(w, http.StatusForbidden, "You can't delete this video", nil)
+		return
+	}
+
+	err = cfg.purgeVideo(r.Context(), video)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete video", err)
+		return
+	}
+
+	cfg.audit(r, videoEvent(userID, auditVideoPurge, videoID, database.AuditOutcomeSuccess))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"io"
@@ -10,7 +11,7 @@ import (
 	"encoding/base64"
 	"crypto/rand"
 
-	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
 	"github.com/google/uuid"
 )
 
@@ -22,18 +23,30 @@ func (cfg *apiConfig) handlerUploadThumbnail(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+	userID, ok := cfg.authenticate(w, r, scopeUpload)
+	if !ok {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+
+	videoMetadata, err := cfg.db.GetVideo(videoID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting video metadata", err)
+		return
+	}
+	if videoMetadata.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
 		return
 	}
 
+	if userID != videoMetadata.UserID {
+		cfg.audit(r, videoEvent(userID, auditThumbnailUpload, videoID, database.AuditOutcomeFailure))
+		respondWithError(w, http.StatusUnauthorized, "User does not have permission to upload thumbnail for this video", nil)
+		return
+	}
+	if !checkIfMatch(w, r, videoMetadata) {
+		return
+	}
 
 	fmt.Println("uploading thumbnail for video", videoID, "by user", userID)
 
@@ -78,11 +91,19 @@ func (cfg *apiConfig) handlerUploadThumbnail(w http.ResponseWriter, r *http.Requ
 
 	thumbnailPath := filepath.Join(cfg.assetsRoot, fmt.Sprintf("%s.%s", fileName, fileExtension))
 
+	uow, err := cfg.db.BeginTx(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting upload", err)
+		return
+	}
+	defer rollback(uow)
+
 	thumbnail, err := os.Create(thumbnailPath)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating thumbnail file", err)
 		return
 	}
+	stageFile(uow, thumbnailPath)
 
 	defer thumbnail.Close()
 
@@ -92,25 +113,25 @@ func (cfg *apiConfig) handlerUploadThumbnail(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	videoMetadata, err := cfg.db.GetVideo(videoID)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error getting video metadata", err)
+	thumbnailURL := fmt.Sprintf("http://localhost:%s/assets/%s.%s", cfg.port, fileName, fileExtension)
+	err = saveVideo(uow, r, &videoMetadata, func(video *database.Video) {
+		video.ThumbnailURL = &thumbnailURL
+	})
+	if errors.Is(err, database.ErrVideoModified) {
+		respondWithError(w, http.StatusPreconditionFailed, "Video was modified since you loaded it", err)
 		return
 	}
-
-	if userID != videoMetadata.UserID {
-		respondWithError(w, http.StatusUnauthorized, "User does not have permission to upload thumbnail for this video", nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating video metadata", err)
 		return
 	}
-	
-	thumbnailURL := fmt.Sprintf("http://localhost:%s/assets/%s.%s", cfg.port, fileName, fileExtension)
-	videoMetadata.ThumbnailURL = &thumbnailURL
-
-	err = cfg.db.UpdateVideo(videoMetadata)
+	err = uow.Commit()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating video metadata", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, videoMetadata)
+	cfg.audit(r, videoEvent(userID, auditThumbnailUpload, videoID, database.AuditOutcomeSuccess))
+
+	respondWithVideo(w, http.StatusOK, videoMetadata)
 }
@@ -11,13 +11,16 @@ import (
 	"context"
 	"os/exec"
 	"encoding/json"
+	"errors"
 	"bytes"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
 
-	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
 )
 
 func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request) {
@@ -30,15 +33,8 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+	userID, ok := cfg.authenticate(w, r, scopeUpload)
+	if !ok {
 		return
 	}
 
@@ -49,9 +45,13 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 	}
 
 	if userID != videoMetadata.UserID {
+		cfg.audit(r, videoEvent(userID, auditVideoUpload, videoID, database.AuditOutcomeFailure))
 		respondWithError(w, http.StatusUnauthorized, "User does not have permission to upload thumbnail for this video", nil)
 		return
 	}
+	if !checkIfMatch(w, r, videoMetadata) {
+		return
+	}
 
 	err = r.ParseMultipartForm(maxMemory)
 	if err != nil {
@@ -59,6 +59,21 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	previewOpts, err := parsePreviewOptions(r.FormValue("preview_start"), r.FormValue("preview_duration"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	normalizeAudio := false
+	if value := r.FormValue("normalize_audio"); value != "" {
+		normalizeAudio, err = strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "normalize_audio must be true or false", err)
+			return
+		}
+	}
+
 	file, header, err := r.FormFile("video")
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Error reading video", err)
@@ -97,12 +112,64 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 	}
 
 	tempPath := video.Name()
+	defer os.Remove(tempPath)
 
-	processedPath, err := processVideoForFastStart(tempPath)
+	wm, applyWatermark, err := cfg.watermarkForVideo(videoMetadata)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error processing video for fast start", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting watermark settings", err)
 		return
 	}
+
+	var processedPath string
+	if applyWatermark {
+		processedPath, err = processVideoWithWatermark(tempPath, wm)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error applying watermark", err)
+			return
+		}
+	} else {
+		processedPath, err = processVideoForFastStart(tempPath)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error processing video for fast start", err)
+			return
+		}
+	}
+	defer os.Remove(processedPath)
+
+	// Loudness is always measured for the report, but only an upload that
+	// asked for normalization depends on it.
+	var integratedLoudness *float64
+	loudness, err := measureLoudness(processedPath)
+	if err != nil {
+		if normalizeAudio {
+			respondWithError(w, http.StatusInternalServerError, "Error measuring audio loudness", err)
+			return
+		}
+		log.Printf("Error measuring loudness of video %s: %v", videoID, err)
+	}
+	if loudness != nil {
+		integrated := loudness.InputI
+		if normalizeAudio {
+			normalizedPath, normalized, err := normalizeLoudness(processedPath, *loudness)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error normalizing audio loudness", err)
+				return
+			}
+			defer os.Remove(normalizedPath)
+			processedPath = normalizedPath
+			integrated = normalized.OutputI
+		}
+
+		integratedLoudness, err = parseLUFS(integrated)
+		if err != nil {
+			if normalizeAudio {
+				respondWithError(w, http.StatusInternalServerError, "Error measuring audio loudness", err)
+				return
+			}
+			log.Printf("Error measuring loudness of video %s: %v", videoID, err)
+		}
+	}
+
 	if !checkMoovAtom(processedPath) {
 		respondWithError(w, http.StatusInternalServerError, "Error processing video for fast start", err)
 		return
@@ -113,11 +180,7 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "Error opening processed video", err)
 		return
 	}
-	
-	defer os.Remove(tempPath)
-	defer os.Remove(processedPath)
 	defer processedVideo.Close()
-	
 
 	aspectRatio, err := getVideoAspectRatio(processedPath)
 	if err != nil {
@@ -142,6 +205,13 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 	//key is filename + mp4
 	key := fmt.Sprintf("%s/%s.mp4", aspectRatio, fileName)
 
+	uow, err := cfg.db.BeginTx(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting upload", err)
+		return
+	}
+	defer rollback(uow)
+
 	_, err = cfg.s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
 		Bucket: &cfg.s3Bucket,
 		Key:    &key,
@@ -154,16 +224,59 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 	}
 
 	videoURL := fmt.Sprintf("https://%s/%s", cfg.s3CfDistribution, key)
+	cfg.stageObjectsAt(r.Context(), uow, videoURL)
 
-	videoMetadata.VideoURL = &videoURL
+	versionParams := database.CreateVideoVersionParams{
+		VideoID:            videoID,
+		VideoURL:           videoURL,
+		AspectRatio:        &aspectRatio,
+		IntegratedLoudness: integratedLoudness,
+	}
+	err = probeVideoVersion(processedPath, &versionParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error probing video", err)
+		return
+	}
+
+	previewURL, previewVideoURL, err := cfg.uploadPreviews(r.Context(), processedPath, previewOpts)
+	if err != nil {
+		// The upload itself succeeded; a missing preview only affects hover cards.
+		log.Printf("Error generating preview for video %s: %v", videoID, err)
+	} else {
+		cfg.stageObjectsAt(r.Context(), uow, previewURL, previewVideoURL)
+		versionParams.PreviewURL = &previewURL
+		versionParams.PreviewVideoURL = &previewVideoURL
+	}
 
-	err = cfg.db.UpdateVideo(videoMetadata)
+	version, err := uow.CreateVideoVersion(versionParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording video version", err)
+		return
+	}
+	err = saveVideo(uow, r, &videoMetadata, func(video *database.Video) {
+		setCurrentVersion(video, version)
+	})
+	if errors.Is(err, database.ErrVideoModified) {
+		// The client's precondition failed, so rolling back drops the new
+		// version along with its media.
+		respondWithError(w, http.StatusPreconditionFailed, "Video was modified since you loaded it", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating video metadata", err)
+		return
+	}
+	err = uow.Commit()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating video metadata", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, videoMetadata)
+	cfg.pruneVideoVersions(r.Context(), videoMetadata)
+
+	cfg.audit(r, videoEvent(userID, auditVideoUpload, videoID, database.AuditOutcomeSuccess))
+
+	respondWithVideo(w, http.StatusOK, videoMetadata)
 }
 
 type Stream struct {
@@ -171,8 +284,13 @@ type Stream struct {
     Height int `json:"height"`
 }
 
+type Format struct {
+    Duration string `json:"duration"`
+}
+
 type FFProbeOutput struct {
     Streams []Stream `json:"streams"`
+    Format  Format   `json:"format"`
 }
 
 func getVideoAspectRatio(filePath string) (string, error) {
@@ -212,6 +330,27 @@ func getVideoAspectRatio(filePath string) (string, error) {
     return aspectRatio, nil
 }
 
+func getVideoDuration(filePath string) (time.Duration, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-print_format", "json", "-show_format", filePath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run ffprobe: %w\n%s", err, string(output))
+	}
+
+	var ffprobeOutput FFProbeOutput
+	err = json.Unmarshal(output, &ffprobeOutput)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	seconds, err := strconv.ParseFloat(ffprobeOutput.Format.Duration, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q in ffprobe output: %w", ffprobeOutput.Format.Duration, err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 func processVideoForFastStart(filePath string) (string, error) {
 	outputPath := fmt.Sprintf("%s.processing", filePath)
 	// Run the ffmpeg command
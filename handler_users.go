@@ -38,9 +38,15 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 		Password: hashedPassword,
 	})
 	if err != nil {
+		cfg.audit(r, database.CreateAuditEventParams{
+			Action:  auditUserCreate,
+			Outcome: database.AuditOutcomeFailure,
+		})
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 
+	cfg.audit(r, userEvent(&user.ID, auditUserCreate, user.ID, database.AuditOutcomeSuccess))
+
 	respondWithJSON(w, http.StatusCreated, user)
 }
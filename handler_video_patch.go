@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxVideoTitleLength      = 200
+	maxVideoDescriptionBytes = 5000
+	maxVideoPatchBytes       = 64 << 10
+)
+
+// videoPatch is a JSON merge patch (RFC 7396) of a video's metadata. Nil
+// fields were left out of the patch.
+type videoPatch struct {
+	Title       *string
+	Description *string
+	Visibility  *string
+}
+
+// parseVideoPatch validates a merge patch. Only the title, description and
+// visibility can be patched; a null description clears it, but a video
+// always needs a title and a visibility.
+func parseVideoPatch(body []byte) (videoPatch, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(body, &fields)
+	if err != nil || fields == nil {
+		return videoPatch{}, errors.New("patch must be a JSON object")
+	}
+
+	patch := videoPatch{}
+	for name, value := range fields {
+		isNull := string(value) == "null"
+		switch name {
+		case "title":
+			if isNull {
+				return videoPatch{}, errors.New("title can't be removed")
+			}
+			var title string
+			if err := json.Unmarshal(value, &title); err != nil {
+				return videoPatch{}, errors.New("title must be a string")
+			}
+			title = strings.TrimSpace(title)
+			if title == "" || utf8.RuneCountInString(title) > maxVideoTitleLength {
+				return videoPatch{}, fmt.Errorf("title must be between 1 and %d characters", maxVideoTitleLength)
+			}
+			patch.Title = &title
+		case "description":
+			description := ""
+			if !isNull {
+				if err := json.Unmarshal(value, &description); err != nil {
+					return videoPatch{}, errors.New("description must be a string or null")
+				}
+			}
+			if len(description) > maxVideoDescriptionBytes {
+				return videoPatch{}, fmt.Errorf("description must be at most %d bytes", maxVideoDescriptionBytes)
+			}
+			patch.Description = &description
+		case "visibility":
+			var visibility string
+			if err := json.Unmarshal(value, &visibility); err != nil || !validVisibility(visibility) {
+				return videoPatch{}, errors.New("visibility must be private, unlisted or public")
+			}
+			patch.Visibility = &visibility
+		default:
+			return videoPatch{}, fmt.Errorf("%s can't be patched", name)
+		}
+	}
+	return patch, nil
+}
+
+// handlerVideoPatch edits a video's title, description and visibility with
+// a JSON merge patch.
+func (cfg *apiConfig) handlerVideoPatch(w http.ResponseWriter, r *http.Request) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticate(w, r, scopeVideosWrite)
+	if !ok {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+		w.Header().Set("Accept-Patch", "application/merge-patch+json")
+		respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json", err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxVideoPatchBytes))
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Patch is too large", err)
+		return
+	}
+	patch, err := parseVideoPatch(body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return
+	}
+	if video.UserID != userID {
+		cfg.audit(r, videoEvent(userID, auditVideoUpdate, videoID, database.AuditOutcomeFailure))
+		respondWithError(w, http.StatusForbidden, "You can't edit this video", nil)
+		return
+	}
+	if !checkIfMatch(w, r, video) {
+		return
+	}
+
+	if patch.Title != nil || patch.Description != nil || patch.Visibility != nil {
+		err = saveVideo(cfg.db, r, &video, func(video *database.Video) {
+			if patch.Title != nil {
+				video.Title = *patch.Title
+			}
+			if patch.Description != nil {
+				video.Description = *patch.Description
+			}
+			if patch.Visibility != nil {
+				video.Visibility = *patch.Visibility
+			}
+		})
+		if errors.Is(err, database.ErrVideoModified) {
+			respondWithError(w, http.StatusPreconditionFailed, "Video was modified since you loaded it", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update video", err)
+			return
+		}
+		cfg.audit(r, videoEvent(userID, auditVideoUpdate, videoID, database.AuditOutcomeSuccess))
+	}
+
+	// Reload for the updated_at the database set.
+	video, err = cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	respondWithVideo(w, http.StatusOK, video)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func TestParseVideoPatch(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		wantTitle       *string
+		wantDescription *string
+		wantVisibility  *string
+		wantErr         bool
+	}{
+		{name: "empty patch", body: `{}`},
+		{name: "title is trimmed", body: `{"title": "  New  "}`, wantTitle: ptr("New")},
+		{name: "null clears the description", body: `{"description": null}`, wantDescription: ptr("")},
+		{
+			name:            "several fields",
+			body:            `{"description": "About", "visibility": "public"}`,
+			wantDescription: ptr("About"),
+			wantVisibility:  ptr("public"),
+		},
+		{name: "longest title", body: `{"title": "` + strings.Repeat("é", maxVideoTitleLength) + `"}`, wantTitle: ptr(strings.Repeat("é", maxVideoTitleLength))},
+		{name: "title too long", body: `{"title": "` + strings.Repeat("a", maxVideoTitleLength+1) + `"}`, wantErr: true},
+		{name: "null title", body: `{"title": null}`, wantErr: true},
+		{name: "blank title", body: `{"title": "   "}`, wantErr: true},
+		{name: "title not a string", body: `{"title": 1}`, wantErr: true},
+		{name: "description too long", body: `{"description": "` + strings.Repeat("a", maxVideoDescriptionBytes+1) + `"}`, wantErr: true},
+		{name: "null visibility", body: `{"visibility": null}`, wantErr: true},
+		{name: "unknown visibility", body: `{"visibility": "friends"}`, wantErr: true},
+		{name: "unknown field", body: `{"user_id": "x"}`, wantErr: true},
+		{name: "not an object", body: `["title"]`, wantErr: true},
+		{name: "null patch", body: `null`, wantErr: true},
+	}
+
+	equal := func(got, want *string) bool {
+		return got == nil && want == nil || got != nil && want != nil && *got == *want
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := parseVideoPatch([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, tt.wantErr)
+			}
+			if !equal(patch.Title, tt.wantTitle) || !equal(patch.Description, tt.wantDescription) || !equal(patch.Visibility, tt.wantVisibility) {
+				t.Errorf("got %+v", patch)
+			}
+		})
+	}
+}
+
+func TestHandlerVideoPatch(t *testing.T) {
+	cfg := newTestConfig(t)
+	owner := testUser(t, cfg, "owner@example.com")
+	_, ownerToken := testLogin(t, cfg, owner)
+	_, otherToken := testLogin(t, cfg, testUser(t, cfg, "other@example.com"))
+
+	tests := []struct {
+		name            string
+		token           string
+		contentType     string
+		body            string
+		wantStatus      int
+		wantTitle       string
+		wantDescription string
+	}{
+		{
+			name:            "fields left out stay as they are",
+			token:           ownerToken,
+			contentType:     "application/merge-patch+json",
+			body:            `{"title": "Renamed"}`,
+			wantStatus:      http.StatusOK,
+			wantTitle:       "Renamed",
+			wantDescription: "About",
+		},
+		{
+			name:            "plain JSON is accepted",
+			token:           ownerToken,
+			contentType:     "application/json; charset=utf-8",
+			body:            `{"description": null}`,
+			wantStatus:      http.StatusOK,
+			wantTitle:       "Original",
+			wantDescription: "",
+		},
+		{name: "wrong content type", token: ownerToken, contentType: "text/plain", body: `{}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "invalid patch", token: ownerToken, contentType: "application/merge-patch+json", body: `{"title": ""}`, wantStatus: http.StatusBadRequest},
+		{name: "someone else's video", token: otherToken, contentType: "application/merge-patch+json", body: `{"title": "Mine"}`, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video, err := cfg.db.CreateVideo(database.CreateVideoParams{Title: "Original", Description: "About", UserID: owner.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := authorizedRequest(http.MethodPatch, "/api/videos/"+video.ID.String(), tt.token, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.SetPathValue("videoID", video.ID.String())
+			rec := httptest.NewRecorder()
+			cfg.handlerVideoPatch(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			saved, err := cfg.db.GetVideo(video.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if saved.Revision != video.Revision {
+					t.Errorf("rejected patch changed the video to revision %d", saved.Revision)
+				}
+				return
+			}
+			if saved.Title != tt.wantTitle || saved.Description != tt.wantDescription {
+				t.Errorf("got title %q and description %q, want %q and %q", saved.Title, saved.Description, tt.wantTitle, tt.wantDescription)
+			}
+			if rec.Header().Get("ETag") != videoETag(saved) {
+				t.Errorf("got ETag %q, want %q", rec.Header().Get("ETag"), videoETag(saved))
+			}
+		})
+	}
+}
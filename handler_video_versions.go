@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerVideoVersionsList(w http.ResponseWriter, r *http.Request) {
+	type versionResponse struct {
+		database.VideoVersion
+		Current bool `json:"current"`
+	}
+
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return
+	}
+
+	userID, ok := cfg.authenticate(w, r, scopeVideosRead)
+	if !ok {
+		return
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return
+	}
+	if video.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't view this video's versions", nil)
+		return
+	}
+
+	versions, err := cfg.db.GetVideoVersions(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve versions", err)
+		return
+	}
+
+	resp := []versionResponse{}
+	for _, v := range versions {
+		resp = append(resp, versionResponse{
+			VideoVersion: v,
+			Current:      video.CurrentVersionID != nil && v.ID == *video.CurrentVersionID,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerVideoVersionActivate makes an earlier version the video's current
+// media again.
+func (cfg *apiConfig) handlerVideoVersionActivate(w http.ResponseWriter, r *http.Request) {
+	video, version, ok := cfg.ownedVideoVersion(w, r, scopeVideosWrite)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, video) {
+		return
+	}
+
+	err := saveVideo(cfg.db, r, &video, func(video *database.Video) {
+		setCurrentVersion(video, version)
+	})
+	if errors.Is(err, database.ErrVideoModified) {
+		respondWithError(w, http.StatusPreconditionFailed, "Video was modified since you loaded it", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update video", err)
+		return
+	}
+
+	err = cfg.syncHLSSubtitles(r.Context(), video)
+	if err != nil {
+		log.Printf("Couldn't add subtitles to HLS playlist of video %s: %v", video.ID, err)
+	}
+
+	respondWithVideo(w, http.StatusOK, video)
+}
+
+// handlerVideoVersionDownload streams a version's media as an attachment.
+func (cfg *apiConfig) handlerVideoVersionDownload(w http.ResponseWriter, r *http.Request) {
+	video, version, ok := cfg.ownedVideoVersion(w, r, scopeVideosRead)
+	if !ok {
+		return
+	}
+
+	// HLS playlists reference their segments, so they can't be downloaded
+	// as a single file.
+	key, ok := cfg.objectKey(version.VideoURL)
+	if !ok || strings.HasSuffix(version.VideoURL, ".m3u8") {
+		http.Redirect(w, r, version.VideoURL, http.StatusFound)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-v%d.mp4", video.ID, version.Version)
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if version.SizeBytes != nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(*version.SizeBytes, 10))
+	}
+	err := cfg.downloadObject(r.Context(), key, w)
+	if err != nil {
+		// Headers are already sent, so the client sees a truncated body.
+		log.Printf("Error streaming version %d of video %s: %v", version.Version, video.ID, err)
+	}
+}
+
+// ownedVideoVersion loads the video and version named in the path and
+// checks that the caller, authenticated for scope, owns the video. If not,
+// it writes the error response and returns false.
+func (cfg *apiConfig) ownedVideoVersion(w http.ResponseWriter, r *http.Request, scope string) (database.Video, database.VideoVersion, bool) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return database.Video{}, database.VideoVersion{}, false
+	}
+	versionID, err := uuid.Parse(r.PathValue("versionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid version ID", err)
+		return database.Video{}, database.VideoVersion{}, false
+	}
+
+	userID, ok := cfg.authenticate(w, r, scope)
+	if !ok {
+		return database.Video{}, database.VideoVersion{}, false
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return database.Video{}, database.VideoVersion{}, false
+	}
+	if video.ID == uuid.Nil {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return database.Video{}, database.VideoVersion{}, false
+	}
+	if video.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't access this video's versions", nil)
+		return database.Video{}, database.VideoVersion{}, false
+	}
+
+	version, err := cfg.db.GetVideoVersion(versionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get version", err)
+		return database.Video{}, database.VideoVersion{}, false
+	}
+	if version.ID == uuid.Nil || version.VideoID != videoID {
+		respondWithError(w, http.StatusNotFound, "Version not found", nil)
+		return database.Video{}, database.VideoVersion{}, false
+	}
+	return video, version, true
+}
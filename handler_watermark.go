@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+type watermarkResponse struct {
+	database.WatermarkSettings
+	CustomImage bool `json:"custom_image"`
+}
+
+func newWatermarkResponse(settings database.WatermarkSettings) watermarkResponse {
+	return watermarkResponse{
+		WatermarkSettings: settings,
+		CustomImage:       settings.ImagePath != nil,
+	}
+}
+
+func (cfg *apiConfig) handlerWatermarkGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := cfg.db.GetWatermarkSettings(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get watermark settings", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWatermarkResponse(settings))
+}
+
+func (cfg *apiConfig) handlerWatermarkUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Enabled  *bool    `json:"enabled"`
+		Position *string  `json:"position"`
+		Opacity  *float64 `json:"opacity"`
+		Scale    *float64 `json:"scale"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	settings, err := cfg.db.GetWatermarkSettings(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get watermark settings", err)
+		return
+	}
+
+	if params.Enabled != nil {
+		settings.Enabled = *params.Enabled
+	}
+	if params.Position != nil {
+		settings.Position = params.Position
+	}
+	if params.Opacity != nil {
+		settings.Opacity = params.Opacity
+	}
+	if params.Scale != nil {
+		settings.Scale = params.Scale
+	}
+
+	merged, _ := mergeWatermark(cfg.watermark, database.WatermarkSettings{
+		Enabled:  true,
+		Position: settings.Position,
+		Opacity:  settings.Opacity,
+		Scale:    settings.Scale,
+	})
+	err = validateWatermark(merged.position, merged.opacity, merged.scale)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	settings, err = cfg.db.UpsertWatermarkSettings(settings)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save watermark settings", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWatermarkResponse(settings))
+}
+
+func (cfg *apiConfig) handlerWatermarkImageUpload(w http.ResponseWriter, r *http.Request) {
+	const maxMemory = 5 << 20 // 5 MB
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMemory)
+	err := r.ParseMultipartForm(maxMemory)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error parsing form", err)
+		return
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error reading watermark image", err)
+		return
+	}
+	defer file.Close()
+
+	mediaType, _, err := mime.ParseMediaType(header.Header.Get("Content-Type"))
+	if err != nil || mediaType != "image/png" {
+		respondWithError(w, http.StatusBadRequest, "Watermark must be a png", err)
+		return
+	}
+
+	fileName, err := randomAssetName()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error generating random data", err)
+		return
+	}
+	imagePath := filepath.Join(cfg.assetsRoot, fmt.Sprintf("watermark-%s.png", fileName))
+
+	uow, err := cfg.db.BeginTx(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error starting upload", err)
+		return
+	}
+	defer rollback(uow)
+
+	image, err := os.Create(imagePath)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating watermark file", err)
+		return
+	}
+	stageFile(uow, imagePath)
+	defer image.Close()
+
+	_, err = io.Copy(image, file)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error copying watermark data", err)
+		return
+	}
+
+	settings, err := uow.GetWatermarkSettings(userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get watermark settings", err)
+		return
+	}
+	oldImagePath := settings.ImagePath
+	settings.ImagePath = &imagePath
+
+	settings, err = uow.UpsertWatermarkSettings(settings)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save watermark settings", err)
+		return
+	}
+	err = uow.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save watermark settings", err)
+		return
+	}
+	if oldImagePath != nil {
+		os.Remove(*oldImagePath)
+	}
+
+	respondWithJSON(w, http.StatusOK, newWatermarkResponse(settings))
+}
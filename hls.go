@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+const (
+	hlsContentType   = "application/vnd.apple.mpegurl"
+	hlsSubtitleGroup = "subs"
+)
+
+type hlsSubtitleRendition struct {
+	Name     string
+	Language string
+	URI      string
+}
+
+// subtitlePlaylist wraps a single WebVTT file in an HLS media playlist so it
+// can be referenced from a master playlist.
+func subtitlePlaylist(vttURL string, duration time.Duration) string {
+	seconds := duration.Seconds()
+	return fmt.Sprintf(
+		"#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:%.3f,\n%s\n#EXT-X-ENDLIST\n",
+		int(math.Ceil(seconds)), seconds, vttURL,
+	)
+}
+
+// setMasterPlaylistSubtitles replaces the subtitle renditions of a master
+// playlist with the given ones and points every variant stream at them.
+func setMasterPlaylistSubtitles(master string, renditions []hlsSubtitleRendition) string {
+	groupAttr := fmt.Sprintf(`GROUP-ID="%s"`, hlsSubtitleGroup)
+	subtitlesAttr := fmt.Sprintf(`,SUBTITLES="%s"`, hlsSubtitleGroup)
+
+	media := []string{}
+	for i, r := range renditions {
+		isDefault := "NO"
+		if i == 0 {
+			isDefault = "YES"
+		}
+		media = append(media, fmt.Sprintf(
+			`#EXT-X-MEDIA:TYPE=SUBTITLES,%s,NAME="%s",LANGUAGE="%s",DEFAULT=%s,AUTOSELECT=YES,URI="%s"`,
+			groupAttr, quoteAttr(r.Name), quoteAttr(r.Language), isDefault, r.URI,
+		))
+	}
+
+	out := []string{}
+	inserted := false
+	for _, line := range strings.Split(strings.TrimRight(master, "\n"), "\n") {
+		if strings.HasPrefix(line, "#EXT-X-MEDIA:") && strings.Contains(line, "TYPE=SUBTITLES") && strings.Contains(line, groupAttr) {
+			continue
+		}
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !inserted {
+				out = append(out, media...)
+				inserted = true
+			}
+			line = strings.Replace(line, subtitlesAttr, "", 1)
+			if len(renditions) > 0 {
+				line += subtitlesAttr
+			}
+		}
+		out = append(out, line)
+	}
+	if !inserted {
+		out = append(out, media...)
+	}
+
+	return strings.Join(out, "\n") + "\n"
+}
+
+func quoteAttr(s string) string {
+	return strings.ReplaceAll(s, `"`, "'")
+}
+
+// syncHLSSubtitles rewrites the video's master playlist so it lists the
+// video's current subtitle tracks. Videos that aren't served over HLS are
+// left alone.
+func (cfg *apiConfig) syncHLSSubtitles(ctx context.Context, video database.Video) error {
+	if video.VideoURL == nil || !strings.HasSuffix(*video.VideoURL, ".m3u8") {
+		return nil
+	}
+	masterKey, ok := cfg.objectKey(*video.VideoURL)
+	if !ok {
+		return nil
+	}
+
+	master, err := cfg.getObject(ctx, masterKey)
+	if err != nil {
+		return fmt.Errorf("couldn't fetch master playlist: %w", err)
+	}
+
+	renditions := []hlsSubtitleRendition{}
+	for _, track := range video.SubtitleTracks {
+		renditions = append(renditions, hlsSubtitleRendition{
+			Name:     track.Label,
+			Language: track.Language,
+			URI:      strings.TrimSuffix(track.URL, ".vtt") + ".m3u8",
+		})
+	}
+
+	updated := setMasterPlaylistSubtitles(string(master), renditions)
+	return cfg.putObject(ctx, masterKey, strings.NewReader(updated), hlsContentType)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetMasterPlaylistSubtitles(t *testing.T) {
+	const master = "#EXTM3U\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000\nhigh.m3u8\n"
+	const withEnglish = "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="en.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=800000,SUBTITLES="subs"` + "\nlow.m3u8\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=2000000,SUBTITLES="subs"` + "\nhigh.m3u8\n"
+
+	tests := []struct {
+		name       string
+		master     string
+		renditions []hlsSubtitleRendition
+		want       string
+	}{
+		{
+			name:       "add",
+			master:     master,
+			renditions: []hlsSubtitleRendition{{Name: "English", Language: "en", URI: "en.m3u8"}},
+			want:       withEnglish,
+		},
+		{
+			name:       "unchanged",
+			master:     withEnglish,
+			renditions: []hlsSubtitleRendition{{Name: "English", Language: "en", URI: "en.m3u8"}},
+			want:       withEnglish,
+		},
+		{
+			name:   "replace",
+			master: withEnglish,
+			renditions: []hlsSubtitleRendition{
+				{Name: `The "French"`, Language: "fr", URI: "fr.m3u8"},
+				{Name: "German", Language: "de", URI: "de.m3u8"},
+			},
+			want: "#EXTM3U\n" +
+				`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="The 'French'",LANGUAGE="fr",DEFAULT=YES,AUTOSELECT=YES,URI="fr.m3u8"` + "\n" +
+				`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="German",LANGUAGE="de",DEFAULT=NO,AUTOSELECT=YES,URI="de.m3u8"` + "\n" +
+				`#EXT-X-STREAM-INF:BANDWIDTH=800000,SUBTITLES="subs"` + "\nlow.m3u8\n" +
+				`#EXT-X-STREAM-INF:BANDWIDTH=2000000,SUBTITLES="subs"` + "\nhigh.m3u8\n",
+		},
+		{
+			name:   "remove",
+			master: withEnglish,
+			want:   master,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := setMasterPlaylistSubtitles(tt.master, tt.renditions)
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtitlePlaylist(t *testing.T) {
+	got := subtitlePlaylist("https://cdn.example.com/en.vtt", 90*time.Second+500*time.Millisecond)
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:91\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n" +
+		"#EXTINF:90.500,\nhttps://cdn.example.com/en.vtt\n#EXT-X-ENDLIST\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -34,50 +34,97 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
+// Identity is who an access token was issued to.
+type Identity struct {
+	UserID uuid.UUID
+	// SessionID is the session the token was issued to, or uuid.Nil for
+	// tokens issued without one.
+	SessionID uuid.UUID
+	// Role is the user's role when the token was issued.
+	Role string
+}
+
+// claims are an access token's claims. The subject is the user's ID.
+type claims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+}
+
 func MakeJWT(
 	userID uuid.UUID,
 	tokenSecret string,
 	expiresIn time.Duration,
+) (string, error) {
+	return MakeAccessJWT(Identity{UserID: userID}, tokenSecret, expiresIn)
+}
+
+// MakeAccessJWT makes an access token that carries the whole identity.
+func MakeAccessJWT(
+	identity Identity,
+	tokenSecret string,
+	expiresIn time.Duration,
 ) (string, error) {
 	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   identity.UserID.String(),
+		},
+		Role: identity.Role,
+	}
+	if identity.SessionID != uuid.Nil {
+		c.SessionID = identity.SessionID.String()
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
 	return token.SignedString(signingKey)
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+	identity, err := ValidateAccessJWT(tokenString, tokenSecret)
+	return identity.UserID, err
+}
+
+// ValidateAccessJWT validates an access token and returns who it was
+// issued to.
+func ValidateAccessJWT(tokenString, tokenSecret string) (Identity, error) {
+	claimsStruct := claims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claimsStruct,
 		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return Identity{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return Identity{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return Identity{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return Identity{}, errors.New("invalid issuer")
 	}
 
-	id, err := uuid.Parse(userIDString)
+	identity := Identity{Role: claimsStruct.Role}
+	identity.UserID, err = uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return Identity{}, fmt.Errorf("invalid user ID: %w", err)
 	}
-	return id, nil
+	if claimsStruct.SessionID != "" {
+		identity.SessionID, err = uuid.Parse(claimsStruct.SessionID)
+		if err != nil {
+			return Identity{}, fmt.Errorf("invalid session ID: %w", err)
+		}
+	}
+	return identity, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -102,6 +149,19 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(token), nil
 }
 
+// apiKeyPrefix starts every API key, so keys are easy to spot in logs and
+// config files.
+const apiKeyPrefix = "tubely_"
+
+func MakeAPIKey() (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(key), nil
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
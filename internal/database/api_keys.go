@@ -0,0 +1,178 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// apiKeyPrefixLength is how much of a key is kept in the clear.
+const apiKeyPrefixLength = 12
+
+// APIKey is a stored API key. Like refresh tokens, only the key's SHA-256
+// is stored, and methods take the key and hash it themselves.
+type APIKey struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	KeyHash   string    `json:"-"`
+	// Prefix is the start of the key, enough to recognize it by.
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+type CreateAPIKeyParams struct {
+	Key    string
+	UserID uuid.UUID
+	Name   string
+	Scopes []string
+	// ExpiresAt is nil for keys that don't expire.
+	ExpiresAt *time.Time
+}
+
+const apiKeyColumns = `
+		id,
+		created_at,
+		user_id,
+		name,
+		key_hash,
+		prefix,
+		scopes,
+		expires_at,
+		last_used_at,
+		revoked_at`
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var k APIKey
+	var scopes string
+	err := row.Scan(
+		&k.ID,
+		&k.CreatedAt,
+		&k.UserID,
+		&k.Name,
+		&k.KeyHash,
+		&k.Prefix,
+		&scopes,
+		&k.ExpiresAt,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+	)
+	k.Scopes = strings.Fields(scopes)
+	return k, err
+}
+
+func hashAPIKey(key string) string {
+	return sha256Hex(key)
+}
+
+func (c Client) CreateAPIKey(params CreateAPIKeyParams) (APIKey, error) {
+	id := uuid.New()
+	query := `
+	INSERT INTO api_keys (id, created_at, user_id, name, key_hash, prefix, scopes, expires_at)
+	VALUES (?, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?, ?)
+	`
+	var expiresAt *string
+	if params.ExpiresAt != nil {
+		s := formatTimestamp(*params.ExpiresAt)
+		expiresAt = &s
+	}
+	prefix := params.Key[:min(len(params.Key), apiKeyPrefixLength)]
+	_, err := c.exec(query, id, params.UserID, params.Name, hashAPIKey(params.Key), prefix, strings.Join(params.Scopes, " "), expiresAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	return c.GetAPIKey(id)
+}
+
+// GetAPIKey returns an API key by ID, or a zero APIKey if it doesn't exist.
+func (c Client) GetAPIKey(id uuid.UUID) (APIKey, error) {
+	query := `
+	SELECT` + apiKeyColumns + `
+	FROM api_keys
+	WHERE id = ?
+	`
+	return c.queryAPIKey(query, id)
+}
+
+// GetAPIKeyByKey looks up the stored key a client presented, returning a
+// zero APIKey if there is none. Revoked and expired keys are returned too;
+// telling them apart is up to the caller.
+func (c Client) GetAPIKeyByKey(key string) (APIKey, error) {
+	query := `
+	SELECT` + apiKeyColumns + `
+	FROM api_keys
+	WHERE key_hash = ?
+	`
+	return c.queryAPIKey(query, hashAPIKey(key))
+}
+
+func (c Client) queryAPIKey(query string, args ...any) (APIKey, error) {
+	k, err := scanAPIKey(c.queryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIKey{}, nil
+		}
+		return APIKey{}, err
+	}
+	return k, nil
+}
+
+// GetAPIKeys returns the user's keys that aren't revoked, newest first.
+func (c Client) GetAPIKeys(userID uuid.UUID) ([]APIKey, error) {
+	query := `
+	SELECT` + apiKeyColumns + `
+	FROM api_keys
+	WHERE user_id = ? AND revoked_at IS NULL
+	ORDER BY created_at DESC
+	`
+	rows, err := c.query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// TouchAPIKey records that a key was just used.
+func (c Client) TouchAPIKey(id uuid.UUID) error {
+	query := `
+	UPDATE api_keys
+	SET last_used_at = ?
+	WHERE id = ?
+	`
+	_, err := c.exec(query, formatTimestamp(time.Now()), id)
+	return err
+}
+
+func (c Client) RevokeAPIKey(id uuid.UUID) error {
+	query := `
+	UPDATE api_keys
+	SET revoked_at = CURRENT_TIMESTAMP
+	WHERE id = ? AND revoked_at IS NULL
+	`
+	_, err := c.exec(query, id)
+	return err
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditTargetUser is the target type of events about a user's account.
+// Users can see events targeting them even when someone else was the actor,
+// such as failed logins.
+const AuditTargetUser = "user"
+
+type AuditEvent struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	CreateAuditEventParams
+}
+
+type CreateAuditEventParams struct {
+	// ActorID is nil when nobody was authenticated, e.g. a failed login, or
+	// the server acted on its own.
+	ActorID    *uuid.UUID `json:"actor_id"`
+	Action     string     `json:"action"`
+	TargetType string     `json:"target_type"`
+	TargetID   string     `json:"target_id"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	Outcome    string     `json:"outcome"`
+	Detail     string     `json:"detail"`
+}
+
+// AuditEventCursor marks the last event of a page, handed to clients as an
+// opaque string.
+type AuditEventCursor struct {
+	CreatedAt string    `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+func (ac AuditEventCursor) Encode() string {
+	dat, _ := json.Marshal(ac)
+	return base64.RawURLEncoding.EncodeToString(dat)
+}
+
+func DecodeAuditEventCursor(s string) (AuditEventCursor, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return AuditEventCursor{}, ErrInvalidCursor
+	}
+	var ac AuditEventCursor
+	err = json.Unmarshal(dat, &ac)
+	if err != nil || ac.ID == uuid.Nil || ac.CreatedAt == "" {
+		return AuditEventCursor{}, ErrInvalidCursor
+	}
+	return ac, nil
+}
+
+type ListAuditEventsParams struct {
+	// UserID restricts the page to events the user performed or that
+	// target their account. uuid.Nil lists everyone's events.
+	UserID uuid.UUID
+	Action string
+	Limit  int
+	After  *AuditEventCursor
+}
+
+func (c Client) CreateAuditEvent(params CreateAuditEventParams) (AuditEvent, error) {
+	id := uuid.New()
+	query := `
+	INSERT INTO audit_events (
+		id,
+		created_at,
+		actor_id,
+		action,
+		target_type,
+		target_id,
+		ip,
+		user_agent,
+		outcome,
+		detail
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	// SQLite's CURRENT_TIMESTAMP only has whole seconds, too coarse to keep
+	// a burst of events in order.
+	_, err := c.exec(
+		query,
+		id,
+		formatTimestamp(time.Now()),
+		params.ActorID,
+		params.Action,
+		params.TargetType,
+		params.TargetID,
+		params.IP,
+		params.UserAgent,
+		params.Outcome,
+		params.Detail,
+	)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+
+	events, _, err := c.listAuditEvents("id = ?", []any{id}, 1)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+	if len(events) == 0 {
+		return AuditEvent{}, errors.New("audit event vanished after insert")
+	}
+	return events[0], nil
+}
+
+// ListAuditEvents returns one page of events, newest first. The cursor is
+// nil on the last page.
+func (c Client) ListAuditEvents(params ListAuditEventsParams) ([]AuditEvent, *AuditEventCursor, error) {
+	if params.Limit < 1 {
+		return nil, nil, errors.New("limit must be positive")
+	}
+
+	where := []string{}
+	args := []any{}
+	if params.UserID != uuid.Nil {
+		where = append(where, "(actor_id = ? OR (target_type = ? AND target_id = ?))")
+		args = append(args, params.UserID, AuditTargetUser, params.UserID.String())
+	}
+	if params.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, params.Action)
+	}
+	if params.After != nil {
+		where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, params.After.CreatedAt, params.After.CreatedAt, params.After.ID)
+	}
+	if len(where) == 0 {
+		where = append(where, "1 = 1")
+	}
+
+	return c.listAuditEvents(strings.Join(where, " AND "), args, params.Limit)
+}
+
+func (c Client) listAuditEvents(where string, args []any, limit int) ([]AuditEvent, *AuditEventCursor, error) {
+	query := `
+	SELECT
+		id,
+		created_at,
+		actor_id,
+		action,
+		target_type,
+		target_id,
+		ip,
+		user_agent,
+		outcome,
+		detail
+	FROM audit_events
+	WHERE ` + where + `
+	ORDER BY created_at DESC, id DESC
+	LIMIT ?
+	`
+	rows, err := c.query(query, append(args, limit+1)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.CreatedAt,
+			&event.ActorID,
+			&event.Action,
+			&event.TargetType,
+			&event.TargetID,
+			&event.IP,
+			&event.UserAgent,
+			&event.Outcome,
+			&event.Detail,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(events) <= limit {
+		return events, nil, nil
+	}
+
+	events = events[:limit]
+	last := events[len(events)-1]
+	return events, &AuditEventCursor{CreatedAt: formatTimestamp(last.CreatedAt), ID: last.ID}, nil
+}
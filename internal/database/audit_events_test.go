@@ -0,0 +1,84 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestListAuditEvents(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	alice, bob := uuid.New(), uuid.New()
+	for _, event := range []CreateAuditEventParams{
+		{ActorID: &alice, Action: "user.login", TargetType: AuditTargetUser, TargetID: alice.String(), Detail: "1"},
+		// Someone failing to log in as bob has no actor but targets bob.
+		{Action: "user.login", TargetType: AuditTargetUser, TargetID: bob.String(), Outcome: AuditOutcomeFailure, Detail: "2"},
+		{ActorID: &bob, Action: "video.upload", TargetType: "video", TargetID: uuid.NewString(), Detail: "3"},
+		// A video that happens to share bob's ID isn't bob.
+		{ActorID: &alice, Action: "video.delete", TargetType: "video", TargetID: bob.String(), Detail: "4"},
+		{ActorID: &alice, Action: "user.role_change", TargetType: AuditTargetUser, TargetID: bob.String(), Detail: "5"},
+		{Action: "video.purge", TargetType: "video", TargetID: uuid.NewString(), Detail: "6"},
+	} {
+		if _, err := c.CreateAuditEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		params ListAuditEventsParams
+		want   []string
+	}{
+		{name: "everything, newest first", params: ListAuditEventsParams{}, want: []string{"6", "5", "4", "3", "2", "1"}},
+		{name: "performed by or targeting bob", params: ListAuditEventsParams{UserID: bob}, want: []string{"5", "3", "2"}},
+		{name: "performed by alice", params: ListAuditEventsParams{UserID: alice}, want: []string{"5", "4", "1"}},
+		{name: "one action", params: ListAuditEventsParams{Action: "user.login"}, want: []string{"2", "1"}},
+		{name: "action and user", params: ListAuditEventsParams{UserID: bob, Action: "user.login"}, want: []string{"2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Page through two at a time to exercise the cursor as well.
+			params := tt.params
+			params.Limit = 2
+			got := []string{}
+			for {
+				events, next, err := c.ListAuditEvents(params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, event := range events {
+					got = append(got, event.Detail)
+				}
+				if next == nil {
+					break
+				}
+				if len(got) > 6 {
+					t.Fatal("paging doesn't end")
+				}
+				decoded, err := DecodeAuditEventCursor(next.Encode())
+				if err != nil {
+					t.Fatal(err)
+				}
+				params.After = &decoded
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got events %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "%%%", AuditEventCursor{ID: uuid.New()}.Encode(), AuditEventCursor{CreatedAt: "2024-01-01 00:00:00"}.Encode()} {
+		if _, err := DecodeAuditEventCursor(invalid); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeAuditEventCursor(%q): got %v, want ErrInvalidCursor", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,1426 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestConformance runs every Store check against a scratch SQLite
+// database, and against PostgreSQL when TUBELY_TEST_POSTGRES_URL points at
+// an empty database. Backends must pass it before they're used in
+// production.
+func TestConformance(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		runConformance(t, filepath.Join(t.TempDir(), "tubely.db"))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		databaseURL := os.Getenv("TUBELY_TEST_POSTGRES_URL")
+		if databaseURL == "" {
+			t.Skip("TUBELY_TEST_POSTGRES_URL is not set")
+		}
+		runConformance(t, databaseURL)
+	})
+}
+
+func runConformance(t *testing.T, databaseURL string) {
+	c, err := NewClient(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	// The suite deletes everything it touches.
+	users, err := c.GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) > 0 {
+		t.Fatal("conformance suite must run against an empty database")
+	}
+	t.Cleanup(func() { c.Reset() })
+
+	checks := []struct {
+		name string
+		run  func(*testing.T, Store)
+	}{
+		{"users", conformanceUsers},
+		{"roles", conformanceRoles},
+		{"refresh tokens", conformanceRefreshTokens},
+		{"sessions", conformanceSessions},
+		{"api keys", conformanceAPIKeys},
+		{"videos", conformanceVideos},
+		{"video visibility", conformanceVideoVisibility},
+		{"video search", conformanceVideoSearch},
+		{"trash", conformanceTrash},
+		{"video versions", conformanceVideoVersions},
+		{"subtitle tracks", conformanceSubtitleTracks},
+		{"tags", conformanceTags},
+		{"playlists", conformancePlaylists},
+		{"watermark settings", conformanceWatermarkSettings},
+		{"exports", conformanceExports},
+		{"audit events", conformanceAuditEvents},
+		{"units of work", conformanceUnitsOfWork},
+	}
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			if err := c.Reset(); err != nil {
+				t.Fatal(err)
+			}
+			// Reset keeps the audit log, but checks start without one.
+			if _, err := c.exec("DELETE FROM audit_events"); err != nil {
+				t.Fatal(err)
+			}
+			check.run(t, c)
+		})
+	}
+}
+
+func conformanceUser(t *testing.T, s Store) *User {
+	t.Helper()
+	user, err := s.CreateUser(CreateUserParams{
+		Email:    fmt.Sprintf("%s@example.com", uuid.NewString()),
+		Password: "hash",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func conformanceUsers(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	if !(user != nil && user.ID != uuid.Nil) {
+		t.Fatal("CreateUser returned no user")
+	}
+
+	byID, err := s.GetUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(byID != nil && byID.Email == user.Email) {
+		t.Fatalf("GetUser returned %+v", byID)
+	}
+
+	byEmail, err := s.GetUserByEmail(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(byEmail.ID == user.ID && byEmail.Password == "hash") {
+		t.Fatalf("GetUserByEmail returned %+v", byEmail)
+	}
+
+	missing, err := s.GetUserByEmail("missing@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing.ID != uuid.Nil {
+		t.Fatal("GetUserByEmail found a missing user")
+	}
+
+	users, err := s.GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("GetUsers returned %d users, want 1", len(users))
+	}
+
+	if _, err := s.CreateUser(CreateUserParams{Email: user.Email, Password: "x"}); err == nil {
+		t.Fatal("CreateUser accepted a duplicate email")
+	}
+
+	if err := s.DeleteUser(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.GetUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != nil {
+		t.Fatal("GetUser found a deleted user")
+	}
+}
+
+func conformanceRoles(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	if !(user.Role == RoleUser && user.DisabledAt == nil) {
+		t.Fatalf("CreateUser returned role %q, disabled at %v", user.Role, user.DisabledAt)
+	}
+
+	if err := s.SetUserRole(user.ID, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := s.GetUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.Role != RoleAdmin {
+		t.Fatalf("SetUserRole stored role %q", admin.Role)
+	}
+
+	token := uuid.NewString()
+	if _, err := s.CreateRefreshToken(CreateRefreshTokenParams{Token: token, UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	key := uuid.NewString()
+	if _, err := s.CreateAPIKey(CreateAPIKeyParams{Key: key, UserID: user.ID, Name: "ci", Scopes: []string{"upload"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DisableUser(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	disabled, err := s.GetUserByEmail(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled.DisabledAt == nil {
+		t.Fatal("DisableUser didn't set disabled_at")
+	}
+	rt, err := s.GetRefreshToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.RevokedAt == nil {
+		t.Fatal("DisableUser didn't revoke the user's refresh tokens")
+	}
+	apiKey, err := s.GetAPIKeyByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiKey.RevokedAt == nil {
+		t.Fatal("DisableUser didn't revoke the user's API keys")
+	}
+
+	if err := s.EnableUser(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	enabled, err := s.GetUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(enabled.DisabledAt == nil && enabled.Role == RoleAdmin) {
+		t.Fatalf("EnableUser left %+v", enabled)
+	}
+}
+
+func conformanceRefreshTokens(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+
+	raw := uuid.NewString()
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	token, err := s.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     raw,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(token.UserID == user.ID && token.RevokedAt == nil) {
+		t.Fatalf("CreateRefreshToken returned %+v", token)
+	}
+	if !(token.TokenHash != "" && token.TokenHash != raw) {
+		t.Fatal("CreateRefreshToken stored the token instead of its hash")
+	}
+	if !token.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("expires_at round-tripped as %v, want %v", token.ExpiresAt, expiresAt)
+	}
+
+	owner, err := s.GetUserByRefreshToken(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(owner != nil && owner.ID == user.ID) {
+		t.Fatalf("GetUserByRefreshToken returned %+v", owner)
+	}
+	byHash, err := s.GetRefreshToken(token.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byHash.TokenHash != "" {
+		t.Fatal("GetRefreshToken accepted a token's hash as the token")
+	}
+
+	expiredRaw := uuid.NewString()
+	expired, err := s.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     expiredRaw,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner, err = s.GetUserByRefreshToken(expiredRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != nil {
+		t.Fatal("GetUserByRefreshToken accepted an expired token")
+	}
+	if expired.FamilyID == token.FamilyID {
+		t.Fatalf("separate logins share family %s", token.FamilyID)
+	}
+
+	nextRaw := uuid.NewString()
+	next, err := s.RotateRefreshToken(raw, CreateRefreshTokenParams{
+		Token:     nextRaw,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		FamilyID:  token.FamilyID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(next.FamilyID == token.FamilyID && next.RotatedAt == nil) {
+		t.Fatalf("RotateRefreshToken returned %+v", next)
+	}
+	rotated, err := s.GetRefreshToken(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.RotatedAt == nil {
+		t.Fatal("RotateRefreshToken didn't set rotated_at")
+	}
+	owner, err = s.GetUserByRefreshToken(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != nil {
+		t.Fatal("GetUserByRefreshToken accepted a rotated token")
+	}
+	_, err = s.RotateRefreshToken(raw, CreateRefreshTokenParams{
+		Token:     uuid.NewString(),
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		FamilyID:  token.FamilyID,
+	})
+	if !errors.Is(err, ErrRefreshTokenRotated) {
+		t.Fatalf("rotating a rotated token returned %v", err)
+	}
+
+	if err := s.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+		t.Fatal(err)
+	}
+	next, err = s.GetRefreshToken(nextRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.RevokedAt == nil {
+		t.Fatal("RevokeRefreshTokenFamily didn't revoke the family's newest token")
+	}
+	owner, err = s.GetUserByRefreshToken(nextRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != nil {
+		t.Fatal("GetUserByRefreshToken accepted a revoked token")
+	}
+	expired, err = s.GetRefreshToken(expiredRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired.RevokedAt != nil {
+		t.Fatal("RevokeRefreshTokenFamily revoked another family")
+	}
+
+	liveRaw := uuid.NewString()
+	_, err = s.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     liveRaw,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeRefreshToken(liveRaw); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := s.GetRefreshToken(liveRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked.RevokedAt == nil {
+		t.Fatal("RevokeRefreshToken didn't set revoked_at")
+	}
+
+	rotatedRaw := uuid.NewString()
+	kept, err := s.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     rotatedRaw,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keptRaw := uuid.NewString()
+	_, err = s.RotateRefreshToken(rotatedRaw, CreateRefreshTokenParams{
+		Token:     keptRaw,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		FamilyID:  kept.FamilyID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first family's two tokens, the expired token and the revoked one
+	// go; the live family's rotated and current tokens stay.
+	deleted, err := s.DeleteStaleRefreshTokens(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 4 {
+		t.Fatalf("DeleteStaleRefreshTokens deleted %d tokens, want 4", deleted)
+	}
+	for _, survivor := range []string{rotatedRaw, keptRaw} {
+		rt, err := s.GetRefreshToken(survivor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rt.TokenHash == "" {
+			t.Fatal("DeleteStaleRefreshTokens deleted a live or rotated token")
+		}
+	}
+
+	if err := s.DeleteRefreshToken(keptRaw); err != nil {
+		t.Fatal(err)
+	}
+	gone, err := s.GetRefreshToken(keptRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gone.TokenHash != "" {
+		t.Fatal("GetRefreshToken found a deleted token")
+	}
+}
+
+func conformanceSessions(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	other := conformanceUser(t, s)
+
+	expiresAt := time.Now().UTC().Add(time.Hour)
+	laptopRaw := uuid.NewString()
+	laptop, err := s.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:      laptopRaw,
+		UserID:     user.ID,
+		ExpiresAt:  expiresAt,
+		DeviceName: "Laptop",
+		UserAgent:  "agent/1",
+		IP:         "192.0.2.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone, err := s.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:      uuid.NewString(),
+		UserID:     user.ID,
+		ExpiresAt:  expiresAt,
+		DeviceName: "Phone",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	elsewhere, err := s.CreateRefreshToken(CreateRefreshTokenParams{
+		Token:     uuid.NewString(),
+		UserID:    other.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A refresh continues the session: same ID, sign-in time and device
+	// name, with the refreshing client's details.
+	_, err = s.RotateRefreshToken(laptopRaw, CreateRefreshTokenParams{
+		Token:     uuid.NewString(),
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		FamilyID:  laptop.FamilyID,
+		UserAgent: "agent/2",
+		IP:        "192.0.2.2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := s.GetSession(laptop.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(session.ID == laptop.FamilyID && session.UserID == user.ID && session.DeviceName == "Laptop") {
+		t.Fatalf("GetSession returned %+v", session)
+	}
+	if !(session.UserAgent == "agent/2" && session.IP == "192.0.2.2") {
+		t.Fatalf("rotation didn't record the refreshing client, got %+v", session)
+	}
+	if !session.SignedInAt.Equal(laptop.SignedInAt) {
+		t.Fatalf("rotation changed the sign-in time from %v to %v", laptop.SignedInAt, session.SignedInAt)
+	}
+
+	sessions, err := s.GetSessions(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("GetSessions returned %d sessions, want 2", len(sessions))
+	}
+
+	revoked, err := s.RevokeOtherSessions(user.ID, laptop.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 1 {
+		t.Fatalf("RevokeOtherSessions revoked %d sessions, want 1", revoked)
+	}
+	sessions, err = s.GetSessions(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(sessions) == 1 && sessions[0].ID == laptop.FamilyID) {
+		t.Fatalf("RevokeOtherSessions left %+v", sessions)
+	}
+	gone, err := s.GetSession(phone.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gone.ID != uuid.Nil {
+		t.Fatal("GetSession found a revoked session")
+	}
+	kept, err := s.GetSession(elsewhere.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.ID != elsewhere.FamilyID {
+		t.Fatal("RevokeOtherSessions revoked another user's session")
+	}
+}
+
+func conformanceAPIKeys(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+
+	raw := "tubely_" + uuid.NewString()
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	key, err := s.CreateAPIKey(CreateAPIKeyParams{
+		Key:       raw,
+		UserID:    user.ID,
+		Name:      "ci",
+		Scopes:    []string{"videos:read", "upload"},
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(key.ID != uuid.Nil && key.UserID == user.ID && key.Name == "ci") {
+		t.Fatalf("CreateAPIKey returned %+v", key)
+	}
+	if !(key.KeyHash != "" && key.KeyHash != raw && key.Prefix == raw[:12]) {
+		t.Fatal("CreateAPIKey stored the key instead of its hash")
+	}
+	if !(key.HasScope("upload") && !key.HasScope("videos:write")) {
+		t.Fatalf("CreateAPIKey stored scopes %v", key.Scopes)
+	}
+	if !(key.ExpiresAt != nil && key.ExpiresAt.Equal(expiresAt) && key.LastUsedAt == nil) {
+		t.Fatalf("CreateAPIKey returned %+v", key)
+	}
+	if _, err := s.CreateAPIKey(CreateAPIKeyParams{Key: uuid.NewString(), UserID: user.ID, Name: "forever", Scopes: []string{"videos:read"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.GetAPIKeyByKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != key.ID {
+		t.Fatalf("GetAPIKeyByKey returned %+v", found)
+	}
+	byHash, err := s.GetAPIKeyByKey(key.KeyHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byHash.ID != uuid.Nil {
+		t.Fatal("GetAPIKeyByKey accepted a key's hash as the key")
+	}
+
+	if err := s.TouchAPIKey(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	touched, err := s.GetAPIKey(key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if touched.LastUsedAt == nil {
+		t.Fatal("TouchAPIKey didn't set last_used_at")
+	}
+
+	if err := s.RevokeAPIKey(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := s.GetAPIKeyByKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked.RevokedAt == nil {
+		t.Fatal("RevokeAPIKey didn't set revoked_at")
+	}
+	keys, err := s.GetAPIKeys(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(keys) == 1 && keys[0].Name == "forever" && keys[0].ExpiresAt == nil) {
+		t.Fatalf("GetAPIKeys returned %+v", keys)
+	}
+}
+
+func conformanceVideos(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+
+	video, err := s.CreateVideo(CreateVideoParams{Title: "title", Description: "description", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(video.ID != uuid.Nil && video.UserID == user.ID && video.Title == "title") {
+		t.Fatalf("CreateVideo returned %+v", video)
+	}
+	if !(video.VideoURL == nil && video.ThumbnailURL == nil) {
+		t.Fatal("new video already has media")
+	}
+
+	videoURL := "https://example.com/video.mp4"
+	loudness := -16.25
+	video.VideoURL = &videoURL
+	video.IntegratedLoudness = &loudness
+	video.Description = "updated"
+	if err := s.UpdateVideo(video); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(updated.VideoURL != nil && *updated.VideoURL == videoURL) {
+		t.Fatal("UpdateVideo didn't store video_url")
+	}
+	if !(updated.IntegratedLoudness != nil && *updated.IntegratedLoudness == loudness) {
+		t.Fatal("UpdateVideo didn't store integrated_loudness")
+	}
+	if updated.Description != "updated" {
+		t.Fatal("UpdateVideo didn't store description")
+	}
+	if updated.Revision != video.Revision+1 {
+		t.Fatalf("UpdateVideo left revision at %d, want %d", updated.Revision, video.Revision+1)
+	}
+	video.Description = "stale"
+	if err := s.UpdateVideo(video); !errors.Is(err, ErrVideoModified) {
+		t.Fatalf("UpdateVideo with a stale revision returned %v, want ErrVideoModified", err)
+	}
+	if err := s.AddVideoTags(video.ID, user.ID, []string{"revised"}); err != nil {
+		t.Fatal(err)
+	}
+	tagged, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(tagged.Revision == updated.Revision+1 && tagged.Description == "updated") {
+		t.Fatalf("AddVideoTags left revision %d and description %q", tagged.Revision, tagged.Description)
+	}
+
+	if _, err := s.CreateVideo(CreateVideoParams{Title: "second", UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	videos, err := s.GetVideos(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 2 {
+		t.Fatalf("GetVideos returned %d videos, want 2", len(videos))
+	}
+
+	page, next, err := s.ListVideos(ListVideosParams{UserID: user.ID, Limit: 1, Sort: VideoSortTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(page) == 1 && page[0].Title == "second" && next != nil) {
+		t.Fatalf("ListVideos first page returned %d videos", len(page))
+	}
+	page, next, err = s.ListVideos(ListVideosParams{UserID: user.ID, Limit: 1, Sort: VideoSortTitle, After: next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(page) == 1 && page[0].ID == video.ID && next == nil) {
+		t.Fatalf("ListVideos second page returned %d videos", len(page))
+	}
+	for status, want := range map[string]int{VideoStatusDraft: 1, VideoStatusIncomplete: 1, VideoStatusReady: 0} {
+		filtered, _, err := s.ListVideos(ListVideosParams{UserID: user.ID, Limit: 10, Sort: VideoSortCreated, Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(filtered) != want {
+			t.Fatalf("ListVideos with status %s returned %d videos, want %d", status, len(filtered), want)
+		}
+	}
+
+	loud, err := s.GetVideosOutsideLoudness(user.ID, -24, -22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(loud) == 1 && loud[0].ID == video.ID) {
+		t.Fatalf("GetVideosOutsideLoudness returned %d videos, want 1", len(loud))
+	}
+
+	if err := s.DeleteVideo(video.ID); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.ID != uuid.Nil {
+		t.Fatal("GetVideo found a deleted video")
+	}
+}
+
+func conformanceVideoVisibility(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	other := conformanceUser(t, s)
+
+	private, err := s.CreateVideo(CreateVideoParams{Title: "private", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if private.Visibility != VisibilityPrivate {
+		t.Fatalf("CreateVideo without a visibility returned %q, want private", private.Visibility)
+	}
+	public, err := s.CreateVideo(CreateVideoParams{Title: "public", UserID: user.ID, Visibility: VisibilityUnlisted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	public.Visibility = VisibilityPublic
+	if err := s.UpdateVideo(public); err != nil {
+		t.Fatal(err)
+	}
+	othersPublic, err := s.CreateVideo(CreateVideoParams{Title: "other", UserID: other.ID, Visibility: VisibilityPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tag keeps the catalog listing to this check's videos.
+	tag := "catalog-" + user.ID.String()
+	for _, v := range []Video{private, public} {
+		if err := s.AddVideoTags(v.ID, user.ID, []string{tag}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddVideoTags(othersPublic.ID, other.ID, []string{tag}); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, _, err := s.ListVideos(ListVideosParams{Limit: 10, Sort: VideoSortTitle, Visibility: VisibilityPublic, Tags: []string{tag}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(catalog) == 2 && catalog[0].ID == othersPublic.ID && catalog[1].ID == public.ID) {
+		t.Fatalf("ListVideos of public videos returned %d videos, want 2", len(catalog))
+	}
+	own, _, err := s.ListVideos(ListVideosParams{UserID: user.ID, Limit: 10, Sort: VideoSortTitle, Visibility: VisibilityPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(own) == 1 && own[0].ID == private.ID) {
+		t.Fatalf("ListVideos of the user's private videos returned %d videos, want 1", len(own))
+	}
+}
+
+func conformanceVideoSearch(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	other := conformanceUser(t, s)
+
+	video, err := s.CreateVideo(CreateVideoParams{Title: "Boots & the <b>dragon</b>", Description: "A dragon story", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateVideo(CreateVideoParams{Title: "Dragons", UserID: other.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateVideo(CreateVideoParams{Title: "Unrelated", UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.SearchVideos(SearchVideosParams{UserID: user.ID, Query: "drag", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(results) == 1 && results[0].ID == video.ID) {
+		t.Fatalf("prefix search returned %d results, want 1", len(results))
+	}
+	want := "Boots &amp; the &lt;b&gt;<mark>dragon</mark>&lt;/b&gt;"
+	if results[0].TitleSnippet != want {
+		t.Fatalf("title snippet is %q, want %q", results[0].TitleSnippet, want)
+	}
+
+	video.Title = "Renamed"
+	video.Description = ""
+	if err := s.UpdateVideo(video); err != nil {
+		t.Fatal(err)
+	}
+	results, err = s.SearchVideos(SearchVideosParams{UserID: user.ID, Query: "dragon", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatal("search matched text removed by UpdateVideo")
+	}
+
+	if err := s.DeleteVideo(video.ID); err != nil {
+		t.Fatal(err)
+	}
+	results, err = s.SearchVideos(SearchVideosParams{UserID: user.ID, Query: "renamed", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatal("search found a deleted video")
+	}
+}
+
+func conformanceTrash(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	video, err := s.CreateVideo(CreateVideoParams{Title: "trashed", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateVideo(CreateVideoParams{Title: "kept", UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.TrashVideo(video.ID); err != nil {
+		t.Fatal(err)
+	}
+	hidden, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hidden.ID != uuid.Nil {
+		t.Fatal("GetVideo found a trashed video")
+	}
+	videos, err := s.GetVideos(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 {
+		t.Fatalf("GetVideos returned %d videos, want 1", len(videos))
+	}
+	listed, _, err := s.ListVideos(ListVideosParams{UserID: user.ID, Limit: 10, Sort: VideoSortCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 {
+		t.Fatalf("ListVideos returned %d videos, want 1", len(listed))
+	}
+
+	trashed, err := s.GetDeletedVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(trashed.ID == video.ID && trashed.DeletedAt != nil) {
+		t.Fatalf("GetDeletedVideo returned %+v", trashed)
+	}
+	trash, err := s.GetDeletedVideos(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(trash) == 1 && trash[0].ID == video.ID) {
+		t.Fatalf("GetDeletedVideos returned %d videos, want 1", len(trash))
+	}
+
+	expired, err := s.GetVideosDeletedBefore(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 0 {
+		t.Fatal("GetVideosDeletedBefore returned a video trashed just now")
+	}
+	expired, err = s.GetVideosDeletedBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 {
+		t.Fatalf("GetVideosDeletedBefore returned %d videos, want 1", len(expired))
+	}
+
+	if err := s.RestoreVideo(video.ID); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(restored.ID == video.ID && restored.DeletedAt == nil) {
+		t.Fatal("RestoreVideo didn't restore the video")
+	}
+}
+
+func conformanceVideoVersions(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	video, err := s.CreateVideo(CreateVideoParams{Title: "versioned", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := int64(1) << 33
+	checksum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	first, err := s.CreateVideoVersion(CreateVideoVersionParams{VideoID: video.ID, VideoURL: "https://example.com/1.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateVideoVersion(CreateVideoVersionParams{
+		VideoID:        video.ID,
+		VideoURL:       "https://example.com/2.mp4",
+		SizeBytes:      &size,
+		ChecksumSHA256: &checksum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(first.Version == 1 && second.Version == 2) {
+		t.Fatalf("versions numbered %d and %d, want 1 and 2", first.Version, second.Version)
+	}
+	if !(second.SizeBytes != nil && *second.SizeBytes == size) {
+		t.Fatalf("size_bytes round-tripped as %v", second.SizeBytes)
+	}
+
+	video.CurrentVersionID = &first.ID
+	if err := s.UpdateVideo(video); err != nil {
+		t.Fatal(err)
+	}
+	current, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(current.CurrentVersionID != nil && *current.CurrentVersionID == first.ID) {
+		t.Fatal("UpdateVideo didn't store current_version_id")
+	}
+
+	versions, err := s.GetVideoVersions(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(versions) == 2 && versions[0].ID == second.ID) {
+		t.Fatalf("GetVideoVersions returned %d versions, newest %v", len(versions), versions)
+	}
+
+	if err := s.DeleteVideoVersion(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteVideo(video.ID); err != nil {
+		t.Fatalf("DeleteVideo with versions: %v", err)
+	}
+	versions, err = s.GetVideoVersions(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 0 {
+		t.Fatal("DeleteVideo left versions behind")
+	}
+}
+
+func conformanceSubtitleTracks(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	video, err := s.CreateVideo(CreateVideoParams{Title: "title", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := s.CreateSubtitleTrack(CreateSubtitleTrackParams{
+		VideoID:  video.ID,
+		Language: "en",
+		Label:    "English",
+		URL:      "https://example.com/en.vtt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(track.ID != uuid.Nil && track.VideoID == video.ID && track.Language == "en") {
+		t.Fatalf("CreateSubtitleTrack returned %+v", track)
+	}
+
+	withTracks, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withTracks.SubtitleTracks) != 1 {
+		t.Fatalf("GetVideo returned %d subtitle tracks, want 1", len(withTracks.SubtitleTracks))
+	}
+
+	if err := s.DeleteSubtitleTrack(track.ID); err != nil {
+		t.Fatal(err)
+	}
+	tracks, err := s.GetSubtitleTracks(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 0 {
+		t.Fatal("GetSubtitleTracks returned a deleted track")
+	}
+
+	if _, err := s.CreateSubtitleTrack(CreateSubtitleTrackParams{VideoID: video.ID, Language: "fr", Label: "Français", URL: "u"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteVideo(video.ID); err != nil {
+		t.Fatalf("DeleteVideo with subtitle tracks: %v", err)
+	}
+	tracks, err = s.GetSubtitleTracks(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 0 {
+		t.Fatal("DeleteVideo left subtitle tracks behind")
+	}
+}
+
+func conformanceTags(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	video, err := s.CreateVideo(CreateVideoParams{Title: "tagged", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	untagged, err := s.CreateVideo(CreateVideoParams{Title: "untagged", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddVideoTags(video.ID, user.ID, []string{"travel", "food"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddVideoTags(video.ID, user.ID, []string{"food", "100%_real"}); err != nil {
+		t.Fatalf("AddVideoTags with an existing tag: %v", err)
+	}
+	if err := s.AddVideoTags(untagged.ID, user.ID, []string{"food"}); err != nil {
+		t.Fatal(err)
+	}
+
+	withTags, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(withTags.Tags) != "[100%_real food travel]" {
+		t.Fatalf("GetVideo returned tags %v", withTags.Tags)
+	}
+
+	suggestions, err := s.SuggestTags(user.ID, "f", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(suggestions) == 1 && suggestions[0].Name == "food" && suggestions[0].VideoCount == 2) {
+		t.Fatalf("SuggestTags returned %+v", suggestions)
+	}
+	suggestions, err = s.SuggestTags(user.ID, "100%_", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 {
+		t.Fatalf("SuggestTags didn't escape LIKE wildcards: %+v", suggestions)
+	}
+
+	listed, _, err := s.ListVideos(ListVideosParams{UserID: user.ID, Limit: 10, Sort: VideoSortCreated, Tags: []string{"food", "travel"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(listed) == 1 && listed[0].ID == video.ID) {
+		t.Fatalf("ListVideos filtered by tags returned %d videos, want 1", len(listed))
+	}
+
+	if err := s.RemoveVideoTag(video.ID, "travel"); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := s.GetVideoTags(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 {
+		t.Fatalf("RemoveVideoTag left %v", tags)
+	}
+
+	if err := s.DeleteVideo(video.ID); err != nil {
+		t.Fatalf("DeleteVideo with tags: %v", err)
+	}
+	suggestions, err = s.SuggestTags(user.ID, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(suggestions) == 1 && suggestions[0].VideoCount == 1) {
+		t.Fatalf("SuggestTags after DeleteVideo returned %+v", suggestions)
+	}
+}
+
+func conformancePlaylists(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	playlist, err := s.CreatePlaylist(CreatePlaylistParams{Title: "favorites", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(playlist.ID != uuid.Nil && playlist.ItemCount == 0 && playlist.CoverURL == nil) {
+		t.Fatalf("CreatePlaylist returned %+v", playlist)
+	}
+
+	thumbnail := "https://example.com/thumb.png"
+	items := []PlaylistItem{}
+	for _, title := range []string{"a", "b", "c"} {
+		video, err := s.CreateVideo(CreateVideoParams{Title: title, UserID: user.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		video.ThumbnailURL = &thumbnail
+		if err := s.UpdateVideo(video); err != nil {
+			t.Fatal(err)
+		}
+		item, err := s.AddPlaylistItem(playlist.ID, video.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	if _, err := s.AddPlaylistItem(playlist.ID, items[0].Video.ID); !errors.Is(err, ErrVideoInPlaylist) {
+		t.Fatalf("AddPlaylistItem with a duplicate video returned %v, want ErrVideoInPlaylist", err)
+	}
+
+	order := func() (string, error) {
+		p, err := s.GetPlaylist(playlist.ID)
+		if err != nil {
+			return "", err
+		}
+		titles := ""
+		for _, item := range p.Items {
+			titles += item.Video.Title
+		}
+		return titles, nil
+	}
+
+	if err := s.MovePlaylistItem(items[2].ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := order(); err != nil || got != "cab" {
+		t.Fatalf("order after MovePlaylistItem is %q (%v), want cab", got, err)
+	}
+
+	if err := s.ReorderPlaylist(playlist.ID, []uuid.UUID{items[1].ID, items[0].ID}); !errors.Is(err, ErrInvalidOrder) {
+		t.Fatalf("ReorderPlaylist with a missing item returned %v, want ErrInvalidOrder", err)
+	}
+	if err := s.ReorderPlaylist(playlist.ID, []uuid.UUID{items[1].ID, items[0].ID, items[2].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := order(); err != nil || got != "bac" {
+		t.Fatalf("order after ReorderPlaylist is %q (%v), want bac", got, err)
+	}
+
+	if err := s.DeleteVideo(items[0].Video.ID); err != nil {
+		t.Fatalf("DeleteVideo in a playlist: %v", err)
+	}
+	if err := s.RemovePlaylistItem(items[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	remaining, err := s.GetPlaylist(playlist.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(remaining.ItemCount == 1 && len(remaining.Items) == 1 && remaining.Items[0].Position == 0) {
+		t.Fatalf("playlist after removals is %+v", remaining)
+	}
+	if !(remaining.CoverURL != nil && *remaining.CoverURL == thumbnail) {
+		t.Fatalf("playlist cover is %v", remaining.CoverURL)
+	}
+
+	if err := s.DeletePlaylist(playlist.ID); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.GetPlaylist(playlist.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.ID != uuid.Nil {
+		t.Fatal("GetPlaylist found a deleted playlist")
+	}
+}
+
+func conformanceWatermarkSettings(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+
+	settings, err := s.GetWatermarkSettings(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(settings.Enabled && settings.Position == nil) {
+		t.Fatalf("default watermark settings are %+v", settings)
+	}
+
+	position := "top-left"
+	opacity := 0.5
+	settings.Enabled = false
+	settings.Position = &position
+	settings.Opacity = &opacity
+	settings, err = s.UpsertWatermarkSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(!settings.Enabled && settings.Position != nil && *settings.Position == position) {
+		t.Fatalf("UpsertWatermarkSettings returned %+v", settings)
+	}
+
+	settings.Enabled = true
+	settings, err = s.UpsertWatermarkSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(settings.Enabled && settings.Opacity != nil && *settings.Opacity == opacity) {
+		t.Fatalf("second UpsertWatermarkSettings returned %+v", settings)
+	}
+}
+
+func conformanceExports(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+
+	export, err := s.CreateExport(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(export.Status == ExportStatusPending && export.UserID == user.ID) {
+		t.Fatalf("CreateExport returned %+v", export)
+	}
+	stale, err := s.CreateExport(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "exports/archive.zip"
+	size := int64(1234)
+	now := time.Now().UTC()
+	expiresAt := now.Add(-time.Minute)
+	export.Status = ExportStatusReady
+	export.ObjectKey = &key
+	export.SizeBytes = &size
+	export.CompletedAt = &now
+	export.ExpiresAt = &expiresAt
+	if err := s.UpdateExport(export); err != nil {
+		t.Fatal(err)
+	}
+	ready, err := s.GetExport(export.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(ready.Status == ExportStatusReady && ready.ObjectKey != nil && *ready.ObjectKey == key && ready.SizeBytes != nil && *ready.SizeBytes == size) {
+		t.Fatalf("UpdateExport stored %+v", ready)
+	}
+
+	failed, err := s.FailUnfinishedExports("interrupted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 {
+		t.Fatalf("FailUnfinishedExports failed %d exports, want 1", failed)
+	}
+	stale, err = s.GetExport(stale.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(stale.Status == ExportStatusFailed && stale.Error != nil && *stale.Error == "interrupted") {
+		t.Fatalf("unfinished export became %+v", stale)
+	}
+
+	exports, err := s.GetExports(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exports) != 2 {
+		t.Fatalf("GetExports returned %d exports, want 2", len(exports))
+	}
+	expired, err := s.GetExportsExpiredBefore(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(len(expired) == 1 && expired[0].ID == export.ID) {
+		t.Fatalf("GetExportsExpiredBefore returned %d exports", len(expired))
+	}
+
+	if err := s.DeleteExport(export.ID); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.GetExport(export.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.ID != uuid.Nil {
+		t.Fatal("GetExport found a deleted export")
+	}
+}
+
+func conformanceAuditEvents(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+	other := uuid.New()
+
+	event, err := s.CreateAuditEvent(CreateAuditEventParams{
+		Action:     "user.login",
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID.String(),
+		IP:         "192.0.2.1",
+		Outcome:    AuditOutcomeFailure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(event.ID != uuid.Nil && event.ActorID == nil && event.IP == "192.0.2.1") {
+		t.Fatalf("CreateAuditEvent returned %+v", event)
+	}
+	for _, actor := range []uuid.UUID{user.ID, user.ID, other} {
+		_, err := s.CreateAuditEvent(CreateAuditEventParams{
+			ActorID:    &actor,
+			Action:     "video.delete",
+			TargetType: "video",
+			TargetID:   uuid.NewString(),
+			Outcome:    AuditOutcomeSuccess,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, _, err := s.ListAuditEvents(ListAuditEventsParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("ListAuditEvents returned %d events, want 4", len(all))
+	}
+
+	// The failed login targets the user, so it is theirs even without an actor.
+	own, _, err := s.ListAuditEvents(ListAuditEventsParams{UserID: user.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(own) != 3 {
+		t.Fatalf("ListAuditEvents for a user returned %d events, want 3", len(own))
+	}
+	deletes, _, err := s.ListAuditEvents(ListAuditEventsParams{UserID: user.ID, Action: "video.delete", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deletes) != 2 {
+		t.Fatalf("ListAuditEvents by action returned %d events, want 2", len(deletes))
+	}
+
+	seen := map[uuid.UUID]bool{}
+	var after *AuditEventCursor
+	for range 4 {
+		page, next, err := s.ListAuditEvents(ListAuditEventsParams{Limit: 1, After: after})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !(len(page) == 1 && !seen[page[0].ID]) {
+			t.Fatalf("paging audit events returned %+v", page)
+		}
+		seen[page[0].ID] = true
+		after = next
+	}
+	if after != nil {
+		t.Fatal("last page of audit events has a cursor")
+	}
+
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	kept, _, err := s.ListAuditEvents(ListAuditEventsParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 4 {
+		t.Fatalf("ListAuditEvents after Reset returned %d events, want 4", len(kept))
+	}
+}
+
+func conformanceUnitsOfWork(t *testing.T, s Store) {
+	user := conformanceUser(t, s)
+
+	undone := []string{}
+	uow, err := s.BeginTx(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uow.Compensate(func() error {
+		undone = append(undone, "first")
+		return nil
+	})
+	video, err := uow.CreateVideo(CreateVideoParams{Title: "rolled back", UserID: user.ID})
+	if err != nil {
+		uow.Rollback()
+		t.Fatal(err)
+	}
+	version, err := uow.CreateVideoVersion(CreateVideoVersionParams{VideoID: video.ID, VideoURL: "https://example.com/v.mp4"})
+	if err != nil {
+		uow.Rollback()
+		t.Fatal(err)
+	}
+	uow.Compensate(func() error {
+		undone = append(undone, "second")
+		return errors.New("object is gone")
+	})
+	err = uow.Rollback()
+	if err == nil {
+		t.Fatal("Rollback hid a compensation's error")
+	}
+	if !(len(undone) == 2 && undone[0] == "second" && undone[1] == "first") {
+		t.Fatalf("Rollback ran compensations %v, want newest first", undone)
+	}
+	gone, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goneVersion, err := s.GetVideoVersion(version.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(gone.ID == uuid.Nil && goneVersion.ID == uuid.Nil) {
+		t.Fatal("Rollback kept rows written in the unit")
+	}
+
+	undone = undone[:0]
+	uow, err = s.BeginTx(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uow.Compensate(func() error {
+		undone = append(undone, "committed")
+		return nil
+	})
+	video, err = uow.CreateVideo(CreateVideoParams{Title: "committed", UserID: user.ID})
+	if err != nil {
+		uow.Rollback()
+		t.Fatal(err)
+	}
+	video.Title = "renamed"
+	if err := uow.UpdateVideo(video); err != nil {
+		uow.Rollback()
+		t.Fatal(err)
+	}
+	if err := uow.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := uow.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 0 {
+		t.Fatal("Rollback after Commit ran compensations")
+	}
+	if _, err := uow.GetVideo(video.ID); !errors.Is(err, ErrUnitOfWorkDone) {
+		t.Fatalf("using a committed unit returned %v, want ErrUnitOfWorkDone", err)
+	}
+	kept, err := s.GetVideo(video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(kept.Title == "renamed" && kept.Revision == 2) {
+		t.Fatalf("Commit stored %+v", kept)
+	}
+}
@@ -2,88 +2,102 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
-type Client struct {
-	db *sql.DB
-}
-
-func NewClient(pathToDB string) (Client, error) {
-	db, err := sql.Open("sqlite3", pathToDB)
+// NewClient connects to the database at databaseURL and applies any pending
+// migrations. See parseDatabaseURL for the accepted forms.
+func NewClient(databaseURL string) (Client, error) {
+	c, err := Open(databaseURL)
 	if err != nil {
 		return Client{}, err
 	}
-	c := Client{db}
-	err = c.autoMigrate()
+	_, err = c.MigrateUp()
 	if err != nil {
 		return Client{}, err
 	}
 	return c, nil
-
 }
 
-func (c *Client) autoMigrate() error {
-	userTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id TEXT PRIMARY KEY,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		password TEXT NOT NULL,
-		email TEXT UNIQUE NOT NULL
-	);
-	`
-	_, err := c.db.Exec(userTable)
+// Open connects to the database without touching its schema.
+func Open(databaseURL string) (Client, error) {
+	d, dsn, err := parseDatabaseURL(databaseURL)
 	if err != nil {
-		return err
+		return Client{}, err
 	}
-	refreshTokenTable := `
-	CREATE TABLE IF NOT EXISTS refresh_tokens (
-		token TEXT PRIMARY KEY,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		revoked_at TIMESTAMP,
-		user_id TEXT NOT NULL,
-		expires_at TIMESTAMP NOT NULL,
-		FOREIGN KEY(user_id) REFERENCES users(id)
-	);
-	`
-	_, err = c.db.Exec(refreshTokenTable)
+	db, err := sql.Open(d.driver, dsn)
 	if err != nil {
-		return err
+		return Client{}, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return Client{}, err
+	}
+	c := Client{db: db, dialect: d}
+	err = c.checkFeatures()
+	if err != nil {
+		db.Close()
+		return Client{}, err
 	}
+	return c, nil
+}
 
-	videoTable := `
-	CREATE TABLE IF NOT EXISTS videos (
-		id TEXT PRIMARY KEY,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		title TEXT NOT NULL,
-		description TEXT,
-		thumbnail_url TEXT,
-		video_url TEXT TEXT,
-		user_id INTEGER,
-		FOREIGN KEY(user_id) REFERENCES users(id)
-	);
-	`
-	_, err = c.db.Exec(videoTable)
+// checkFeatures fails early when the database lacks something the schema
+// depends on, rather than on the first query that needs it.
+func (c Client) checkFeatures() error {
+	if c.dialect != dialectSQLite {
+		return nil
+	}
+	fts5, err := c.sqliteHasFTS5()
+	if err != nil || fts5 {
+		return err
+	}
+	// Without FTS5 even inserting a video fails once the search index
+	// exists, since triggers keep the index up to date.
+	indexed, err := c.tableExists("videos_search")
 	if err != nil {
 		return err
 	}
+	if indexed {
+		return errors.New("this database has a full-text search index, but SQLite was built without FTS5; build with -tags sqlite_fts5")
+	}
 	return nil
 }
 
+// sqliteHasFTS5 reports whether the SQLite library was compiled with FTS5,
+// which the sqlite_fts5 build tag turns on.
+func (c Client) sqliteHasFTS5() (bool, error) {
+	var fts5 bool
+	err := c.queryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5)
+	return fts5, err
+}
+
+func (c Client) Close() error {
+	return c.db.Close()
+}
+
+// Reset deletes every row, children before parents so it also works where
+// foreign keys are enforced. The audit log is append-only and is kept.
 func (c Client) Reset() error {
-	if _, err := c.db.Exec("DELETE FROM refresh_tokens"); err != nil {
-		return fmt.Errorf("failed to reset table refresh_tokens: %w", err)
-	}
-	if _, err := c.db.Exec("DELETE FROM users"); err != nil {
-		return fmt.Errorf("failed to reset table users: %w", err)
-	}
-	if _, err := c.db.Exec("DELETE FROM videos"); err != nil {
-		return fmt.Errorf("failed to reset table videos: %w", err)
+	for _, table := range []string{
+		"playlist_items",
+		"playlists",
+		"subtitle_tracks",
+		"video_versions",
+		"video_tags",
+		"tags",
+		"videos",
+		"refresh_tokens",
+		"api_keys",
+		"exports",
+		"watermark_settings",
+		"users",
+	} {
+		if _, err := c.exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("failed to reset table %s: %w", table, err)
+		}
 	}
 	return nil
 }
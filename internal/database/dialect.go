@@ -0,0 +1,196 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// dialect captures what differs between the SQL backends. Queries are
+// written with "?" placeholders and rebound for backends that number them.
+type dialect struct {
+	name                 string
+	driver               string
+	numberedPlaceholders bool
+}
+
+var (
+	dialectSQLite   = dialect{name: "sqlite", driver: "sqlite3_tubely"}
+	dialectPostgres = dialect{name: "postgres", driver: "postgres", numberedPlaceholders: true}
+)
+
+// The SQLite driver is registered with the functions migrations need that
+// PostgreSQL has built in. sha256_hex(text) matches PostgreSQL's
+// encode(sha256(convert_to(text, 'UTF8')), 'hex'). search_rank ranks
+// searches when SQLite was built without FTS5; see scanSearchVideos.
+func init() {
+	sql.Register(dialectSQLite.driver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			err := conn.RegisterFunc("sha256_hex", sha256Hex, true)
+			if err != nil {
+				return err
+			}
+			return conn.RegisterFunc("search_rank", searchRank, true)
+		},
+	})
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// rebind rewrites "?" placeholders to "$1", "$2", ... when the backend
+// expects numbered placeholders. Question marks inside quoted literals are
+// left alone.
+func (d dialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+		case r == '?' && !inQuote:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d dialect) tableExistsQuery() string {
+	if d.name == dialectPostgres.name {
+		return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?"
+	}
+	return "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+}
+
+// parseDatabaseURL picks the backend from the URL scheme. postgres:// and
+// postgresql:// URLs select PostgreSQL; sqlite:// URLs and plain file paths
+// select SQLite.
+func parseDatabaseURL(databaseURL string) (dialect, string, error) {
+	scheme, rest, hasScheme := strings.Cut(databaseURL, "://")
+	if !hasScheme {
+		return dialectSQLite, sqliteDSN(databaseURL), nil
+	}
+
+	switch scheme {
+	case "sqlite", "sqlite3":
+		return dialectSQLite, sqliteDSN(rest), nil
+	case "postgres", "postgresql":
+		u, err := url.Parse(databaseURL)
+		if err != nil {
+			return dialect{}, "", fmt.Errorf("invalid database URL: %w", err)
+		}
+		// Timestamps are stored without a time zone, so every session has
+		// to agree on one.
+		q := u.Query()
+		if q.Get("timezone") == "" {
+			q.Set("timezone", "UTC")
+		}
+		u.RawQuery = q.Encode()
+		return dialectPostgres, u.String(), nil
+	default:
+		return dialect{}, "", fmt.Errorf("unsupported database URL scheme %q", scheme)
+	}
+}
+
+// sqliteBusyTimeout is how long, in milliseconds, a SQLite connection waits
+// for another one's write lock before failing with "database is locked".
+const sqliteBusyTimeout = 5000
+
+// sqliteDSN sets a busy timeout on a SQLite DSN that doesn't set one.
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_busy_timeout=") || strings.Contains(dsn, "_timeout=") {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_busy_timeout=" + strconv.Itoa(sqliteBusyTimeout)
+}
+
+// Client talks to the database through the dialect's rebinding so the same
+// queries serve every backend.
+type Client struct {
+	db      *sql.DB
+	dialect dialect
+	// tx is set on the Client behind a UnitOfWork, so every method runs in
+	// the unit's transaction.
+	tx *sql.Tx
+}
+
+func (c Client) exec(query string, args ...any) (sql.Result, error) {
+	if c.tx != nil {
+		return c.tx.Exec(c.dialect.rebind(query), args...)
+	}
+	return c.db.Exec(c.dialect.rebind(query), args...)
+}
+
+func (c Client) query(query string, args ...any) (*sql.Rows, error) {
+	if c.tx != nil {
+		return c.tx.Query(c.dialect.rebind(query), args...)
+	}
+	return c.db.Query(c.dialect.rebind(query), args...)
+}
+
+func (c Client) queryRow(query string, args ...any) *sql.Row {
+	if c.tx != nil {
+		return c.tx.QueryRow(c.dialect.rebind(query), args...)
+	}
+	return c.db.QueryRow(c.dialect.rebind(query), args...)
+}
+
+// transaction wraps a *sql.Tx with the same placeholder rebinding as Client.
+type transaction struct {
+	tx      *sql.Tx
+	dialect dialect
+}
+
+func (t transaction) exec(query string, args ...any) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t transaction) query(query string, args ...any) (*sql.Rows, error) {
+	return t.tx.Query(t.dialect.rebind(query), args...)
+}
+
+func (t transaction) queryRow(query string, args ...any) *sql.Row {
+	return t.tx.QueryRow(t.dialect.rebind(query), args...)
+}
+
+// inTransaction runs fn in a transaction, committing if it returns nil and
+// rolling back otherwise. Inside a UnitOfWork, fn joins the unit's
+// transaction instead, which commits or rolls back with the unit.
+func (c Client) inTransaction(fn func(t transaction) error) error {
+	if c.tx != nil {
+		return fn(transaction{tx: c.tx, dialect: c.dialect})
+	}
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(transaction{tx: tx, dialect: c.dialect})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
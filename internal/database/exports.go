@@ -0,0 +1,182 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Export statuses in the order an export goes through them. An export ends
+// up either ready or failed.
+const (
+	ExportStatusPending = "pending"
+	ExportStatusRunning = "running"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+// Export is an archive of everything a user uploaded, built in the
+// background.
+type Export struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Status    string    `json:"status"`
+	// ObjectKey is where the archive is stored once the export is ready.
+	ObjectKey   *string    `json:"-"`
+	SizeBytes   *int64     `json:"size_bytes"`
+	Error       *string    `json:"error"`
+	CompletedAt *time.Time `json:"completed_at"`
+	// ExpiresAt is when a ready archive is deleted.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+const exportColumns = `
+		id,
+		created_at,
+		user_id,
+		status,
+		object_key,
+		size_bytes,
+		error,
+		completed_at,
+		expires_at`
+
+func scanExport(row rowScanner) (Export, error) {
+	var e Export
+	err := row.Scan(
+		&e.ID,
+		&e.CreatedAt,
+		&e.UserID,
+		&e.Status,
+		&e.ObjectKey,
+		&e.SizeBytes,
+		&e.Error,
+		&e.CompletedAt,
+		&e.ExpiresAt,
+	)
+	return e, err
+}
+
+// CreateExport records a pending export for the user.
+func (c Client) CreateExport(userID uuid.UUID) (Export, error) {
+	id := uuid.New()
+	query := `
+	INSERT INTO exports (id, created_at, user_id, status)
+	VALUES (?, CURRENT_TIMESTAMP, ?, ?)
+	`
+	_, err := c.exec(query, id, userID, ExportStatusPending)
+	if err != nil {
+		return Export{}, err
+	}
+
+	return c.GetExport(id)
+}
+
+// GetExport returns an export, or a zero Export if it doesn't exist.
+func (c Client) GetExport(id uuid.UUID) (Export, error) {
+	query := `
+	SELECT` + exportColumns + `
+	FROM exports
+	WHERE id = ?
+	`
+	e, err := scanExport(c.queryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Export{}, nil
+		}
+		return Export{}, err
+	}
+	return e, nil
+}
+
+// GetExports returns the user's exports, newest first.
+func (c Client) GetExports(userID uuid.UUID) ([]Export, error) {
+	query := `
+	SELECT` + exportColumns + `
+	FROM exports
+	WHERE user_id = ?
+	ORDER BY created_at DESC
+	`
+	return c.queryExports(query, userID)
+}
+
+// GetExportsExpiredBefore returns every user's exports that expired before
+// cutoff.
+func (c Client) GetExportsExpiredBefore(cutoff time.Time) ([]Export, error) {
+	query := `
+	SELECT` + exportColumns + `
+	FROM exports
+	WHERE expires_at IS NOT NULL AND expires_at < ?
+	ORDER BY expires_at
+	`
+	return c.queryExports(query, formatTimestamp(cutoff))
+}
+
+func (c Client) queryExports(query string, args ...any) ([]Export, error) {
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []Export{}
+	for rows.Next() {
+		e, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// UpdateExport saves an export's progress.
+func (c Client) UpdateExport(e Export) error {
+	query := `
+	UPDATE exports
+	SET
+		status = ?,
+		object_key = ?,
+		size_bytes = ?,
+		error = ?,
+		completed_at = ?,
+		expires_at = ?
+	WHERE id = ?
+	`
+	var completedAt, expiresAt *string
+	if e.CompletedAt != nil {
+		s := formatTimestamp(*e.CompletedAt)
+		completedAt = &s
+	}
+	if e.ExpiresAt != nil {
+		s := formatTimestamp(*e.ExpiresAt)
+		expiresAt = &s
+	}
+	_, err := c.exec(query, e.Status, e.ObjectKey, e.SizeBytes, e.Error, completedAt, expiresAt, e.ID)
+	return err
+}
+
+// FailUnfinishedExports marks exports that were pending or running as
+// failed. The server calls it on startup, since their jobs died with the
+// previous process.
+func (c Client) FailUnfinishedExports(reason string) (int, error) {
+	query := `
+	UPDATE exports
+	SET status = ?, error = ?, completed_at = CURRENT_TIMESTAMP
+	WHERE status IN (?, ?)
+	`
+	result, err := c.exec(query, ExportStatusFailed, reason, ExportStatusPending, ExportStatusRunning)
+	if err != nil {
+		return 0, err
+	}
+	failed, err := result.RowsAffected()
+	return int(failed), err
+}
+
+func (c Client) DeleteExport(id uuid.UUID) error {
+	_, err := c.exec("DELETE FROM exports WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,365 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration files are named NNNN_name.up.sql and NNNN_name.down.sql and are
+// shared by every backend. A file with a variant before the extension,
+// such as NNNN_name.up.postgres.sql, replaces the shared file for databases
+// of that variant when the SQL can't be written portably. See
+// migrationVariants for which variants apply.
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+?)\.(up|down)(?:\.(sqlite|sqlite_nofts5|postgres))?\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// migrationVariants lists the variants whose migration files apply to the
+// database, from least to most specific. SQLite built without FTS5 gets
+// its own variant so the search migration can skip the index it can't
+// create.
+func (c Client) migrationVariants() ([]string, error) {
+	if c.dialect != dialectSQLite {
+		return []string{c.dialect.name}, nil
+	}
+	fts5, err := c.sqliteHasFTS5()
+	if err != nil {
+		return nil, err
+	}
+	if !fts5 {
+		return []string{dialectSQLite.name, "sqlite_nofts5"}, nil
+	}
+	return []string{dialectSQLite.name}, nil
+}
+
+// loadMigrations reads the embedded migrations for the given variants,
+// sorted by version. Of the files for one script, the most specific
+// variant wins. Every migration must have both an up and a down script.
+func loadMigrations(variants []string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	// Scripts are kept with their specificity: 0 for shared files, i+1
+	// for variants[i].
+	type script struct {
+		contents    string
+		specificity int
+	}
+	type scripts struct {
+		name     string
+		up, down script
+	}
+	byVersion := map[int]*scripts{}
+	for _, entry := range entries {
+		m := migrationFileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", entry.Name())
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		specificity := 0
+		if m[4] != "" {
+			specificity = slices.Index(variants, m[4]) + 1
+			if specificity == 0 {
+				continue
+			}
+		}
+		contents, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := byVersion[version]
+		if !ok {
+			s = &scripts{name: m[2]}
+			byVersion[version] = s
+		}
+		if s.name != m[2] {
+			return nil, fmt.Errorf("migration %d has files named %q and %q", version, s.name, m[2])
+		}
+		dest := &s.down
+		if m[3] == "up" {
+			dest = &s.up
+		}
+		if dest.contents == "" || specificity > dest.specificity {
+			*dest = script{contents: string(contents), specificity: specificity}
+		}
+	}
+
+	migrations := []Migration{}
+	for version, s := range byVersion {
+		migration := Migration{Version: version, Name: s.name, Up: s.up.contents, Down: s.down.contents}
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script for %s", version, s.name, variants[len(variants)-1])
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func (c Client) ensureMigrationsTable() error {
+	exists, err := c.tableExists("schema_migrations")
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	err = c.prepareLegacySchema()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.exec(`
+	CREATE TABLE schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+	return err
+}
+
+func (c Client) tableExists(name string) (bool, error) {
+	var count int
+	err := c.queryRow(c.dialect.tableExistsQuery(), name).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// prepareLegacySchema brings a database created by the old autoMigrate up
+// to the final shape autoMigrate gave it, so the first migration can copy
+// every column when it rebuilds the videos table. Only SQLite databases
+// predate migrations.
+func (c Client) prepareLegacySchema() error {
+	if c.dialect != dialectSQLite {
+		return nil
+	}
+	exists, err := c.tableExists("videos")
+	if err != nil || !exists {
+		return err
+	}
+
+	for _, column := range []struct{ name, definition string }{
+		{"preview_url", "TEXT"},
+		{"preview_video_url", "TEXT"},
+		{"integrated_loudness", "REAL"},
+	} {
+		err = c.addColumnIfMissing("videos", column.name, column.definition)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table that was created before the
+// column existed.
+func (c Client) addColumnIfMissing(table, column, definition string) error {
+	rows, err := c.query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   bool
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = c.exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func (c Client) appliedMigrations() (map[int]time.Time, error) {
+	rows, err := c.query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction, and returns the ones it applied.
+func (c Client) MigrateUp() ([]Migration, error) {
+	err := c.ensureMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+	variants, err := c.migrationVariants()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations(variants)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := c.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := c.runMigration(migration.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(c.dialect.rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"), migration.Version, migration.Name)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// MigrateDown rolls back the most recently applied migrations, at most
+// steps of them, and returns the ones it rolled back.
+func (c Client) MigrateDown(steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, errors.New("steps must be at least 1")
+	}
+	err := c.ensureMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+	variants, err := c.migrationVariants()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations(variants)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := c.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := c.runMigration(migration.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(c.dialect.rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("rolling back migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// MigrationStatus lists every known migration and when it was applied.
+func (c Client) MigrationStatus() ([]MigrationStatus, error) {
+	err := c.ensureMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+	variants, err := c.migrationVariants()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations(variants)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := c.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (c Client) runMigration(script string, record func(tx *sql.Tx) error) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(script)
+	if err != nil {
+		return err
+	}
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []string
+		// search is the file the video search migration should come from.
+		search string
+	}{
+		{
+			name:     "sqlite",
+			variants: []string{"sqlite"},
+			search:   "0003_video_search.up.sqlite.sql",
+		},
+		{
+			name:     "sqlite without fts5",
+			variants: []string{"sqlite", "sqlite_nofts5"},
+			search:   "0003_video_search.up.sqlite_nofts5.sql",
+		},
+		{
+			name:     "postgres",
+			variants: []string{"postgres"},
+			search:   "0003_video_search.up.postgres.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.variants)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(migrations) == 0 {
+				t.Fatal("no migrations")
+			}
+			for i, migration := range migrations {
+				if migration.Version != i+1 {
+					t.Fatalf("migration %d has version %d; versions must be consecutive", i, migration.Version)
+				}
+				if migration.Up == "" || migration.Down == "" {
+					t.Errorf("migration %d_%s is missing a script", migration.Version, migration.Name)
+				}
+			}
+
+			search, err := migrationFiles.ReadFile("migrations/" + tt.search)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrations[2].Up != string(search) {
+				t.Errorf("migration 3 doesn't come from %s", tt.search)
+			}
+			// Migrations without variants use the shared file.
+			listing, err := migrationFiles.ReadFile("migrations/0002_video_listing.up.sql")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrations[1].Up != string(listing) {
+				t.Error("migration 2 doesn't come from the shared file")
+			}
+		})
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	statuses, err := c.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Fatalf("migration %d_%s wasn't applied by NewClient", status.Version, status.Name)
+		}
+	}
+
+	_, err = c.MigrateDown(0)
+	if err == nil {
+		t.Error("MigrateDown(0) succeeded")
+	}
+
+	down, err := c.MigrateDown(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(down) != 2 || down[0].Version != len(statuses) || down[1].Version != len(statuses)-1 {
+		t.Fatalf("MigrateDown(2) rolled back %+v, want the last two migrations, newest first", down)
+	}
+
+	down, err = c.MigrateDown(len(statuses))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(down) != len(statuses)-2 {
+		t.Fatalf("rolled back %d more migrations, want %d", len(down), len(statuses)-2)
+	}
+	statuses, err = c.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			t.Fatalf("migration %d_%s is still applied", status.Version, status.Name)
+		}
+	}
+
+	up, err := c.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(up) != len(statuses) {
+		t.Fatalf("applied %d migrations, want %d", len(up), len(statuses))
+	}
+	up, err = c.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(up) != 0 {
+		t.Fatalf("applied %d migrations twice", len(up))
+	}
+}
+
+// TestMigrateLegacySchema checks that a database created by the old
+// autoMigrate, before migrations existed, is brought up to date with its
+// rows intact.
+func TestMigrateLegacySchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tubely.db")
+	db, err := sql.Open(dialectSQLite.driver, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, videoID := uuid.New(), uuid.New()
+	for _, query := range []string{
+		`CREATE TABLE users (
+			id TEXT PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			password TEXT NOT NULL,
+			email TEXT UNIQUE NOT NULL
+		)`,
+		`CREATE TABLE refresh_tokens (
+			token TEXT PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			revoked_at TIMESTAMP,
+			user_id TEXT NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		)`,
+		`CREATE TABLE videos (
+			id TEXT PRIMARY KEY,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			title TEXT NOT NULL,
+			description TEXT,
+			thumbnail_url TEXT,
+			video_url TEXT TEXT,
+			user_id INTEGER,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		)`,
+		`INSERT INTO users (id, password, email) VALUES ('` + userID.String() + `', 'hash', 'old@example.com')`,
+		`INSERT INTO videos (id, title, description, video_url, user_id)
+		VALUES ('` + videoID.String() + `', 'Old video', 'From before migrations', 'https://cdn.example.com/old.mp4', '` + userID.String() + `')`,
+	} {
+		_, err := db.Exec(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	c, err := NewClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	user, err := c.GetUserByEmail("old@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != userID {
+		t.Errorf("got user %s, want %s", user.ID, userID)
+	}
+	video, err := c.GetVideo(videoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video.ID != videoID || video.Title != "Old video" || video.Description != "From before migrations" || video.UserID != userID {
+		t.Errorf("got video %+v", video)
+	}
+	if video.VideoURL == nil || *video.VideoURL != "https://cdn.example.com/old.mp4" {
+		t.Errorf("got video URL %v", video.VideoURL)
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE watermark_settings;
+DROP TABLE subtitle_tracks;
+DROP TABLE videos;
+DROP TABLE refresh_tokens;
+DROP TABLE users;
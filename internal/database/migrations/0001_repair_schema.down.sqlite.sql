@@ -0,0 +1,29 @@
+-- Puts videos back the way the old autoMigrate declared it. The other
+-- tables are left in place so rolling back never loses data.
+DROP INDEX IF EXISTS idx_videos_user_id;
+DROP INDEX IF EXISTS idx_refresh_tokens_user_id;
+DROP INDEX IF EXISTS idx_subtitle_tracks_video_id;
+
+CREATE TABLE videos_legacy (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT,
+	thumbnail_url TEXT,
+	video_url TEXT TEXT,
+	preview_url TEXT,
+	preview_video_url TEXT,
+	integrated_loudness REAL,
+	user_id INTEGER,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+INSERT INTO videos_legacy
+SELECT
+	id, created_at, updated_at, title, description, thumbnail_url, video_url,
+	preview_url, preview_video_url, integrated_loudness, user_id
+FROM videos;
+
+DROP TABLE videos;
+ALTER TABLE videos_legacy RENAME TO videos;
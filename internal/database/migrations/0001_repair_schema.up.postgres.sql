@@ -0,0 +1,55 @@
+-- PostgreSQL databases never went through the old autoMigrate, so they
+-- start directly from the repaired schema.
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	password TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE refresh_tokens (
+	token TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	revoked_at TIMESTAMP,
+	user_id TEXT NOT NULL REFERENCES users(id),
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE videos (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	thumbnail_url TEXT,
+	video_url TEXT,
+	preview_url TEXT,
+	preview_video_url TEXT,
+	integrated_loudness DOUBLE PRECISION,
+	user_id TEXT NOT NULL REFERENCES users(id)
+);
+
+CREATE TABLE subtitle_tracks (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	video_id TEXT NOT NULL REFERENCES videos(id),
+	language TEXT NOT NULL,
+	label TEXT NOT NULL,
+	url TEXT NOT NULL
+);
+
+CREATE TABLE watermark_settings (
+	user_id TEXT PRIMARY KEY REFERENCES users(id),
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	image_path TEXT,
+	position TEXT,
+	opacity DOUBLE PRECISION,
+	scale DOUBLE PRECISION
+);
+
+CREATE INDEX idx_videos_user_id ON videos(user_id, created_at);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_subtitle_tracks_video_id ON subtitle_tracks(video_id);
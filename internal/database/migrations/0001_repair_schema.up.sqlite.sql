@@ -0,0 +1,98 @@
+-- Tables as the old autoMigrate created them. On databases that predate
+-- versioned migrations these are no-ops.
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	password TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	token TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	revoked_at TIMESTAMP,
+	user_id TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS videos (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT,
+	thumbnail_url TEXT,
+	video_url TEXT TEXT,
+	preview_url TEXT,
+	preview_video_url TEXT,
+	integrated_loudness REAL,
+	user_id INTEGER,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS subtitle_tracks (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	video_id TEXT NOT NULL,
+	language TEXT NOT NULL,
+	label TEXT NOT NULL,
+	url TEXT NOT NULL,
+	FOREIGN KEY(video_id) REFERENCES videos(id)
+);
+
+CREATE TABLE IF NOT EXISTS watermark_settings (
+	user_id TEXT PRIMARY KEY,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	image_path TEXT,
+	position TEXT,
+	opacity REAL,
+	scale REAL,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+-- videos.user_id was declared INTEGER although it holds UUID text, and
+-- video_url was typed "TEXT TEXT". SQLite can't alter column types, so the
+-- table is rebuilt.
+CREATE TABLE videos_repaired (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	thumbnail_url TEXT,
+	video_url TEXT,
+	preview_url TEXT,
+	preview_video_url TEXT,
+	integrated_loudness REAL,
+	user_id TEXT NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+INSERT INTO videos_repaired (
+	id, created_at, updated_at, title, description, thumbnail_url, video_url,
+	preview_url, preview_video_url, integrated_loudness, user_id
+)
+SELECT
+	id,
+	COALESCE(created_at, CURRENT_TIMESTAMP),
+	COALESCE(updated_at, CURRENT_TIMESTAMP),
+	title,
+	COALESCE(description, ''),
+	thumbnail_url,
+	video_url,
+	preview_url,
+	preview_video_url,
+	integrated_loudness,
+	CAST(user_id AS TEXT)
+FROM videos;
+
+DROP TABLE videos;
+ALTER TABLE videos_repaired RENAME TO videos;
+
+CREATE INDEX IF NOT EXISTS idx_videos_user_id ON videos(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_subtitle_tracks_video_id ON subtitle_tracks(video_id);
@@ -0,0 +1,4 @@
+DROP INDEX idx_videos_user_title;
+DROP INDEX idx_videos_user_updated;
+ALTER TABLE videos DROP COLUMN aspect_ratio;
+ALTER TABLE videos DROP COLUMN duration_seconds;
@@ -0,0 +1,16 @@
+-- Columns needed to sort and filter video listings without touching the
+-- object store.
+ALTER TABLE videos ADD COLUMN duration_seconds DOUBLE PRECISION;
+ALTER TABLE videos ADD COLUMN aspect_ratio TEXT;
+
+-- Uploaded videos are stored under a key prefixed with their aspect ratio.
+UPDATE videos
+SET aspect_ratio = CASE
+	WHEN video_url LIKE '%/landscape/%' THEN 'landscape'
+	WHEN video_url LIKE '%/portrait/%' THEN 'portrait'
+	ELSE 'other'
+END
+WHERE video_url IS NOT NULL;
+
+CREATE INDEX idx_videos_user_updated ON videos(user_id, updated_at);
+CREATE INDEX idx_videos_user_title ON videos(user_id, title);
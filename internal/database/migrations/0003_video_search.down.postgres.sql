@@ -0,0 +1,4 @@
+DROP INDEX idx_videos_search;
+DROP TRIGGER videos_search_vector_update ON videos;
+DROP FUNCTION videos_search_vector_update();
+ALTER TABLE videos DROP COLUMN search_vector;
@@ -0,0 +1,5 @@
+-- IF EXISTS: databases created without FTS5 never got the index.
+DROP TRIGGER IF EXISTS videos_search_delete;
+DROP TRIGGER IF EXISTS videos_search_update;
+DROP TRIGGER IF EXISTS videos_search_insert;
+DROP TABLE IF EXISTS videos_search;
@@ -0,0 +1,23 @@
+-- Full-text index over video titles and descriptions, with titles weighted
+-- above descriptions. The 'simple' configuration doesn't stem, matching the
+-- SQLite index.
+ALTER TABLE videos ADD COLUMN search_vector tsvector;
+
+CREATE FUNCTION videos_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector :=
+		setweight(to_tsvector('simple', coalesce(NEW.title, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(NEW.description, '')), 'B');
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER videos_search_vector_update
+BEFORE INSERT OR UPDATE OF title, description ON videos
+FOR EACH ROW EXECUTE FUNCTION videos_search_vector_update();
+
+UPDATE videos SET search_vector =
+	setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+	setweight(to_tsvector('simple', coalesce(description, '')), 'B');
+
+CREATE INDEX idx_videos_search ON videos USING GIN (search_vector);
@@ -0,0 +1,28 @@
+-- Full-text index over video titles and descriptions. The index keeps its
+-- own copy of the text keyed by video id rather than using the videos
+-- table as external content, because videos has no stable integer rowid.
+-- Requires SQLite built with FTS5 (go build -tags sqlite_fts5).
+CREATE VIRTUAL TABLE videos_search USING fts5(
+	video_id UNINDEXED,
+	title,
+	description,
+	prefix = '2 3'
+);
+
+INSERT INTO videos_search (video_id, title, description)
+SELECT id, title, description FROM videos;
+
+CREATE TRIGGER videos_search_insert AFTER INSERT ON videos BEGIN
+	INSERT INTO videos_search (video_id, title, description)
+	VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER videos_search_update AFTER UPDATE OF title, description ON videos BEGIN
+	UPDATE videos_search
+	SET title = new.title, description = new.description
+	WHERE video_id = old.id;
+END;
+
+CREATE TRIGGER videos_search_delete AFTER DELETE ON videos BEGIN
+	DELETE FROM videos_search WHERE video_id = old.id;
+END;
@@ -0,0 +1,3 @@
+-- This SQLite was built without FTS5, so there is no full-text index.
+-- SearchVideos scans titles and descriptions instead. Building with
+-- -tags sqlite_fts5 only helps new databases; this one keeps scanning.
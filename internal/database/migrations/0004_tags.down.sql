@@ -0,0 +1,3 @@
+DROP INDEX idx_video_tags_tag_id;
+DROP TABLE video_tags;
+DROP TABLE tags;
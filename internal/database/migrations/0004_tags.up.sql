@@ -0,0 +1,17 @@
+-- Tags belong to the user who created them, so autocomplete only suggests
+-- the user's own vocabulary. Names are stored normalized.
+CREATE TABLE tags (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id TEXT NOT NULL REFERENCES users(id),
+	name TEXT NOT NULL,
+	UNIQUE (user_id, name)
+);
+
+CREATE TABLE video_tags (
+	video_id TEXT NOT NULL REFERENCES videos(id),
+	tag_id TEXT NOT NULL REFERENCES tags(id),
+	PRIMARY KEY (video_id, tag_id)
+);
+
+CREATE INDEX idx_video_tags_tag_id ON video_tags(tag_id);
@@ -0,0 +1,5 @@
+DROP INDEX idx_playlist_items_video_id;
+DROP INDEX idx_playlist_items_position;
+DROP INDEX idx_playlists_user_id;
+DROP TABLE playlist_items;
+DROP TABLE playlists;
@@ -0,0 +1,23 @@
+-- Item positions only need to sort correctly; gaps left by deleted videos
+-- are closed the next time the playlist is reordered.
+CREATE TABLE playlists (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id TEXT NOT NULL REFERENCES users(id),
+	title TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE playlist_items (
+	id TEXT PRIMARY KEY,
+	added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	playlist_id TEXT NOT NULL REFERENCES playlists(id),
+	video_id TEXT NOT NULL REFERENCES videos(id),
+	position INTEGER NOT NULL,
+	UNIQUE (playlist_id, video_id)
+);
+
+CREATE INDEX idx_playlists_user_id ON playlists(user_id, created_at);
+CREATE INDEX idx_playlist_items_position ON playlist_items(playlist_id, position);
+CREATE INDEX idx_playlist_items_video_id ON playlist_items(video_id);
@@ -0,0 +1,2 @@
+DROP INDEX idx_videos_deleted_at;
+ALTER TABLE videos DROP COLUMN deleted_at;
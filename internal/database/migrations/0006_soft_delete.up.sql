@@ -0,0 +1,4 @@
+-- Deleted videos stay in the trash until they are restored or purged.
+ALTER TABLE videos ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_videos_deleted_at ON videos(deleted_at);
@@ -0,0 +1,2 @@
+ALTER TABLE videos DROP COLUMN current_version_id;
+DROP TABLE video_versions;
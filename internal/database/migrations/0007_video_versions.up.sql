@@ -0,0 +1,52 @@
+-- Every upload of a video's media is kept as a version. The videos row
+-- mirrors the current version so listing doesn't need a join.
+CREATE TABLE video_versions (
+	id TEXT PRIMARY KEY,
+	uploaded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	video_id TEXT NOT NULL REFERENCES videos(id),
+	version INTEGER NOT NULL,
+	video_url TEXT NOT NULL,
+	preview_url TEXT,
+	preview_video_url TEXT,
+	size_bytes BIGINT,
+	checksum_sha256 TEXT,
+	duration_seconds DOUBLE PRECISION,
+	width INTEGER,
+	height INTEGER,
+	aspect_ratio TEXT,
+	integrated_loudness DOUBLE PRECISION,
+	UNIQUE (video_id, version)
+);
+
+ALTER TABLE videos ADD COLUMN current_version_id TEXT;
+
+-- Media uploaded before versioning becomes version 1. Its size and checksum
+-- were never recorded. The version reuses the video's ID, which is unique
+-- because each video gets at most one backfilled version.
+INSERT INTO video_versions (
+	id,
+	uploaded_at,
+	video_id,
+	version,
+	video_url,
+	preview_url,
+	preview_video_url,
+	duration_seconds,
+	aspect_ratio,
+	integrated_loudness
+)
+SELECT
+	id,
+	updated_at,
+	id,
+	1,
+	video_url,
+	preview_url,
+	preview_video_url,
+	duration_seconds,
+	aspect_ratio,
+	integrated_loudness
+FROM videos
+WHERE video_url IS NOT NULL;
+
+UPDATE videos SET current_version_id = id WHERE video_url IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX idx_audit_events_target;
+DROP INDEX idx_audit_events_actor_id;
+DROP INDEX idx_audit_events_created_at;
+DROP TABLE audit_events;
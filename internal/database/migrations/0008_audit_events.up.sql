@@ -0,0 +1,18 @@
+-- Audit events are only ever inserted. Actors and targets aren't foreign
+-- keys so the history outlives the users and videos it mentions.
+CREATE TABLE audit_events (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	actor_id TEXT,
+	action TEXT NOT NULL,
+	target_type TEXT NOT NULL DEFAULT '',
+	target_id TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	outcome TEXT NOT NULL,
+	detail TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at, id);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id, created_at);
+CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id, created_at);
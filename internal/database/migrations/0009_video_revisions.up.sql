@@ -0,0 +1,3 @@
+-- revision counts changes to a video and what's shown with it, so clients
+-- can tell whether the copy they hold is stale.
+ALTER TABLE videos ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
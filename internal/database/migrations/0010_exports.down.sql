@@ -0,0 +1,3 @@
+DROP INDEX idx_exports_expires_at;
+DROP INDEX idx_exports_user_id;
+DROP TABLE exports;
@@ -0,0 +1,16 @@
+-- A user's data export is built in the background and kept in the bucket
+-- until it expires.
+CREATE TABLE exports (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id TEXT NOT NULL REFERENCES users(id),
+	status TEXT NOT NULL,
+	object_key TEXT,
+	size_bytes BIGINT,
+	error TEXT,
+	completed_at TIMESTAMP,
+	expires_at TIMESTAMP
+);
+
+CREATE INDEX idx_exports_user_id ON exports(user_id, created_at);
+CREATE INDEX idx_exports_expires_at ON exports(expires_at);
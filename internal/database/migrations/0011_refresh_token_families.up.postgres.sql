@@ -0,0 +1,12 @@
+-- Each login starts a family of refresh tokens. Refreshing rotates the
+-- token: the old one is marked rotated and a new one joins the family.
+-- Presenting a rotated token again means it leaked, so the whole family is
+-- revoked.
+ALTER TABLE refresh_tokens ADD COLUMN family_id TEXT;
+ALTER TABLE refresh_tokens ADD COLUMN rotated_at TIMESTAMP;
+
+-- Tokens issued before rotation each start their own family.
+UPDATE refresh_tokens SET family_id = gen_random_uuid()::text;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
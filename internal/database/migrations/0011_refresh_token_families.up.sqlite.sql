@@ -0,0 +1,19 @@
+-- Each login starts a family of refresh tokens. Refreshing rotates the
+-- token: the old one is marked rotated and a new one joins the family.
+-- Presenting a rotated token again means it leaked, so the whole family is
+-- revoked.
+ALTER TABLE refresh_tokens ADD COLUMN family_id TEXT;
+ALTER TABLE refresh_tokens ADD COLUMN rotated_at TIMESTAMP;
+
+-- Tokens issued before rotation each start their own family. SQLite has no
+-- UUID function, so a version 4 UUID is assembled from random bytes.
+UPDATE refresh_tokens SET family_id = lower(
+	hex(randomblob(4)) || '-' ||
+	hex(randomblob(2)) || '-4' ||
+	substr(hex(randomblob(2)), 2) || '-' ||
+	substr('89ab', 1 + abs(random()) % 4, 1) ||
+	substr(hex(randomblob(2)), 2) || '-' ||
+	hex(randomblob(6))
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
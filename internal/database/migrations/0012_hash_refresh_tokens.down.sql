@@ -0,0 +1,4 @@
+-- Hashes can't be turned back into tokens, so every session ends.
+DELETE FROM refresh_tokens;
+DROP INDEX idx_refresh_tokens_expires_at;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,6 @@
+-- Refresh tokens are stored as the hex SHA-256 of the token, so a copy of
+-- the database can't be used to refresh anyone's session.
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
@@ -0,0 +1,7 @@
+-- Refresh tokens are stored as the hex SHA-256 of the token, so a copy of
+-- the database can't be used to refresh anyone's session. sha256_hex is
+-- registered by the application's SQLite driver.
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens SET token_hash = sha256_hex(token_hash);
+
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
@@ -0,0 +1,9 @@
+-- A session is a refresh token family. Its newest token records where the
+-- session was last used from, and carries over when the session started.
+ALTER TABLE refresh_tokens ADD COLUMN signed_in_at TIMESTAMP;
+ALTER TABLE refresh_tokens ADD COLUMN last_used_at TIMESTAMP;
+ALTER TABLE refresh_tokens ADD COLUMN device_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+
+UPDATE refresh_tokens SET signed_in_at = created_at, last_used_at = updated_at;
@@ -0,0 +1,4 @@
+-- role is user, moderator or admin. A disabled user can't log in, and their
+-- sessions and API keys are revoked when they're disabled.
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
@@ -0,0 +1,2 @@
+DROP INDEX idx_videos_visibility;
+ALTER TABLE videos DROP COLUMN visibility;
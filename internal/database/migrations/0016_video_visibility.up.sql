@@ -0,0 +1,7 @@
+-- visibility is private (owner only), unlisted (anyone with the link) or
+-- public (also listed in the catalog). New videos are private; videos that
+-- already existed were reachable by link, so they start out unlisted.
+ALTER TABLE videos ADD COLUMN visibility TEXT NOT NULL DEFAULT 'private';
+UPDATE videos SET visibility = 'unlisted';
+
+CREATE INDEX idx_videos_visibility ON videos(visibility, created_at);
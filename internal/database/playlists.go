@@ -0,0 +1,396 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrVideoInPlaylist = errors.New("video is already in the playlist")
+	ErrInvalidOrder    = errors.New("order must list every item of the playlist exactly once")
+)
+
+type Playlist struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// CoverURL is the thumbnail of the first item, nil while the playlist
+	// is empty or its first video has no thumbnail. Items whose video is in
+	// the trash are hidden until it is restored.
+	CoverURL  *string `json:"cover_url"`
+	ItemCount int     `json:"item_count"`
+	CreatePlaylistParams
+	// Items is only loaded by GetPlaylist.
+	Items []PlaylistItem `json:"items,omitempty"`
+}
+
+type CreatePlaylistParams struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	UserID      uuid.UUID `json:"user_id"`
+}
+
+type PlaylistItem struct {
+	ID         uuid.UUID `json:"id"`
+	AddedAt    time.Time `json:"added_at"`
+	PlaylistID uuid.UUID `json:"playlist_id"`
+	Position   int       `json:"position"`
+	Video      Video     `json:"video"`
+}
+
+const playlistColumns = `
+		id,
+		created_at,
+		updated_at,
+		title,
+		description,
+		user_id,
+		(
+			SELECT videos.thumbnail_url
+			FROM playlist_items
+			JOIN videos ON videos.id = playlist_items.video_id
+			WHERE playlist_items.playlist_id = playlists.id AND videos.deleted_at IS NULL
+			ORDER BY playlist_items.position
+			LIMIT 1
+		),
+		(
+			SELECT COUNT(*)
+			FROM playlist_items
+			JOIN videos ON videos.id = playlist_items.video_id
+			WHERE playlist_items.playlist_id = playlists.id AND videos.deleted_at IS NULL
+		)`
+
+func scanPlaylist(row rowScanner) (Playlist, error) {
+	var playlist Playlist
+	err := row.Scan(
+		&playlist.ID,
+		&playlist.CreatedAt,
+		&playlist.UpdatedAt,
+		&playlist.Title,
+		&playlist.Description,
+		&playlist.UserID,
+		&playlist.CoverURL,
+		&playlist.ItemCount,
+	)
+	return playlist, err
+}
+
+func (c Client) CreatePlaylist(params CreatePlaylistParams) (Playlist, error) {
+	id := uuid.New()
+	query := `
+	INSERT INTO playlists (
+		id,
+		created_at,
+		updated_at,
+		title,
+		description,
+		user_id
+	) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, ?)
+	`
+	_, err := c.exec(query, id, params.Title, params.Description, params.UserID)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	return c.GetPlaylist(id)
+}
+
+// GetPlaylist returns a playlist with its items in order, or a zero
+// Playlist if it doesn't exist.
+func (c Client) GetPlaylist(id uuid.UUID) (Playlist, error) {
+	query := `
+	SELECT` + playlistColumns + `
+	FROM playlists
+	WHERE id = ?
+	`
+
+	playlist, err := scanPlaylist(c.queryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Playlist{}, nil
+		}
+		return Playlist{}, err
+	}
+
+	playlist.Items, err = c.getPlaylistItems(id)
+	if err != nil {
+		return Playlist{}, err
+	}
+	return playlist, nil
+}
+
+// GetPlaylists returns the user's playlists, newest first, without their
+// items.
+func (c Client) GetPlaylists(userID uuid.UUID) ([]Playlist, error) {
+	query := `
+	SELECT` + playlistColumns + `
+	FROM playlists
+	WHERE user_id = ?
+	ORDER BY created_at DESC
+	`
+
+	rows, err := c.query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []Playlist{}
+	for rows.Next() {
+		playlist, err := scanPlaylist(rows)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, playlist)
+	}
+	return playlists, rows.Err()
+}
+
+func (c Client) UpdatePlaylist(playlist Playlist) error {
+	query := `
+	UPDATE playlists
+	SET
+		updated_at = CURRENT_TIMESTAMP,
+		title = ?,
+		description = ?
+	WHERE id = ?
+	`
+	_, err := c.exec(query, playlist.Title, playlist.Description, playlist.ID)
+	return err
+}
+
+func (c Client) DeletePlaylist(id uuid.UUID) error {
+	return c.inTransaction(func(t transaction) error {
+		_, err := t.exec("DELETE FROM playlist_items WHERE playlist_id = ?", id)
+		if err != nil {
+			return err
+		}
+		_, err = t.exec("DELETE FROM playlists WHERE id = ?", id)
+		return err
+	})
+}
+
+func (c Client) getPlaylistItems(playlistID uuid.UUID) ([]PlaylistItem, error) {
+	query := `
+	SELECT
+		playlist_items.id,
+		playlist_items.added_at,
+		playlist_items.playlist_id,
+		playlist_items.position,
+		playlist_items.video_id
+	FROM playlist_items
+	JOIN videos ON videos.id = playlist_items.video_id
+	WHERE playlist_items.playlist_id = ? AND videos.deleted_at IS NULL
+	ORDER BY playlist_items.position
+	`
+	rows, err := c.query(query, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []PlaylistItem{}
+	for rows.Next() {
+		var item PlaylistItem
+		err := rows.Scan(&item.ID, &item.AddedAt, &item.PlaylistID, &item.Position, &item.Video.ID)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		items[i].Video, err = c.GetVideo(items[i].Video.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// GetPlaylistItem returns a single item without its video loaded, or a
+// zero PlaylistItem if it doesn't exist.
+func (c Client) GetPlaylistItem(id uuid.UUID) (PlaylistItem, error) {
+	query := `
+	SELECT
+		id,
+		added_at,
+		playlist_id,
+		position,
+		video_id
+	FROM playlist_items
+	WHERE id = ?
+	`
+	var item PlaylistItem
+	err := c.queryRow(query, id).Scan(&item.ID, &item.AddedAt, &item.PlaylistID, &item.Position, &item.Video.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PlaylistItem{}, nil
+		}
+		return PlaylistItem{}, err
+	}
+	return item, nil
+}
+
+// AddPlaylistItem appends a video to the end of a playlist.
+func (c Client) AddPlaylistItem(playlistID, videoID uuid.UUID) (PlaylistItem, error) {
+	id := uuid.New()
+	err := c.inTransaction(func(t transaction) error {
+		var exists int
+		err := t.queryRow("SELECT COUNT(*) FROM playlist_items WHERE playlist_id = ? AND video_id = ?", playlistID, videoID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrVideoInPlaylist
+		}
+
+		query := `
+		INSERT INTO playlist_items (id, added_at, playlist_id, video_id, position)
+		SELECT ?, CURRENT_TIMESTAMP, ?, ?, COALESCE(MAX(position), -1) + 1
+		FROM playlist_items
+		WHERE playlist_id = ?
+		`
+		_, err = t.exec(query, id, playlistID, videoID, playlistID)
+		if err != nil {
+			return err
+		}
+		return touchPlaylist(t, playlistID)
+	})
+	if err != nil {
+		return PlaylistItem{}, err
+	}
+
+	return c.GetPlaylistItem(id)
+}
+
+func (c Client) RemovePlaylistItem(id uuid.UUID) error {
+	return c.inTransaction(func(t transaction) error {
+		var playlistID uuid.UUID
+		err := t.queryRow("SELECT playlist_id FROM playlist_items WHERE id = ?", id).Scan(&playlistID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		_, err = t.exec("DELETE FROM playlist_items WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		visible, hidden, err := playlistOrder(t, playlistID)
+		if err != nil {
+			return err
+		}
+		return writePlaylistOrder(t, playlistID, append(visible, hidden...))
+	})
+}
+
+// MovePlaylistItem moves an item to position, counted from zero among the
+// items that aren't in the trash, shifting the items in between. Positions
+// past the end move the item to the end.
+func (c Client) MovePlaylistItem(id uuid.UUID, position int) error {
+	return c.inTransaction(func(t transaction) error {
+		var playlistID uuid.UUID
+		err := t.queryRow("SELECT playlist_id FROM playlist_items WHERE id = ?", id).Scan(&playlistID)
+		if err != nil {
+			return err
+		}
+		visible, hidden, err := playlistOrder(t, playlistID)
+		if err != nil {
+			return err
+		}
+
+		reordered := make([]uuid.UUID, 0, len(visible))
+		for _, itemID := range visible {
+			if itemID != id {
+				reordered = append(reordered, itemID)
+			}
+		}
+		position = max(0, min(position, len(reordered)))
+		reordered = slices.Insert(reordered, position, id)
+		return writePlaylistOrder(t, playlistID, append(reordered, hidden...))
+	})
+}
+
+// ReorderPlaylist puts a playlist's items in the given order, which must
+// contain every item exactly once. Items whose video is in the trash can't
+// be seen by the caller, so they are left out and keep their relative order
+// after the others.
+func (c Client) ReorderPlaylist(playlistID uuid.UUID, itemIDs []uuid.UUID) error {
+	return c.inTransaction(func(t transaction) error {
+		visible, hidden, err := playlistOrder(t, playlistID)
+		if err != nil {
+			return err
+		}
+		if len(visible) != len(itemIDs) {
+			return ErrInvalidOrder
+		}
+		remaining := map[uuid.UUID]bool{}
+		for _, id := range visible {
+			remaining[id] = true
+		}
+		for _, id := range itemIDs {
+			if !remaining[id] {
+				return ErrInvalidOrder
+			}
+			delete(remaining, id)
+		}
+		return writePlaylistOrder(t, playlistID, append(slices.Clone(itemIDs), hidden...))
+	})
+}
+
+// playlistOrder returns a playlist's item IDs in order, split into items
+// whose video is visible and items whose video is in the trash.
+func playlistOrder(t transaction, playlistID uuid.UUID) (visible, hidden []uuid.UUID, err error) {
+	query := `
+	SELECT playlist_items.id, videos.deleted_at IS NOT NULL
+	FROM playlist_items
+	JOIN videos ON videos.id = playlist_items.video_id
+	WHERE playlist_items.playlist_id = ?
+	ORDER BY playlist_items.position
+	`
+	rows, err := t.query(query, playlistID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	visible, hidden = []uuid.UUID{}, []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		var trashed bool
+		if err := rows.Scan(&id, &trashed); err != nil {
+			return nil, nil, err
+		}
+		if trashed {
+			hidden = append(hidden, id)
+		} else {
+			visible = append(visible, id)
+		}
+	}
+	return visible, hidden, rows.Err()
+}
+
+// writePlaylistOrder numbers the items 0, 1, 2... in the given order.
+func writePlaylistOrder(t transaction, playlistID uuid.UUID, ids []uuid.UUID) error {
+	for position, id := range ids {
+		_, err := t.exec("UPDATE playlist_items SET position = ? WHERE id = ? AND playlist_id = ?", position, id, playlistID)
+		if err != nil {
+			return err
+		}
+	}
+	return touchPlaylist(t, playlistID)
+}
+
+func touchPlaylist(t transaction, playlistID uuid.UUID) error {
+	_, err := t.exec("UPDATE playlists SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", playlistID)
+	return err
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newPlaylistFixture creates a playlist of videos titled a, b, c and d, in
+// that order, and returns the items' IDs by title.
+func newPlaylistFixture(t *testing.T) (Client, Playlist, map[string]uuid.UUID) {
+	t.Helper()
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	user, err := c.CreateUser(CreateUserParams{Email: "playlists@example.com", Password: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	playlist, err := c.CreatePlaylist(CreatePlaylistParams{Title: "Mix", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := map[string]uuid.UUID{}
+	for _, title := range []string{"a", "b", "c", "d"} {
+		video, err := c.CreateVideo(CreateVideoParams{Title: title, UserID: user.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		item, err := c.AddPlaylistItem(playlist.ID, video.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items[title] = item.ID
+	}
+	return c, playlist, items
+}
+
+// playlistTitles lists the titles of a playlist's visible items in order,
+// checking that their positions are numbered from zero.
+func playlistTitles(t *testing.T, c Client, playlistID uuid.UUID) []string {
+	t.Helper()
+	playlist, err := c.GetPlaylist(playlistID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{}
+	for _, item := range playlist.Items {
+		titles = append(titles, item.Video.Title)
+	}
+	if playlist.ItemCount != len(titles) {
+		t.Errorf("ItemCount is %d, but %d items are listed", playlist.ItemCount, len(titles))
+	}
+	return titles
+}
+
+func TestMovePlaylistItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     string
+		position int
+		trash    string
+		want     []string
+	}{
+		{name: "to the front", item: "c", position: 0, want: []string{"c", "a", "b", "d"}},
+		{name: "forward", item: "a", position: 2, want: []string{"b", "c", "a", "d"}},
+		{name: "to the end", item: "b", position: 3, want: []string{"a", "c", "d", "b"}},
+		{name: "past the end", item: "a", position: 99, want: []string{"b", "c", "d", "a"}},
+		{name: "in place", item: "b", position: 1, want: []string{"a", "b", "c", "d"}},
+		{name: "counting only visible items", item: "d", position: 1, trash: "a", want: []string{"b", "d", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, playlist, items := newPlaylistFixture(t)
+			if tt.trash != "" {
+				trashPlaylistItem(t, c, items[tt.trash])
+			}
+
+			err := c.MovePlaylistItem(items[tt.item], tt.position)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := playlistTitles(t, c, playlist.ID); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderPlaylist(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []string
+		trash   string
+		want    []string
+		wantErr error
+	}{
+		{name: "reversed", order: []string{"d", "c", "b", "a"}, want: []string{"d", "c", "b", "a"}},
+		{name: "without trashed items", order: []string{"c", "a", "d"}, trash: "b", want: []string{"c", "a", "d"}},
+		{name: "missing item", order: []string{"a", "b", "c"}, wantErr: ErrInvalidOrder},
+		{name: "duplicate item", order: []string{"a", "b", "c", "c"}, wantErr: ErrInvalidOrder},
+		{name: "unknown item", order: []string{"a", "b", "c", "unknown"}, wantErr: ErrInvalidOrder},
+		{name: "trashed item", order: []string{"a", "b", "c", "d"}, trash: "d", wantErr: ErrInvalidOrder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, playlist, items := newPlaylistFixture(t)
+			if tt.trash != "" {
+				trashPlaylistItem(t, c, items[tt.trash])
+			}
+
+			order := []uuid.UUID{}
+			for _, title := range tt.order {
+				id, ok := items[title]
+				if !ok {
+					id = uuid.New()
+				}
+				order = append(order, id)
+			}
+			err := c.ReorderPlaylist(playlist.ID, order)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := playlistTitles(t, c, playlist.ID); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPlaylistTrashedItem checks that an item whose video is in the trash
+// keeps its place while hidden and shows up there again on restore.
+func TestPlaylistTrashedItem(t *testing.T) {
+	c, playlist, items := newPlaylistFixture(t)
+	item := trashPlaylistItem(t, c, items["b"])
+
+	err := c.RemovePlaylistItem(items["a"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.MovePlaylistItem(items["d"], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := playlistTitles(t, c, playlist.ID), []string{"d", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	err = c.RestoreVideo(item.Video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := playlistTitles(t, c, playlist.ID), []string{"d", "c", "b"}; !slices.Equal(got, want) {
+		t.Errorf("after restore: got %q, want %q", got, want)
+	}
+
+	_, err = c.AddPlaylistItem(playlist.ID, item.Video.ID)
+	if !errors.Is(err, ErrVideoInPlaylist) {
+		t.Errorf("adding a video twice: got %v, want ErrVideoInPlaylist", err)
+	}
+}
+
+func trashPlaylistItem(t *testing.T, c Client, itemID uuid.UUID) PlaylistItem {
+	t.Helper()
+	item, err := c.GetPlaylistItem(itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.TrashVideo(item.Video.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
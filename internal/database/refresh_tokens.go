@@ -2,35 +2,66 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrRefreshTokenRotated is returned by RotateRefreshToken when the token
+// was already rotated or revoked, for example by a concurrent refresh.
+var ErrRefreshTokenRotated = errors.New("refresh token was already rotated or revoked")
+
+// RefreshToken is a stored refresh token. Only the token's SHA-256 is
+// stored, so the token itself is known only to the client holding it.
+// Methods take the token and hash it themselves.
 type RefreshToken struct {
-	CreateRefreshTokenParams
+	TokenHash string     `json:"-"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	FamilyID  uuid.UUID  `json:"family_id"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	RevokedAt *time.Time `json:"revoked_at"`
+	// RotatedAt is when the token was exchanged for a new one. A rotated
+	// token can't be used again.
+	RotatedAt *time.Time `json:"rotated_at"`
+	// SignedInAt is when the family's first token was issued at login.
+	SignedInAt time.Time `json:"signed_in_at"`
+	// LastUsedAt is when the token was issued or last refreshed from.
+	LastUsedAt time.Time `json:"last_used_at"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
 }
 
 type CreateRefreshTokenParams struct {
-	Token     string    `json:"token"`
-	UserID    uuid.UUID `json:"user_id"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Token     string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	// FamilyID groups a login's token with the tokens it was rotated into.
+	// A nil FamilyID starts a new family.
+	FamilyID uuid.UUID
+	// DeviceName, UserAgent and IP describe the client the token is issued
+	// to. Rotation keeps the family's device name.
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
+
+func hashRefreshToken(token string) string {
+	return sha256Hex(token)
 }
 
 func (c Client) CreateRefreshToken(params CreateRefreshTokenParams) (RefreshToken, error) {
-	query := `
-		INSERT INTO refresh_tokens (
-			token,
-			created_at,
-			updated_at,
-			user_id,
-			expires_at
-		) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?)
-	`
-	_, err := c.db.Exec(query, params.Token, params.UserID.String(), params.ExpiresAt)
+	if params.FamilyID == uuid.Nil {
+		params.FamilyID = uuid.New()
+	}
+	now := formatTimestamp(time.Now())
+	_, err := c.exec(insertRefreshToken,
+		hashRefreshToken(params.Token), params.UserID.String(), formatTimestamp(params.ExpiresAt), params.FamilyID,
+		now, now, params.DeviceName, params.UserAgent, params.IP,
+	)
 	if err != nil {
 		return RefreshToken{}, err
 	}
@@ -38,26 +69,100 @@ func (c Client) CreateRefreshToken(params CreateRefreshTokenParams) (RefreshToke
 	return c.GetRefreshToken(params.Token)
 }
 
+const insertRefreshToken = `
+	INSERT INTO refresh_tokens (
+		token_hash,
+		created_at,
+		updated_at,
+		user_id,
+		expires_at,
+		family_id,
+		signed_in_at,
+		last_used_at,
+		device_name,
+		user_agent,
+		ip
+	) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// RotateRefreshToken marks token as rotated and stores next, in the same
+// family, in its place. next keeps token's sign-in time and device name.
+// It returns ErrRefreshTokenRotated, storing nothing, if token was rotated
+// or revoked in the meantime.
+func (c Client) RotateRefreshToken(token string, next CreateRefreshTokenParams) (RefreshToken, error) {
+	err := c.inTransaction(func(t transaction) error {
+		query := `
+		UPDATE refresh_tokens
+		SET rotated_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE token_hash = ? AND family_id = ? AND rotated_at IS NULL AND revoked_at IS NULL
+		`
+		result, err := t.exec(query, hashRefreshToken(token), next.FamilyID)
+		if err != nil {
+			return err
+		}
+		rotated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rotated == 0 {
+			return ErrRefreshTokenRotated
+		}
+
+		var signedInAt time.Time
+		var deviceName string
+		err = t.queryRow(`SELECT signed_in_at, device_name FROM refresh_tokens WHERE token_hash = ?`, hashRefreshToken(token)).
+			Scan(&signedInAt, &deviceName)
+		if err != nil {
+			return err
+		}
+
+		_, err = t.exec(insertRefreshToken,
+			hashRefreshToken(next.Token), next.UserID.String(), formatTimestamp(next.ExpiresAt), next.FamilyID,
+			formatTimestamp(signedInAt), formatTimestamp(time.Now()), deviceName, next.UserAgent, next.IP,
+		)
+		return err
+	})
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	return c.GetRefreshToken(next.Token)
+}
+
 func (c Client) RevokeRefreshToken(token string) error {
 	query := `
 		UPDATE refresh_tokens
 		SET revoked_at = CURRENT_TIMESTAMP
-		WHERE token = ?
+		WHERE token_hash = ?
 	`
-	_, err := c.db.Exec(query, token)
+	_, err := c.exec(query, hashRefreshToken(token))
+	return err
+}
+
+// RevokeRefreshTokenFamily revokes every token of a family that isn't
+// revoked yet.
+func (c Client) RevokeRefreshTokenFamily(familyID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = ? AND revoked_at IS NULL
+	`
+	_, err := c.exec(query, familyID)
 	return err
 }
 
 func (c Client) GetRefreshToken(token string) (RefreshToken, error) {
 	query := `
-		SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+		SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at,
+			signed_in_at, last_used_at, device_name, user_agent, ip
 		FROM refresh_tokens
-		WHERE token = ?
+		WHERE token_hash = ?
 	`
 	var rt RefreshToken
 	var userID string
-	err := c.db.QueryRow(query, token).
-		Scan(&rt.Token, &rt.CreatedAt, &rt.UpdatedAt, &userID, &rt.ExpiresAt, &rt.RevokedAt)
+	err := c.queryRow(query, hashRefreshToken(token)).
+		Scan(&rt.TokenHash, &rt.CreatedAt, &rt.UpdatedAt, &userID, &rt.ExpiresAt, &rt.RevokedAt, &rt.FamilyID, &rt.RotatedAt,
+			&rt.SignedInAt, &rt.LastUsedAt, &rt.DeviceName, &rt.UserAgent, &rt.IP)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return RefreshToken{}, nil
@@ -76,8 +181,24 @@ func (c Client) GetRefreshToken(token string) (RefreshToken, error) {
 func (c Client) DeleteRefreshToken(token string) error {
 	query := `
 		DELETE FROM refresh_tokens
-		WHERE token = ?
+		WHERE token_hash = ?
 	`
-	_, err := c.db.Exec(query, token)
+	_, err := c.exec(query, hashRefreshToken(token))
 	return err
 }
+
+// DeleteStaleRefreshTokens deletes tokens that expired before cutoff or
+// were revoked, and returns how many it deleted. Rotated tokens are kept
+// until they expire so their reuse is still recognized.
+func (c Client) DeleteStaleRefreshTokens(cutoff time.Time) (int, error) {
+	query := `
+		DELETE FROM refresh_tokens
+		WHERE expires_at < ? OR revoked_at IS NOT NULL
+	`
+	result, err := c.exec(query, formatTimestamp(cutoff))
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSHA256Hex(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{in: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		// PostgreSQL hashes the UTF-8 bytes, so SQLite has to as well.
+		{in: "é", want: "4a99557e4033c3539de2eb65472017cad5f9557f7a0625a09f1c3f6e2ba69c4c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got := sha256Hex(tt.in)
+			if got != tt.want {
+				t.Errorf("sha256Hex(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+
+			var fromSQL string
+			err := c.db.QueryRow("SELECT sha256_hex(?)", tt.in).Scan(&fromSQL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fromSQL != tt.want {
+				t.Errorf("sha256_hex(%q) = %s, want %s", tt.in, fromSQL, tt.want)
+			}
+		})
+	}
+}
+
+// TestHashRefreshTokensMigration checks that tokens stored in plain text
+// before migration 12 still work after it, and aren't stored in plain text
+// anymore.
+func TestHashRefreshTokensMigration(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	user, err := c.CreateUser(CreateUserParams{Email: "migrate@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := c.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.MigrateDown(len(statuses) - 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := "plain-token"
+	_, err = c.db.Exec(
+		`INSERT INTO refresh_tokens (token, user_id, expires_at, family_id) VALUES (?, ?, ?, ?)`,
+		token, user.ID.String(), formatTimestamp(time.Now().Add(time.Hour)), uuid.NewString(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := c.GetRefreshToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.UserID != user.ID || rt.TokenHash != sha256Hex(token) {
+		t.Errorf("got %+v after the migration", rt)
+	}
+	var plain int
+	err = c.db.QueryRow(`SELECT COUNT(*) FROM refresh_tokens WHERE token_hash = ?`, token).Scan(&plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != 0 {
+		t.Error("token is still stored in plain text")
+	}
+}
+
+func TestDeleteStaleRefreshTokens(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	user, err := c.CreateUser(CreateUserParams{Email: "stale@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		// finish revokes or rotates the token after it's created.
+		finish    func(token string, familyID uuid.UUID) error
+		wantFound bool
+	}{
+		{name: "valid", expiresIn: time.Hour, wantFound: true},
+		{name: "expired", expiresIn: -time.Hour},
+		{name: "revoked", expiresIn: time.Hour, finish: func(token string, _ uuid.UUID) error { return c.RevokeRefreshToken(token) }},
+		{
+			// Kept so that reusing it still revokes its family.
+			name:      "rotated",
+			expiresIn: time.Hour,
+			finish: func(token string, familyID uuid.UUID) error {
+				_, err := c.RotateRefreshToken(token, CreateRefreshTokenParams{
+					Token:     token + "-next",
+					UserID:    user.ID,
+					ExpiresAt: time.Now().Add(time.Hour),
+					FamilyID:  familyID,
+				})
+				return err
+			},
+			wantFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		rt, err := c.CreateRefreshToken(CreateRefreshTokenParams{Token: tt.name, UserID: user.ID, ExpiresAt: time.Now().Add(tt.expiresIn)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.finish != nil {
+			err = tt.finish(tt.name, rt.FamilyID)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	deleted, err := c.DeleteStaleRefreshTokens(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d tokens, want 2", deleted)
+	}
+	for _, tt := range tests {
+		rt, err := c.GetRefreshToken(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := rt.TokenHash != ""; found != tt.wantFound {
+			t.Errorf("%s token kept: %v, want %v", tt.name, found, tt.wantFound)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one login on one device. It is a refresh token family seen
+// through its current token, so its ID is the family's ID and it ends when
+// the family is revoked or its current token expires.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	SignedInAt time.Time `json:"signed_in_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+const selectSessions = `
+	SELECT family_id, user_id, device_name, user_agent, ip, signed_in_at, last_used_at, expires_at
+	FROM refresh_tokens
+	WHERE rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+`
+
+// GetSessions returns a user's active sessions, most recently used first.
+func (c Client) GetSessions(userID uuid.UUID) ([]Session, error) {
+	query := selectSessions + `
+		AND user_id = ?
+		ORDER BY last_used_at DESC, signed_in_at DESC
+	`
+	rows, err := c.query(query, formatTimestamp(time.Now()), userID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// GetSession returns an active session, or a zero Session if there is none
+// with that ID.
+func (c Client) GetSession(id uuid.UUID) (Session, error) {
+	query := selectSessions + `
+		AND family_id = ?
+	`
+	session, err := scanSession(c.queryRow(query, formatTimestamp(time.Now()), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, nil
+	}
+	return session, err
+}
+
+// RevokeOtherSessions revokes every session of a user except keepID, as
+// RevokeRefreshTokenFamily would one by one, and returns how many active
+// sessions it ended.
+func (c Client) RevokeOtherSessions(userID, keepID uuid.UUID) (int, error) {
+	var revoked int
+	err := c.inTransaction(func(t transaction) error {
+		query := `
+		SELECT COUNT(*)
+		FROM refresh_tokens
+		WHERE user_id = ? AND family_id <> ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+		`
+		err := t.queryRow(query, userID.String(), keepID, formatTimestamp(time.Now())).Scan(&revoked)
+		if err != nil {
+			return err
+		}
+
+		query = `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND family_id <> ? AND revoked_at IS NULL
+		`
+		_, err = t.exec(query, userID.String(), keepID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
+
+func scanSession(row rowScanner) (Session, error) {
+	var s Session
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.DeviceName,
+		&s.UserAgent,
+		&s.IP,
+		&s.SignedInAt,
+		&s.LastUsedAt,
+		&s.ExpiresAt,
+	)
+	return s, err
+}
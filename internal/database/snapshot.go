@@ -0,0 +1,35 @@
+package database
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrSnapshotUnsupported = errors.New("snapshots are only supported for SQLite; back up PostgreSQL with pg_dump")
+
+// SQLitePath returns the file behind a SQLite database URL, without the
+// "file:" prefix or connection parameters.
+func SQLitePath(databaseURL string) (string, error) {
+	d, dsn, err := parseDatabaseURL(databaseURL)
+	if err != nil {
+		return "", err
+	}
+	if d != dialectSQLite {
+		return "", ErrSnapshotUnsupported
+	}
+	path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if path == "" || path == ":memory:" {
+		return "", errors.New("database URL doesn't name a file")
+	}
+	return path, nil
+}
+
+// Snapshot writes a consistent copy of the database to path, which must not
+// exist, while other connections keep reading and writing.
+func (c Client) Snapshot(path string) error {
+	if c.dialect != dialectSQLite {
+		return ErrSnapshotUnsupported
+	}
+	_, err := c.exec("VACUUM INTO ?", path)
+	return err
+}
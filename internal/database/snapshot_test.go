@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSQLitePath(t *testing.T) {
+	tests := []struct {
+		databaseURL string
+		want        string
+		wantErr     error
+	}{
+		{databaseURL: "tubely.db", want: "tubely.db"},
+		{databaseURL: "sqlite:///var/lib/tubely.db", want: "/var/lib/tubely.db"},
+		{databaseURL: "file:tubely.db?_busy_timeout=100", want: "tubely.db"},
+		{databaseURL: "postgres://localhost/tubely", wantErr: ErrSnapshotUnsupported},
+		{databaseURL: ":memory:"},
+		{databaseURL: "mysql://localhost/tubely"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.databaseURL, func(t *testing.T) {
+			got, err := SQLitePath(tt.databaseURL)
+			if tt.want == "" {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %q and error %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q and error %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewClient(filepath.Join(dir, "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	user, err := c.CreateUser(CreateUserParams{Email: "snapshot@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "snapshot.db")
+	err = c.Snapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Snapshot(path); err == nil {
+		t.Error("overwrote an existing snapshot")
+	}
+	// Changes after the snapshot aren't in it.
+	_, err = c.CreateUser(CreateUserParams{Email: "later@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	got, err := snapshot.GetUserByEmail("snapshot@example.com")
+	if err != nil || got.ID != user.ID {
+		t.Errorf("snapshot is missing the user: %v", err)
+	}
+	later, err := snapshot.GetUserByEmail("later@example.com")
+	if err != nil || later.ID != uuid.Nil {
+		t.Errorf("snapshot has a user created after it: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store is everything the application needs from its database. Client
+// implements it on top of SQLite and PostgreSQL.
+type Store interface {
+	Close() error
+	Reset() error
+
+	MigrateUp() ([]Migration, error)
+	MigrateDown(steps int) ([]Migration, error)
+	MigrationStatus() ([]MigrationStatus, error)
+	Snapshot(path string) error
+	BeginTx(ctx context.Context) (*UnitOfWork, error)
+
+	GetUsers() ([]User, error)
+	GetUserByEmail(email string) (User, error)
+	GetUserByRefreshToken(token string) (*User, error)
+	CreateUser(params CreateUserParams) (*User, error)
+	GetUser(id uuid.UUID) (*User, error)
+	DeleteUser(id uuid.UUID) error
+	SetUserRole(id uuid.UUID, role string) error
+	DisableUser(id uuid.UUID) error
+	EnableUser(id uuid.UUID) error
+
+	CreateRefreshToken(params CreateRefreshTokenParams) (RefreshToken, error)
+	RotateRefreshToken(token string, next CreateRefreshTokenParams) (RefreshToken, error)
+	RevokeRefreshToken(token string) error
+	RevokeRefreshTokenFamily(familyID uuid.UUID) error
+	GetRefreshToken(token string) (RefreshToken, error)
+	DeleteRefreshToken(token string) error
+	DeleteStaleRefreshTokens(cutoff time.Time) (int, error)
+
+	GetSessions(userID uuid.UUID) ([]Session, error)
+	GetSession(id uuid.UUID) (Session, error)
+	RevokeOtherSessions(userID, keepID uuid.UUID) (int, error)
+
+	CreateAPIKey(params CreateAPIKeyParams) (APIKey, error)
+	GetAPIKey(id uuid.UUID) (APIKey, error)
+	GetAPIKeyByKey(key string) (APIKey, error)
+	GetAPIKeys(userID uuid.UUID) ([]APIKey, error)
+	TouchAPIKey(id uuid.UUID) error
+	RevokeAPIKey(id uuid.UUID) error
+
+	GetVideos(userID uuid.UUID) ([]Video, error)
+	ListVideos(params ListVideosParams) ([]Video, *VideoCursor, error)
+	SearchVideos(params SearchVideosParams) ([]VideoSearchResult, error)
+	GetVideosOutsideLoudness(userID uuid.UUID, min, max float64) ([]Video, error)
+	CreateVideo(params CreateVideoParams) (Video, error)
+	GetVideo(id uuid.UUID) (Video, error)
+	UpdateVideo(video Video) error
+	DeleteVideo(id uuid.UUID) error
+	GetDeletedVideo(id uuid.UUID) (Video, error)
+	GetDeletedVideos(userID uuid.UUID) ([]Video, error)
+	GetVideosDeletedBefore(cutoff time.Time) ([]Video, error)
+	TrashVideo(id uuid.UUID) error
+	RestoreVideo(id uuid.UUID) error
+	CountVideosWithThumbnail(thumbnailURL string) (int, error)
+
+	CreateVideoVersion(params CreateVideoVersionParams) (VideoVersion, error)
+	GetVideoVersion(id uuid.UUID) (VideoVersion, error)
+	GetVideoVersions(videoID uuid.UUID) ([]VideoVersion, error)
+	DeleteVideoVersion(id uuid.UUID) error
+
+	CreateSubtitleTrack(params CreateSubtitleTrackParams) (SubtitleTrack, error)
+	GetSubtitleTrack(id uuid.UUID) (SubtitleTrack, error)
+	GetSubtitleTracks(videoID uuid.UUID) ([]SubtitleTrack, error)
+	DeleteSubtitleTrack(id uuid.UUID) error
+
+	AddVideoTags(videoID, userID uuid.UUID, names []string) error
+	RemoveVideoTag(videoID uuid.UUID, name string) error
+	GetVideoTags(videoID uuid.UUID) ([]string, error)
+	SuggestTags(userID uuid.UUID, prefix string, limit int) ([]Tag, error)
+
+	CreatePlaylist(params CreatePlaylistParams) (Playlist, error)
+	GetPlaylist(id uuid.UUID) (Playlist, error)
+	GetPlaylists(userID uuid.UUID) ([]Playlist, error)
+	UpdatePlaylist(playlist Playlist) error
+	DeletePlaylist(id uuid.UUID) error
+	GetPlaylistItem(id uuid.UUID) (PlaylistItem, error)
+	AddPlaylistItem(playlistID, videoID uuid.UUID) (PlaylistItem, error)
+	RemovePlaylistItem(id uuid.UUID) error
+	MovePlaylistItem(id uuid.UUID, position int) error
+	ReorderPlaylist(playlistID uuid.UUID, itemIDs []uuid.UUID) error
+
+	GetWatermarkSettings(userID uuid.UUID) (WatermarkSettings, error)
+	UpsertWatermarkSettings(settings WatermarkSettings) (WatermarkSettings, error)
+
+	CreateExport(userID uuid.UUID) (Export, error)
+	GetExport(id uuid.UUID) (Export, error)
+	GetExports(userID uuid.UUID) ([]Export, error)
+	GetExportsExpiredBefore(cutoff time.Time) ([]Export, error)
+	UpdateExport(e Export) error
+	FailUnfinishedExports(reason string) (int, error)
+	DeleteExport(id uuid.UUID) error
+
+	CreateAuditEvent(params CreateAuditEventParams) (AuditEvent, error)
+	ListAuditEvents(params ListAuditEventsParams) ([]AuditEvent, *AuditEventCursor, error)
+}
+
+var _ Store = Client{}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SubtitleTrack struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	CreateSubtitleTrackParams
+}
+
+type CreateSubtitleTrackParams struct {
+	VideoID  uuid.UUID `json:"video_id"`
+	Language string    `json:"language"`
+	Label    string    `json:"label"`
+	URL      string    `json:"url"`
+}
+
+func (c Client) CreateSubtitleTrack(params CreateSubtitleTrackParams) (SubtitleTrack, error) {
+	id := uuid.New()
+	query := `
+	INSERT INTO subtitle_tracks (
+		id,
+		created_at,
+		video_id,
+		language,
+		label,
+		url
+	) VALUES (?, CURRENT_TIMESTAMP, ?, ?, ?, ?)
+	`
+	_, err := c.exec(query, id, params.VideoID, params.Language, params.Label, params.URL)
+	if err != nil {
+		return SubtitleTrack{}, err
+	}
+	err = c.touchVideo(params.VideoID)
+	if err != nil {
+		return SubtitleTrack{}, err
+	}
+
+	return c.GetSubtitleTrack(id)
+}
+
+func (c Client) GetSubtitleTrack(id uuid.UUID) (SubtitleTrack, error) {
+	query := `
+	SELECT
+		id,
+		created_at,
+		video_id,
+		language,
+		label,
+		url
+	FROM subtitle_tracks
+	WHERE id = ?
+	`
+
+	var track SubtitleTrack
+	err := c.queryRow(query, id).Scan(
+		&track.ID,
+		&track.CreatedAt,
+		&track.VideoID,
+		&track.Language,
+		&track.Label,
+		&track.URL,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SubtitleTrack{}, nil
+		}
+		return SubtitleTrack{}, err
+	}
+
+	return track, nil
+}
+
+func (c Client) GetSubtitleTracks(videoID uuid.UUID) ([]SubtitleTrack, error) {
+	query := `
+	SELECT
+		id,
+		created_at,
+		video_id,
+		language,
+		label,
+		url
+	FROM subtitle_tracks
+	WHERE video_id = ?
+	ORDER BY created_at ASC
+	`
+
+	rows, err := c.query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []SubtitleTrack{}
+	for rows.Next() {
+		var track SubtitleTrack
+		if err := rows.Scan(
+			&track.ID,
+			&track.CreatedAt,
+			&track.VideoID,
+			&track.Language,
+			&track.Label,
+			&track.URL,
+		); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+
+	return tracks, nil
+}
+
+func (c Client) DeleteSubtitleTrack(id uuid.UUID) error {
+	return c.inTransaction(func(t transaction) error {
+		_, err := t.exec("UPDATE videos SET updated_at = CURRENT_TIMESTAMP, revision = revision + 1 WHERE id = (SELECT video_id FROM subtitle_tracks WHERE id = ?)", id)
+		if err != nil {
+			return err
+		}
+
+		query := `
+		DELETE FROM subtitle_tracks
+		WHERE id = ?
+		`
+		_, err = t.exec(query, id)
+		return err
+	})
+}
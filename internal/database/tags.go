@@ -0,0 +1,124 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type Tag struct {
+	Name       string `json:"name"`
+	VideoCount int    `json:"video_count"`
+}
+
+// AddVideoTags attaches tags, which must already be normalized, to a video,
+// creating any the owner hasn't used before. Tags the video already has
+// are left alone.
+func (c Client) AddVideoTags(videoID, userID uuid.UUID, names []string) error {
+	return c.inTransaction(func(t transaction) error {
+		for _, name := range names {
+			_, err := t.exec(`
+			INSERT INTO tags (id, created_at, user_id, name)
+			VALUES (?, CURRENT_TIMESTAMP, ?, ?)
+			ON CONFLICT (user_id, name) DO NOTHING
+			`, uuid.New(), userID, name)
+			if err != nil {
+				return err
+			}
+
+			_, err = t.exec(`
+			INSERT INTO video_tags (video_id, tag_id)
+			SELECT ?, id FROM tags WHERE user_id = ? AND name = ?
+			ON CONFLICT (video_id, tag_id) DO NOTHING
+			`, videoID, userID, name)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := t.exec("UPDATE videos SET updated_at = CURRENT_TIMESTAMP, revision = revision + 1 WHERE id = ?", videoID)
+		return err
+	})
+}
+
+// RemoveVideoTag detaches a tag from a video. Removing a tag the video
+// doesn't have is not an error.
+func (c Client) RemoveVideoTag(videoID uuid.UUID, name string) error {
+	query := `
+	DELETE FROM video_tags
+	WHERE video_id = ?
+		AND tag_id IN (
+			SELECT tags.id
+			FROM tags
+			JOIN videos ON videos.user_id = tags.user_id
+			WHERE videos.id = ? AND tags.name = ?
+		)
+	`
+	result, err := c.exec(query, videoID, videoID, name)
+	if err != nil {
+		return err
+	}
+	if removed, err := result.RowsAffected(); err != nil || removed == 0 {
+		return err
+	}
+	return c.touchVideo(videoID)
+}
+
+// GetVideoTags returns the names of a video's tags in alphabetical order.
+func (c Client) GetVideoTags(videoID uuid.UUID) ([]string, error) {
+	query := `
+	SELECT tags.name
+	FROM video_tags
+	JOIN tags ON tags.id = video_tags.tag_id
+	WHERE video_tags.video_id = ?
+	ORDER BY tags.name
+	`
+	rows, err := c.query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SuggestTags returns the user's tags starting with prefix that are on at
+// least one video, most used first.
+func (c Client) SuggestTags(userID uuid.UUID, prefix string, limit int) ([]Tag, error) {
+	query := `
+	SELECT tags.name, COUNT(*) AS video_count
+	FROM tags
+	JOIN video_tags ON video_tags.tag_id = tags.id
+	JOIN videos ON videos.id = video_tags.video_id
+	WHERE tags.user_id = ? AND tags.name LIKE ? ESCAPE '\' AND videos.deleted_at IS NULL
+	GROUP BY tags.name
+	ORDER BY video_count DESC, tags.name
+	LIMIT ?
+	`
+	rows, err := c.query(query, userID, escapeLike(prefix)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Name, &tag.VideoCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+var ErrUnitOfWorkDone = errors.New("unit of work was already committed or rolled back")
+
+// TxStore is the part of Store that can run inside a UnitOfWork. Store
+// satisfies it too, so code written against TxStore works either way.
+type TxStore interface {
+	CreateVideo(params CreateVideoParams) (Video, error)
+	GetVideo(id uuid.UUID) (Video, error)
+	UpdateVideo(video Video) error
+
+	CreateVideoVersion(params CreateVideoVersionParams) (VideoVersion, error)
+	GetVideoVersion(id uuid.UUID) (VideoVersion, error)
+
+	CreateSubtitleTrack(params CreateSubtitleTrackParams) (SubtitleTrack, error)
+	DeleteSubtitleTrack(id uuid.UUID) error
+
+	GetWatermarkSettings(userID uuid.UUID) (WatermarkSettings, error)
+	UpsertWatermarkSettings(settings WatermarkSettings) (WatermarkSettings, error)
+}
+
+var (
+	_ TxStore = Client{}
+	_ TxStore = (*UnitOfWork)(nil)
+)
+
+// UnitOfWork groups writes that have to happen together: database changes,
+// which run in one transaction, and files or objects stored along the way,
+// which are undone by compensating actions. Unless Commit succeeds, the
+// transaction is rolled back and the compensations run, newest first.
+//
+// The transaction begins with the unit's first query, so slow uploads
+// before it don't hold a connection or a lock.
+type UnitOfWork struct {
+	ctx           context.Context
+	client        Client
+	tx            *sql.Tx
+	compensations []func() error
+	done          bool
+}
+
+// BeginTx starts a unit of work. Defer its Rollback, which does nothing
+// once the unit is committed.
+func (c Client) BeginTx(ctx context.Context) (*UnitOfWork, error) {
+	if c.tx != nil {
+		return nil, errors.New("units of work can't be nested")
+	}
+	return &UnitOfWork{ctx: ctx, client: c}, nil
+}
+
+// Compensate registers fn to undo a side effect outside the database if
+// the unit doesn't commit.
+func (u *UnitOfWork) Compensate(fn func() error) {
+	u.compensations = append(u.compensations, fn)
+}
+
+// Commit commits the transaction. If that fails, the unit is rolled back.
+func (u *UnitOfWork) Commit() error {
+	if u.done {
+		return ErrUnitOfWorkDone
+	}
+	if u.tx != nil {
+		err := u.tx.Commit()
+		if err != nil {
+			u.done = true
+			return errors.Join(err, u.compensate())
+		}
+	}
+	u.done = true
+	return nil
+}
+
+// Rollback rolls back the transaction and runs the compensations,
+// returning every error they hit. It does nothing after Commit.
+func (u *UnitOfWork) Rollback() error {
+	if u.done {
+		return nil
+	}
+	u.done = true
+	var err error
+	if u.tx != nil {
+		err = u.tx.Rollback()
+	}
+	return errors.Join(err, u.compensate())
+}
+
+func (u *UnitOfWork) compensate() error {
+	var errs []error
+	for _, fn := range slices.Backward(u.compensations) {
+		errs = append(errs, fn())
+	}
+	return errors.Join(errs...)
+}
+
+// store returns the Client bound to the unit's transaction, beginning it
+// on first use.
+func (u *UnitOfWork) store() (Client, error) {
+	if u.done {
+		return Client{}, ErrUnitOfWorkDone
+	}
+	if u.tx == nil {
+		tx, err := u.client.db.BeginTx(u.ctx, nil)
+		if err != nil {
+			return Client{}, err
+		}
+		u.tx = tx
+		u.client.tx = tx
+	}
+	return u.client, nil
+}
+
+func (u *UnitOfWork) CreateVideo(params CreateVideoParams) (Video, error) {
+	c, err := u.store()
+	if err != nil {
+		return Video{}, err
+	}
+	return c.CreateVideo(params)
+}
+
+func (u *UnitOfWork) GetVideo(id uuid.UUID) (Video, error) {
+	c, err := u.store()
+	if err != nil {
+		return Video{}, err
+	}
+	return c.GetVideo(id)
+}
+
+func (u *UnitOfWork) UpdateVideo(video Video) error {
+	c, err := u.store()
+	if err != nil {
+		return err
+	}
+	return c.UpdateVideo(video)
+}
+
+func (u *UnitOfWork) CreateVideoVersion(params CreateVideoVersionParams) (VideoVersion, error) {
+	c, err := u.store()
+	if err != nil {
+		return VideoVersion{}, err
+	}
+	return c.CreateVideoVersion(params)
+}
+
+func (u *UnitOfWork) GetVideoVersion(id uuid.UUID) (VideoVersion, error) {
+	c, err := u.store()
+	if err != nil {
+		return VideoVersion{}, err
+	}
+	return c.GetVideoVersion(id)
+}
+
+func (u *UnitOfWork) CreateSubtitleTrack(params CreateSubtitleTrackParams) (SubtitleTrack, error) {
+	c, err := u.store()
+	if err != nil {
+		return SubtitleTrack{}, err
+	}
+	return c.CreateSubtitleTrack(params)
+}
+
+func (u *UnitOfWork) DeleteSubtitleTrack(id uuid.UUID) error {
+	c, err := u.store()
+	if err != nil {
+		return err
+	}
+	return c.DeleteSubtitleTrack(id)
+}
+
+func (u *UnitOfWork) GetWatermarkSettings(userID uuid.UUID) (WatermarkSettings, error) {
+	c, err := u.store()
+	if err != nil {
+		return WatermarkSettings{}, err
+	}
+	return c.GetWatermarkSettings(userID)
+}
+
+func (u *UnitOfWork) UpsertWatermarkSettings(settings WatermarkSettings) (WatermarkSettings, error) {
+	c, err := u.store()
+	if err != nil {
+		return WatermarkSettings{}, err
+	}
+	return c.UpsertWatermarkSettings(settings)
+}
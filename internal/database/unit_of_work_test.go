@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUnitOfWorkOutcomes(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	user, err := c.CreateUser(CreateUserParams{Email: "uow@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// write makes the unit open its transaction; without it the unit
+		// only has compensations.
+		write           bool
+		commit          bool
+		wantVideo       bool
+		wantCompensated bool
+	}{
+		{name: "commit", write: true, commit: true, wantVideo: true},
+		{name: "rollback", write: true, wantCompensated: true},
+		{name: "commit without queries", commit: true},
+		{name: "rollback without queries", wantCompensated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uow, err := c.BeginTx(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			compensated := false
+			uow.Compensate(func() error {
+				compensated = true
+				return nil
+			})
+
+			videoID := uuid.Nil
+			if tt.write {
+				video, err := uow.CreateVideo(CreateVideoParams{Title: tt.name, UserID: user.ID})
+				if err != nil {
+					uow.Rollback()
+					t.Fatal(err)
+				}
+				videoID = video.ID
+			}
+			if tt.commit {
+				err = uow.Commit()
+			} else {
+				err = uow.Rollback()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compensated != tt.wantCompensated {
+				t.Errorf("compensated: %v, want %v", compensated, tt.wantCompensated)
+			}
+
+			if err := uow.Commit(); !errors.Is(err, ErrUnitOfWorkDone) {
+				t.Errorf("second Commit returned %v, want ErrUnitOfWorkDone", err)
+			}
+			if err := uow.Rollback(); err != nil {
+				t.Errorf("second Rollback returned %v", err)
+			}
+			if _, err := uow.CreateVideo(CreateVideoParams{Title: "late", UserID: user.ID}); !errors.Is(err, ErrUnitOfWorkDone) {
+				t.Errorf("writing to a finished unit returned %v, want ErrUnitOfWorkDone", err)
+			}
+
+			if tt.write {
+				video, err := c.GetVideo(videoID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if found := video.ID != uuid.Nil; found != tt.wantVideo {
+					t.Errorf("video stored: %v, want %v", found, tt.wantVideo)
+				}
+			}
+		})
+	}
+}
+
+func TestUnitOfWorkNesting(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	uow, err := c.BeginTx(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uow.Rollback()
+	inner, err := uow.store()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inner.BeginTx(context.Background()); err == nil {
+		t.Error("began a unit of work inside another")
+	}
+}
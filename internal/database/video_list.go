@@ -0,0 +1,232 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type VideoSort string
+
+const (
+	VideoSortCreated  VideoSort = "created"
+	VideoSortUpdated  VideoSort = "updated"
+	VideoSortTitle    VideoSort = "title"
+	VideoSortDuration VideoSort = "duration"
+)
+
+// Video statuses derived from which media has been uploaded.
+const (
+	VideoStatusDraft      = "draft"
+	VideoStatusIncomplete = "incomplete"
+	VideoStatusReady      = "ready"
+)
+
+// sortExpressions maps each sort to the SQL it orders by. Missing durations
+// sort as -1 so NULL ordering, which differs between backends, never matters.
+var sortExpressions = map[VideoSort]string{
+	VideoSortCreated:  "created_at",
+	VideoSortUpdated:  "updated_at",
+	VideoSortTitle:    "title",
+	VideoSortDuration: "COALESCE(duration_seconds, -1)",
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// VideoCursor marks the last video of a page. It is handed to clients as an
+// opaque string and only valid for the sort it was created with.
+type VideoCursor struct {
+	Sort       VideoSort `json:"s"`
+	Descending bool      `json:"d"`
+	Value      string    `json:"v"`
+	ID         uuid.UUID `json:"i"`
+}
+
+func (vc VideoCursor) Encode() string {
+	dat, _ := json.Marshal(vc)
+	return base64.RawURLEncoding.EncodeToString(dat)
+}
+
+func DecodeVideoCursor(s string) (VideoCursor, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return VideoCursor{}, ErrInvalidCursor
+	}
+	var vc VideoCursor
+	err = json.Unmarshal(dat, &vc)
+	if err != nil {
+		return VideoCursor{}, ErrInvalidCursor
+	}
+	if _, ok := sortExpressions[vc.Sort]; !ok || vc.ID == uuid.Nil {
+		return VideoCursor{}, ErrInvalidCursor
+	}
+	return vc, nil
+}
+
+type ListVideosParams struct {
+	// UserID is the owner whose videos are listed. uuid.Nil lists every
+	// user's.
+	UserID     uuid.UUID
+	Limit      int
+	Sort       VideoSort
+	Descending bool
+	After      *VideoCursor
+
+	Status        string
+	HasVideo      *bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	AspectRatio   string
+	Visibility    string
+	// Tags restricts the page to videos that have every one of these
+	// normalized tag names.
+	Tags []string
+}
+
+// ListVideos returns one page of videos. The cursor is nil on the last
+// page.
+func (c Client) ListVideos(params ListVideosParams) ([]Video, *VideoCursor, error) {
+	sortExpr, ok := sortExpressions[params.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown sort %q", params.Sort)
+	}
+	if params.Limit < 1 {
+		return nil, nil, errors.New("limit must be positive")
+	}
+
+	where := []string{"deleted_at IS NULL"}
+	args := []any{}
+	if params.UserID != uuid.Nil {
+		where = append(where, "user_id = ?")
+		args = append(args, params.UserID)
+	}
+
+	switch params.Status {
+	case "":
+	case VideoStatusDraft:
+		where = append(where, "video_url IS NULL AND thumbnail_url IS NULL")
+	case VideoStatusIncomplete:
+		where = append(where, "(video_url IS NULL) <> (thumbnail_url IS NULL)")
+	case VideoStatusReady:
+		where = append(where, "video_url IS NOT NULL AND thumbnail_url IS NOT NULL")
+	default:
+		return nil, nil, fmt.Errorf("unknown status %q", params.Status)
+	}
+	if params.HasVideo != nil {
+		if *params.HasVideo {
+			where = append(where, "video_url IS NOT NULL")
+		} else {
+			where = append(where, "video_url IS NULL")
+		}
+	}
+	if params.CreatedAfter != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, formatTimestamp(*params.CreatedAfter))
+	}
+	if params.CreatedBefore != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, formatTimestamp(*params.CreatedBefore))
+	}
+	if params.AspectRatio != "" {
+		where = append(where, "aspect_ratio = ?")
+		args = append(args, params.AspectRatio)
+	}
+	if params.Visibility != "" {
+		where = append(where, "visibility = ?")
+		args = append(args, params.Visibility)
+	}
+	// A video only carries its owner's tags, so matching the tag's owner
+	// against the video's covers listings across users too.
+	for _, tag := range params.Tags {
+		where = append(where, `id IN (
+			SELECT video_tags.video_id
+			FROM video_tags
+			JOIN tags ON tags.id = video_tags.tag_id
+			WHERE tags.name = ? AND tags.user_id = videos.user_id
+		)`)
+		args = append(args, tag)
+	}
+
+	direction, comparison := "ASC", ">"
+	if params.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if params.After != nil {
+		if params.After.Sort != params.Sort || params.After.Descending != params.Descending {
+			return nil, nil, ErrInvalidCursor
+		}
+		value, err := cursorArg(params.Sort, params.After.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sortExpr, comparison))
+		args = append(args, value, value, params.After.ID)
+	}
+
+	query := `
+	SELECT` + videoColumns + `
+	FROM videos
+	WHERE ` + strings.Join(where, " AND ") + `
+	ORDER BY ` + sortExpr + ` ` + direction + `, id ` + direction + `
+	LIMIT ?
+	`
+	args = append(args, params.Limit+1)
+
+	videos, err := c.queryVideos(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(videos) <= params.Limit {
+		return videos, nil, nil
+	}
+
+	videos = videos[:params.Limit]
+	last := videos[len(videos)-1]
+	next := &VideoCursor{
+		Sort:       params.Sort,
+		Descending: params.Descending,
+		Value:      cursorValue(params.Sort, last),
+		ID:         last.ID,
+	}
+	return videos, next, nil
+}
+
+func cursorValue(sort VideoSort, video Video) string {
+	switch sort {
+	case VideoSortUpdated:
+		return formatTimestamp(video.UpdatedAt)
+	case VideoSortTitle:
+		return video.Title
+	case VideoSortDuration:
+		if video.DurationSeconds == nil {
+			return "-1"
+		}
+		return strconv.FormatFloat(*video.DurationSeconds, 'g', -1, 64)
+	default:
+		return formatTimestamp(video.CreatedAt)
+	}
+}
+
+func cursorArg(sort VideoSort, value string) (any, error) {
+	if sort == VideoSortDuration {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// formatTimestamp renders a time the way CURRENT_TIMESTAMP stores it, so
+// comparisons against timestamp columns behave the same on every backend.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.999999")
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestVideoCursor(t *testing.T) {
+	cursor := VideoCursor{Sort: VideoSortDuration, Descending: true, Value: "12.5", ID: uuid.New()}
+	got, err := DecodeVideoCursor(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != cursor {
+		t.Errorf("got %+v, want %+v", got, cursor)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"not base64!",
+		"bm90IGpzb24",
+		VideoCursor{Sort: "views", Value: "1", ID: uuid.New()}.Encode(),
+		VideoCursor{Sort: VideoSortTitle, Value: "a"}.Encode(),
+	} {
+		_, err := DecodeVideoCursor(invalid)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeVideoCursor(%q): got %v, want ErrInvalidCursor", invalid, err)
+		}
+	}
+}
+
+// TestListVideosPages checks that paging through videos with the cursor
+// visits each of them once, in the same order as a single page, even when
+// many share the sort value.
+func TestListVideosPages(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	user, err := c.CreateUser(CreateUserParams{Email: "pages@example.com", Password: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	durations := []*float64{nil, ptr(30.0), ptr(12.5), nil, ptr(30.0), ptr(90.0), ptr(12.5)}
+	for i, title := range []string{"b", "a", "b", "c", "a", "d", "b"} {
+		video, err := c.CreateVideo(CreateVideoParams{Title: title, UserID: user.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		video.DurationSeconds = durations[i]
+		err = c.UpdateVideo(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []VideoSort{VideoSortCreated, VideoSortUpdated, VideoSortTitle, VideoSortDuration} {
+		for _, descending := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s descending=%v", sort, descending), func(t *testing.T) {
+				params := ListVideosParams{UserID: user.ID, Limit: 100, Sort: sort, Descending: descending}
+				all, next, err := c.ListVideos(params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(all) != len(durations) || next != nil {
+					t.Fatalf("got %d videos and cursor %v in one page, want %d and no cursor", len(all), next, len(durations))
+				}
+
+				paged := []Video{}
+				params.Limit = 2
+				for {
+					page, next, err := c.ListVideos(params)
+					if err != nil {
+						t.Fatal(err)
+					}
+					paged = append(paged, page...)
+					if next == nil {
+						break
+					}
+					if len(paged) > len(all) {
+						t.Fatal("paging doesn't end")
+					}
+					params.After = next
+				}
+				if ids(paged) != ids(all) {
+					t.Errorf("pages list\n%s\nwant\n%s", ids(paged), ids(all))
+				}
+			})
+		}
+	}
+
+	titles, _, err := c.ListVideos(ListVideosParams{UserID: user.ID, Limit: 100, Sort: VideoSortTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, video := range titles {
+		got = append(got, video.Title)
+	}
+	if want := []string{"a", "a", "b", "b", "b", "c", "d"}; !slices.Equal(got, want) {
+		t.Errorf("sorted by title: got %q, want %q", got, want)
+	}
+
+	_, next, err := c.ListVideos(ListVideosParams{UserID: user.ID, Limit: 1, Sort: VideoSortTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = c.ListVideos(ListVideosParams{UserID: user.ID, Limit: 1, Sort: VideoSortDuration, After: next})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor for another sort: got %v, want ErrInvalidCursor", err)
+	}
+}
+
+func ids(videos []Video) string {
+	s := []string{}
+	for _, video := range videos {
+		s = append(s, video.ID.String())
+	}
+	return strings.Join(s, "\n")
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
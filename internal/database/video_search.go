@@ -0,0 +1,325 @@
+package database
+
+import (
+	"errors"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+var ErrEmptySearch = errors.New("search query has no words")
+
+// Snippets are generated with control characters around each match so the
+// text can be HTML-escaped before the <mark> tags are put in.
+const (
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+)
+
+type SearchVideosParams struct {
+	UserID uuid.UUID
+	Query  string
+	Limit  int
+}
+
+// VideoSearchResult is a matching video with its title and description
+// excerpted as HTML, matches wrapped in <mark>. Higher ranks match better.
+type VideoSearchResult struct {
+	Video
+	TitleSnippet       string  `json:"title_snippet"`
+	DescriptionSnippet string  `json:"description_snippet"`
+	Rank               float64 `json:"rank"`
+}
+
+// searchTerms splits a user's query into words. Everything that isn't a
+// letter or digit is a separator, so no query syntax reaches the database.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// SearchVideos finds the user's videos whose title or description contains
+// a word starting with each word of the query, so results show up while
+// the user is still typing. Best matches come first.
+func (c Client) SearchVideos(params SearchVideosParams) ([]VideoSearchResult, error) {
+	terms := searchTerms(params.Query)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if params.Limit < 1 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	var query, match string
+	if c.dialect == dialectSQLite {
+		indexed, err := c.tableExists("videos_search")
+		if err != nil {
+			return nil, err
+		}
+		if !indexed {
+			return c.scanSearchVideos(params.UserID, terms, params.Limit)
+		}
+	}
+	if c.dialect == dialectPostgres {
+		for i, term := range terms {
+			terms[i] = term + ":*"
+		}
+		match = strings.Join(terms, " & ")
+		query = `
+		SELECT` + videoColumns + `,
+			ts_headline('simple', title, q, 'HighlightAll=true, StartSel=` + highlightStart + `, StopSel=` + highlightEnd + `'),
+			ts_headline('simple', description, q, 'MaxFragments=1, MaxWords=24, MinWords=8, StartSel=` + highlightStart + `, StopSel=` + highlightEnd + `'),
+			ts_rank(search_vector, q) AS rank
+		FROM videos, to_tsquery('simple', ?) AS q
+		WHERE search_vector @@ q AND user_id = ? AND deleted_at IS NULL
+		ORDER BY rank DESC, created_at DESC
+		LIMIT ?
+		`
+	} else {
+		for i, term := range terms {
+			terms[i] = `"` + term + `"*`
+		}
+		match = strings.Join(terms, " ")
+		query = `
+		SELECT` + videoColumns + `, matches.title_snippet, matches.description_snippet, matches.rank
+		FROM videos
+		JOIN (
+			SELECT
+				video_id,
+				highlight(videos_search, 1, char(2), char(3)) AS title_snippet,
+				snippet(videos_search, 2, char(2), char(3), '…', 24) AS description_snippet,
+				-bm25(videos_search, 0, 10.0, 1.0) AS rank
+			FROM videos_search
+			WHERE videos_search MATCH ?
+		) AS matches ON matches.video_id = videos.id
+		WHERE user_id = ? AND deleted_at IS NULL
+		ORDER BY matches.rank DESC, created_at DESC
+		LIMIT ?
+		`
+	}
+
+	rows, err := c.query(query, match, params.UserID, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []VideoSearchResult{}
+	for rows.Next() {
+		var result VideoSearchResult
+		result.Video, err = scanVideo(withExtraColumns(rows, &result.TitleSnippet, &result.DescriptionSnippet, &result.Rank))
+		if err != nil {
+			return nil, err
+		}
+		result.TitleSnippet = highlightHTML(result.TitleSnippet)
+		result.DescriptionSnippet = highlightHTML(result.DescriptionSnippet)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		err = c.loadVideoDetails(&results[i].Video)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// scanSearchVideos is SearchVideos for SQLite databases without an FTS5
+// index. LIKE narrows the videos down to those containing every term, then
+// search_rank matches them on word prefixes and ranks them the way the
+// index would.
+func (c Client) scanSearchVideos(userID uuid.UUID, terms []string, limit int) ([]VideoSearchResult, error) {
+	joinedTerms := strings.Join(terms, " ")
+	where := []string{"user_id = ?", "deleted_at IS NULL"}
+	args := []any{joinedTerms, userID}
+	for _, term := range terms {
+		// Terms are only letters and digits, so they need no escaping.
+		where = append(where, "(title LIKE ? OR description LIKE ?)")
+		args = append(args, "%"+term+"%", "%"+term+"%")
+	}
+	args = append(args, limit)
+	rows, err := c.query(`
+	SELECT`+videoColumns+`, rank
+	FROM (
+		SELECT *, search_rank(title, description, ?) AS rank
+		FROM videos
+		WHERE `+strings.Join(where, " AND ")+`
+	)
+	WHERE rank > 0
+	ORDER BY rank DESC, created_at DESC
+	LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []VideoSearchResult{}
+	for rows.Next() {
+		var result VideoSearchResult
+		result.Video, err = scanVideo(withExtraColumns(rows, &result.Rank))
+		if err != nil {
+			return nil, err
+		}
+		result.TitleSnippet = highlightHTML(highlightPrefixes(result.Title, terms))
+		result.DescriptionSnippet = highlightHTML(excerpt(result.Description, terms, snippetWords))
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		err = c.loadVideoDetails(&results[i].Video)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// searchRank backs the search_rank(title, description, terms) SQL function.
+// terms are separated by spaces. Like the FTS5 index, a word of the title
+// counts ten times a word of the description; videos that don't match
+// every term rank 0.
+func searchRank(title, description, terms string) float64 {
+	split := strings.Fields(terms)
+	if !matchesEveryTerm(title+" "+description, split) {
+		return 0
+	}
+	return float64(10*countPrefixMatches(title, split) + countPrefixMatches(description, split))
+}
+
+// snippetWords is how many words a description snippet shows, as in the
+// FTS5 snippet() call.
+const snippetWords = 24
+
+// word is a run of letters and digits in some text, the same units
+// searchTerms splits queries into.
+type word struct {
+	start, end int
+	lower      string
+}
+
+func words(text string) []word {
+	found := []word{}
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsNumber(r)
+		switch {
+		case isWordRune && start < 0:
+			start = i
+		case !isWordRune && start >= 0:
+			found = append(found, word{start, i, strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		found = append(found, word{start, len(text), strings.ToLower(text[start:])})
+	}
+	return found
+}
+
+func startsWithAny(w word, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(w.lower, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// countPrefixMatches counts the words of text that start with a term.
+func countPrefixMatches(text string, terms []string) int {
+	n := 0
+	for _, w := range words(text) {
+		if startsWithAny(w, terms) {
+			n++
+		}
+	}
+	return n
+}
+
+// matchesEveryTerm reports whether every term starts some word of text.
+func matchesEveryTerm(text string, terms []string) bool {
+	ws := words(text)
+	for _, term := range terms {
+		if !slices.ContainsFunc(ws, func(w word) bool { return strings.HasPrefix(w.lower, term) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// highlightPrefixes wraps every word of text that starts with a term in
+// highlightStart and highlightEnd.
+func highlightPrefixes(text string, terms []string) string {
+	var b strings.Builder
+	last := 0
+	for _, w := range words(text) {
+		if !startsWithAny(w, terms) {
+			continue
+		}
+		b.WriteString(text[last:w.start])
+		b.WriteString(highlightStart + text[w.start:w.end] + highlightEnd)
+		last = w.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// excerpt highlights at most maxWords words of text, starting at the first
+// match, with an ellipsis where text was cut.
+func excerpt(text string, terms []string, maxWords int) string {
+	ws := words(text)
+	if len(ws) <= maxWords {
+		return highlightPrefixes(text, terms)
+	}
+	first := slices.IndexFunc(ws, func(w word) bool { return startsWithAny(w, terms) })
+	first = max(0, min(first, len(ws)-maxWords))
+	last := first + maxWords - 1
+
+	start, end := ws[first].start, ws[last].end
+	if first == 0 {
+		start = 0
+	}
+	if last == len(ws)-1 {
+		end = len(text)
+	}
+	snippet := highlightPrefixes(text[start:end], terms)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// extraColumns scans columns selected after videoColumns.
+type extraColumns struct {
+	row   rowScanner
+	extra []any
+}
+
+func withExtraColumns(row rowScanner, extra ...any) rowScanner {
+	return extraColumns{row: row, extra: extra}
+}
+
+func (e extraColumns) Scan(dest ...any) error {
+	return e.row.Scan(append(dest, e.extra...)...)
+}
+
+func highlightHTML(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightEnd, "</mark>")
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "Dragon", want: []string{"dragon"}},
+		{query: "  boots & the DRAGON ", want: []string{"boots", "the", "dragon"}},
+		{query: `"quoted" OR title:* NEAR(a b)`, want: []string{"quoted", "or", "title", "near", "a", "b"}},
+		{query: "Café 2024", want: []string{"café", "2024"}},
+		{query: "*:-()", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := searchTerms(tt.query)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightPrefixes(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{
+			name:  "prefix",
+			text:  "Boots & the dragon",
+			terms: []string{"drag"},
+			want:  "Boots & the [dragon]",
+		},
+		{
+			name:  "case and several terms",
+			text:  "Dragons, DRAGONFLIES and boots",
+			terms: []string{"dragon", "boot"},
+			want:  "[Dragons], [DRAGONFLIES] and [boots]",
+		},
+		{
+			name:  "only word starts",
+			text:  "snapdragon",
+			terms: []string{"dragon"},
+			want:  "snapdragon",
+		},
+		{
+			name:  "unicode",
+			text:  "Ünïcode café",
+			terms: []string{"ünï", "caf"},
+			want:  "[Ünïcode] [café]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := showHighlights(highlightPrefixes(tt.text, tt.terms))
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{
+			name:  "short text is kept whole",
+			text:  "one two three.",
+			terms: []string{"two"},
+			want:  "one [two] three.",
+		},
+		{
+			name:  "starts at the first match",
+			text:  "one two three four five six",
+			terms: []string{"three"},
+			want:  "…[three] four five…",
+		},
+		{
+			name:  "keeps the last words whole",
+			text:  "one two three four five six.",
+			terms: []string{"six"},
+			want:  "…four five [six].",
+		},
+		{
+			name:  "no match starts at the beginning",
+			text:  "one two three four five six",
+			terms: []string{"seven"},
+			want:  "one two three…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := showHighlights(excerpt(tt.text, tt.terms, 3))
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightHTML(t *testing.T) {
+	got := highlightHTML("<b>" + highlightStart + "Boots & co" + highlightEnd + "</b>")
+	want := "&lt;b&gt;<mark>Boots &amp; co</mark>&lt;/b&gt;"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSearchRank(t *testing.T) {
+	tests := []struct {
+		title, description, terms string
+		want                      float64
+	}{
+		{title: "Dragon boat race", description: "Boats on the river.", terms: "drag", want: 10},
+		{title: "Dragon boat race", description: "Boats on the river.", terms: "boat", want: 11},
+		{title: "Cooking pasta", description: "A dragon watches.", terms: "dragon", want: 1},
+		{title: "Dragon boat race", description: "Boats on the river.", terms: "dragon river", want: 11},
+		// LIKE finds these, but no word starts with the term.
+		{title: "Snapdragon benchmarks", description: "Phones.", terms: "drag", want: 0},
+		{title: "Dragon boat race", description: "Boats on the river.", terms: "dragon pizza", want: 0},
+	}
+	for _, tt := range tests {
+		if got := searchRank(tt.title, tt.description, tt.terms); got != tt.want {
+			t.Errorf("searchRank(%q, %q, %q) = %v, want %v", tt.title, tt.description, tt.terms, got, tt.want)
+		}
+	}
+}
+
+// TestSearchVideosRanking runs against whichever search the build
+// supports: the FTS5 index with -tags sqlite_fts5, the scan without.
+func TestSearchVideosRanking(t *testing.T) {
+	c, err := NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	user, err := c.CreateUser(CreateUserParams{Email: "search@example.com", Password: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := c.CreateUser(CreateUserParams{Email: "other@example.com", Password: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, params := range []CreateVideoParams{
+		{Title: "Cooking pasta", Description: "A dragon watches.", UserID: user.ID},
+		{Title: "Dragon boat race", Description: "Boats on the river.", UserID: user.ID},
+		{Title: "Snapdragon benchmarks", Description: "Phones.", UserID: user.ID},
+		{Title: "Dragon fruit", Description: "Someone else's video.", UserID: other.ID},
+	} {
+		if _, err := c.CreateVideo(params); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		{query: "drag", limit: 10, want: []string{"Dragon boat race", "Cooking pasta"}},
+		{query: "drag", limit: 1, want: []string{"Dragon boat race"}},
+		{query: "dragon river", limit: 10, want: []string{"Dragon boat race"}},
+		{query: "dragon pizza", limit: 10, want: []string{}},
+		{query: "fruit", limit: 10, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := c.SearchVideos(SearchVideosParams{UserID: user.ID, Query: tt.query, Limit: tt.limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, result := range results {
+				got = append(got, result.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err = c.SearchVideos(SearchVideosParams{UserID: user.ID, Query: "&&", Limit: 10})
+	if !errors.Is(err, ErrEmptySearch) {
+		t.Errorf("got %v, want ErrEmptySearch", err)
+	}
+}
+
+// showHighlights makes highlighted words readable in test output.
+func showHighlights(s string) string {
+	return strings.NewReplacer(highlightStart, "[", highlightEnd, "]").Replace(s)
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VideoVersion is one upload of a video's media. Versions are numbered from
+// 1 per video.
+type VideoVersion struct {
+	ID         uuid.UUID `json:"id"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	Version    int       `json:"version"`
+	CreateVideoVersionParams
+}
+
+// CreateVideoVersionParams describes the uploaded media. Size and checksum
+// are nil for media uploaded before versions were recorded.
+type CreateVideoVersionParams struct {
+	VideoID            uuid.UUID `json:"video_id"`
+	VideoURL           string    `json:"video_url"`
+	PreviewURL         *string   `json:"preview_url"`
+	PreviewVideoURL    *string   `json:"preview_video_url"`
+	SizeBytes          *int64    `json:"size_bytes"`
+	ChecksumSHA256     *string   `json:"checksum_sha256"`
+	DurationSeconds    *float64  `json:"duration_seconds"`
+	Width              *int      `json:"width"`
+	Height             *int      `json:"height"`
+	AspectRatio        *string   `json:"aspect_ratio"`
+	IntegratedLoudness *float64  `json:"integrated_loudness"`
+}
+
+const videoVersionColumns = `
+		id,
+		uploaded_at,
+		version,
+		video_id,
+		video_url,
+		preview_url,
+		preview_video_url,
+		size_bytes,
+		checksum_sha256,
+		duration_seconds,
+		width,
+		height,
+		aspect_ratio,
+		integrated_loudness`
+
+func scanVideoVersion(row rowScanner) (VideoVersion, error) {
+	var v VideoVersion
+	err := row.Scan(
+		&v.ID,
+		&v.UploadedAt,
+		&v.Version,
+		&v.VideoID,
+		&v.VideoURL,
+		&v.PreviewURL,
+		&v.PreviewVideoURL,
+		&v.SizeBytes,
+		&v.ChecksumSHA256,
+		&v.DurationSeconds,
+		&v.Width,
+		&v.Height,
+		&v.AspectRatio,
+		&v.IntegratedLoudness,
+	)
+	return v, err
+}
+
+// CreateVideoVersion records a new upload as the next version of the
+// video. It doesn't make the version current; update the video for that.
+func (c Client) CreateVideoVersion(params CreateVideoVersionParams) (VideoVersion, error) {
+	id := uuid.New()
+	err := c.inTransaction(func(t transaction) error {
+		var version int
+		err := t.queryRow("SELECT COALESCE(MAX(version), 0) + 1 FROM video_versions WHERE video_id = ?", params.VideoID).Scan(&version)
+		if err != nil {
+			return err
+		}
+
+		query := `
+		INSERT INTO video_versions (
+			id,
+			uploaded_at,
+			version,
+			video_id,
+			video_url,
+			preview_url,
+			preview_video_url,
+			size_bytes,
+			checksum_sha256,
+			duration_seconds,
+			width,
+			height,
+			aspect_ratio,
+			integrated_loudness
+		) VALUES (?, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`
+		_, err = t.exec(
+			query,
+			id,
+			version,
+			params.VideoID,
+			params.VideoURL,
+			params.PreviewURL,
+			params.PreviewVideoURL,
+			params.SizeBytes,
+			params.ChecksumSHA256,
+			params.DurationSeconds,
+			params.Width,
+			params.Height,
+			params.AspectRatio,
+			params.IntegratedLoudness,
+		)
+		return err
+	})
+	if err != nil {
+		return VideoVersion{}, err
+	}
+
+	return c.GetVideoVersion(id)
+}
+
+// GetVideoVersion returns a version, or a zero VideoVersion if it doesn't
+// exist.
+func (c Client) GetVideoVersion(id uuid.UUID) (VideoVersion, error) {
+	query := `
+	SELECT` + videoVersionColumns + `
+	FROM video_versions
+	WHERE id = ?
+	`
+
+	v, err := scanVideoVersion(c.queryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return VideoVersion{}, nil
+		}
+		return VideoVersion{}, err
+	}
+	return v, nil
+}
+
+// GetVideoVersions returns a video's versions, newest first.
+func (c Client) GetVideoVersions(videoID uuid.UUID) ([]VideoVersion, error) {
+	query := `
+	SELECT` + videoVersionColumns + `
+	FROM video_versions
+	WHERE video_id = ?
+	ORDER BY version DESC
+	`
+	rows, err := c.query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []VideoVersion{}
+	for rows.Next() {
+		v, err := scanVideoVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (c Client) DeleteVideoVersion(id uuid.UUID) error {
+	_, err := c.exec("DELETE FROM video_versions WHERE id = ?", id)
+	return err
+}
@@ -8,24 +8,56 @@ import (
 	"github.com/google/uuid"
 )
 
+var ErrVideoModified = errors.New("video was modified since it was read")
+
+// Who can see a video. Private videos are shown to their owner only,
+// unlisted ones to anyone who has the ID, and public ones are also listed
+// in the catalog.
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
+
 type Video struct {
 	ID           uuid.UUID `json:"id"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	ThumbnailURL *string   `json:"thumbnail_url"`
 	VideoURL     *string   `json:"video_url"`
+	// PreviewURL is a short silent animated WebP of the video and
+	// PreviewVideoURL the same preview as a muted MP4.
+	PreviewURL      *string `json:"preview_url"`
+	PreviewVideoURL *string `json:"preview_video_url"`
+	// IntegratedLoudness is the EBU R128 integrated loudness of the stored
+	// media in LUFS, nil if it was never measured or the video is silent.
+	IntegratedLoudness *float64 `json:"integrated_loudness"`
+	DurationSeconds    *float64 `json:"duration_seconds"`
+	// AspectRatio is "landscape", "portrait" or "other" once media exists.
+	AspectRatio *string `json:"aspect_ratio"`
+	// DeletedAt is set while the video is in the trash.
+	DeletedAt *time.Time `json:"deleted_at"`
+	// CurrentVersionID is the VideoVersion the media fields above were
+	// copied from.
+	CurrentVersionID *uuid.UUID `json:"current_version_id"`
+	// Revision goes up whenever the video, its tags or its subtitles
+	// change. UpdateVideo only saves a video whose revision is current.
+	Revision int `json:"revision"`
 	CreateVideoParams
+	SubtitleTracks []SubtitleTrack `json:"subtitle_tracks"`
+	Tags           []string        `json:"tags"`
 }
 
 type CreateVideoParams struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
 	UserID      uuid.UUID `json:"user_id"`
+	// Visibility defaults to VisibilityPrivate.
+	Visibility string `json:"visibility"`
 }
 
-func (c Client) GetVideos(userID uuid.UUID) ([]Video, error) {
-	query := `
-	SELECT
+// videoColumns lists the columns scanVideo expects, in order.
+const videoColumns = `
 		id,
 		created_at,
 		updated_at,
@@ -33,13 +65,61 @@ func (c Client) GetVideos(userID uuid.UUID) ([]Video, error) {
 		description,
 		thumbnail_url,
 		video_url,
-		user_id
-	FROM videos
-	WHERE user_id = ?
-	ORDER BY created_at DESC
-	`
+		preview_url,
+		preview_video_url,
+		integrated_loudness,
+		duration_seconds,
+		aspect_ratio,
+		deleted_at,
+		current_version_id,
+		revision,
+		user_id,
+		visibility`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanVideo(row rowScanner) (Video, error) {
+	var video Video
+	err := row.Scan(
+		&video.ID,
+		&video.CreatedAt,
+		&video.UpdatedAt,
+		&video.Title,
+		&video.Description,
+		&video.ThumbnailURL,
+		&video.VideoURL,
+		&video.PreviewURL,
+		&video.PreviewVideoURL,
+		&video.IntegratedLoudness,
+		&video.DurationSeconds,
+		&video.AspectRatio,
+		&video.DeletedAt,
+		&video.CurrentVersionID,
+		&video.Revision,
+		&video.UserID,
+		&video.Visibility,
+	)
+	return video, err
+}
+
+// loadVideoDetails fills in the parts of a video stored outside the
+// videos table.
+func (c Client) loadVideoDetails(video *Video) error {
+	var err error
+	video.SubtitleTracks, err = c.GetSubtitleTracks(video.ID)
+	if err != nil {
+		return err
+	}
+	video.Tags, err = c.GetVideoTags(video.ID)
+	return err
+}
 
-	rows, err := c.db.Query(query, userID)
+// queryVideos runs a query selecting videoColumns and attaches each
+// video's subtitle tracks and tags.
+func (c Client) queryVideos(query string, args ...any) ([]Video, error) {
+	rows, err := c.query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -47,25 +127,54 @@ func (c Client) GetVideos(userID uuid.UUID) ([]Video, error) {
 
 	videos := []Video{}
 	for rows.Next() {
-		var video Video
-		if err := rows.Scan(
-			&video.ID,
-			&video.CreatedAt,
-			&video.UpdatedAt,
-			&video.Title,
-			&video.Description,
-			&video.ThumbnailURL,
-			&video.VideoURL,
-			&video.UserID,
-		); err != nil {
+		video, err := scanVideo(rows)
+		if err != nil {
 			return nil, err
 		}
 		videos = append(videos, video)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range videos {
+		err = c.loadVideoDetails(&videos[i])
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return videos, nil
 }
 
+func (c Client) GetVideos(userID uuid.UUID) ([]Video, error) {
+	query := `
+	SELECT` + videoColumns + `
+	FROM videos
+	WHERE user_id = ? AND deleted_at IS NULL
+	ORDER BY created_at DESC
+	`
+
+	return c.queryVideos(query, userID)
+}
+
+// GetVideosOutsideLoudness returns the user's videos whose measured
+// integrated loudness falls outside [min, max] LUFS. Videos that were never
+// measured are not included.
+func (c Client) GetVideosOutsideLoudness(userID uuid.UUID, min, max float64) ([]Video, error) {
+	query := `
+	SELECT` + videoColumns + `
+	FROM videos
+	WHERE user_id = ?
+		AND deleted_at IS NULL
+		AND integrated_loudness IS NOT NULL
+		AND (integrated_loudness < ? OR integrated_loudness > ?)
+	ORDER BY created_at DESC
+	`
+
+	return c.queryVideos(query, userID, min, max)
+}
+
 func (c Client) CreateVideo(params CreateVideoParams) (Video, error) {
 	id := uuid.New()
 	query := `
@@ -75,10 +184,15 @@ func (c Client) CreateVideo(params CreateVideoParams) (Video, error) {
 		updated_at,
 		title,
 		description,
-		user_id
-	) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, ?)
+		user_id,
+		visibility
+	) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, ?, ?)
 	`
-	_, err := c.db.Exec(query, id, params.Title, params.Description, params.UserID)
+	visibility := params.Visibility
+	if visibility == "" {
+		visibility = VisibilityPrivate
+	}
+	_, err := c.exec(query, id, params.Title, params.Description, params.UserID, visibility)
 	if err != nil {
 		return Video{}, err
 	}
@@ -86,31 +200,28 @@ func (c Client) CreateVideo(params CreateVideoParams) (Video, error) {
 	return c.GetVideo(id)
 }
 
+// GetVideo returns a video that isn't in the trash, or a zero Video.
 func (c Client) GetVideo(id uuid.UUID) (Video, error) {
+	return c.getVideo(id, false)
+}
+
+// GetDeletedVideo returns a video that is in the trash, or a zero Video.
+func (c Client) GetDeletedVideo(id uuid.UUID) (Video, error) {
+	return c.getVideo(id, true)
+}
+
+func (c Client) getVideo(id uuid.UUID, deleted bool) (Video, error) {
+	trashed := "deleted_at IS NULL"
+	if deleted {
+		trashed = "deleted_at IS NOT NULL"
+	}
 	query := `
-	SELECT
-		id,
-		created_at,
-		updated_at,
-		title,
-		description,
-		thumbnail_url,
-		video_url,
-		user_id
+	SELECT` + videoColumns + `
 	FROM videos
-	WHERE id = ?
+	WHERE id = ? AND ` + trashed + `
 	`
 
-	var video Video
-	err := c.db.QueryRow(query, id).Scan(
-		&video.ID,
-		&video.CreatedAt,
-		&video.UpdatedAt,
-		&video.Title,
-		&video.Description,
-		&video.ThumbnailURL,
-		&video.VideoURL,
-		&video.UserID)
+	video, err := scanVideo(c.queryRow(query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Video{}, nil
@@ -118,38 +229,148 @@ func (c Client) GetVideo(id uuid.UUID) (Video, error) {
 		return Video{}, err
 	}
 
+	err = c.loadVideoDetails(&video)
+	if err != nil {
+		return Video{}, err
+	}
+
 	return video, nil
 }
 
+// UpdateVideo saves a video and bumps its revision. It returns
+// ErrVideoModified, saving nothing, if video.Revision is no longer the
+// stored revision because someone else changed the video since it was read.
 func (c Client) UpdateVideo(video Video) error {
 	query := `
 	UPDATE videos
 	SET
+		updated_at = CURRENT_TIMESTAMP,
+		revision = revision + 1,
 		title = ?,
 		description = ?,
 		thumbnail_url = ?,
 		video_url = ?,
-		user_id = ?
-	WHERE id = ?
+		preview_url = ?,
+		preview_video_url = ?,
+		integrated_loudness = ?,
+		duration_seconds = ?,
+		aspect_ratio = ?,
+		current_version_id = ?,
+		user_id = ?,
+		visibility = ?
+	WHERE id = ? AND revision = ?
 	`
 
-	_, err := c.db.Exec(
+	result, err := c.exec(
 		query,
 		video.Title,
 		video.Description,
 		&video.ThumbnailURL,
 		&video.VideoURL,
+		video.PreviewURL,
+		video.PreviewVideoURL,
+		video.IntegratedLoudness,
+		video.DurationSeconds,
+		video.AspectRatio,
+		video.CurrentVersionID,
 		video.UserID,
+		video.Visibility,
 		video.ID,
+		video.Revision,
 	)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrVideoModified
+	}
+	return nil
+}
+
+// touchVideo marks a video whose tags or subtitles changed as updated.
+func (c Client) touchVideo(id uuid.UUID) error {
+	_, err := c.exec("UPDATE videos SET updated_at = CURRENT_TIMESTAMP, revision = revision + 1 WHERE id = ?", id)
 	return err
 }
 
-func (c Client) DeleteVideo(id uuid.UUID) error {
+// GetDeletedVideos returns the user's trashed videos, most recently
+// deleted first.
+func (c Client) GetDeletedVideos(userID uuid.UUID) ([]Video, error) {
 	query := `
-	DELETE FROM videos
-	WHERE id = ?
+	SELECT` + videoColumns + `
+	FROM videos
+	WHERE user_id = ? AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC
 	`
-	_, err := c.db.Exec(query, id)
+
+	return c.queryVideos(query, userID)
+}
+
+// GetVideosDeletedBefore returns every trashed video, of any user, that was
+// deleted before cutoff.
+func (c Client) GetVideosDeletedBefore(cutoff time.Time) ([]Video, error) {
+	query := `
+	SELECT` + videoColumns + `
+	FROM videos
+	WHERE deleted_at IS NOT NULL AND deleted_at < ?
+	ORDER BY deleted_at
+	`
+
+	return c.queryVideos(query, formatTimestamp(cutoff))
+}
+
+// TrashVideo moves a video to the trash. It keeps its media, tags,
+// subtitles and playlist memberships until it is purged.
+func (c Client) TrashVideo(id uuid.UUID) error {
+	_, err := c.exec("UPDATE videos SET deleted_at = CURRENT_TIMESTAMP, revision = revision + 1 WHERE id = ? AND deleted_at IS NULL", id)
 	return err
 }
+
+func (c Client) RestoreVideo(id uuid.UUID) error {
+	_, err := c.exec("UPDATE videos SET deleted_at = NULL, revision = revision + 1 WHERE id = ? AND deleted_at IS NOT NULL", id)
+	return err
+}
+
+// CountVideosWithThumbnail counts videos, trashed or not, that use a
+// thumbnail. Clips share their source's thumbnail.
+func (c Client) CountVideosWithThumbnail(thumbnailURL string) (int, error) {
+	var count int
+	err := c.queryRow("SELECT COUNT(*) FROM videos WHERE thumbnail_url = ?", thumbnailURL).Scan(&count)
+	return count, err
+}
+
+// DeleteVideo permanently deletes a video and everything attached to it.
+func (c Client) DeleteVideo(id uuid.UUID) error {
+	return c.inTransaction(func(t transaction) error {
+		_, err := t.exec("DELETE FROM subtitle_tracks WHERE video_id = ?", id)
+		if err != nil {
+			return err
+		}
+		_, err = t.exec("DELETE FROM video_tags WHERE video_id = ?", id)
+		if err != nil {
+			return err
+		}
+		_, err = t.exec(`
+		UPDATE playlists
+		SET updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (SELECT playlist_id FROM playlist_items WHERE video_id = ?)
+		`, id)
+		if err != nil {
+			return err
+		}
+		_, err = t.exec("DELETE FROM playlist_items WHERE video_id = ?", id)
+		if err != nil {
+			return err
+		}
+		_, err = t.exec("DELETE FROM video_versions WHERE video_id = ?", id)
+		if err != nil {
+			return err
+		}
+		_, err = t.exec("DELETE FROM videos WHERE id = ?", id)
+		return err
+	})
+}
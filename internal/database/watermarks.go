@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WatermarkSettings holds a user's overrides of the deployment watermark.
+// Nil fields fall back to the deployment defaults.
+type WatermarkSettings struct {
+	UserID    uuid.UUID `json:"user_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Enabled   bool      `json:"enabled"`
+	ImagePath *string   `json:"-"`
+	Position  *string   `json:"position"`
+	Opacity   *float64  `json:"opacity"`
+	Scale     *float64  `json:"scale"`
+}
+
+// GetWatermarkSettings returns the user's watermark settings. Users who never
+// changed them get the watermark enabled with no overrides.
+func (c Client) GetWatermarkSettings(userID uuid.UUID) (WatermarkSettings, error) {
+	query := `
+	SELECT
+		user_id,
+		updated_at,
+		enabled,
+		image_path,
+		position,
+		opacity,
+		scale
+	FROM watermark_settings
+	WHERE user_id = ?
+	`
+
+	var settings WatermarkSettings
+	err := c.queryRow(query, userID).Scan(
+		&settings.UserID,
+		&settings.UpdatedAt,
+		&settings.Enabled,
+		&settings.ImagePath,
+		&settings.Position,
+		&settings.Opacity,
+		&settings.Scale,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return WatermarkSettings{UserID: userID, Enabled: true}, nil
+		}
+		return WatermarkSettings{}, err
+	}
+
+	return settings, nil
+}
+
+func (c Client) UpsertWatermarkSettings(settings WatermarkSettings) (WatermarkSettings, error) {
+	query := `
+	INSERT INTO watermark_settings (
+		user_id,
+		updated_at,
+		enabled,
+		image_path,
+		position,
+		opacity,
+		scale
+	) VALUES (?, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET
+		updated_at = CURRENT_TIMESTAMP,
+		enabled = excluded.enabled,
+		image_path = excluded.image_path,
+		position = excluded.position,
+		opacity = excluded.opacity,
+		scale = excluded.scale
+	`
+	_, err := c.exec(
+		query,
+		settings.UserID,
+		settings.Enabled,
+		settings.ImagePath,
+		settings.Position,
+		settings.Opacity,
+		settings.Scale,
+	)
+	if err != nil {
+		return WatermarkSettings{}, err
+	}
+
+	return c.GetWatermarkSettings(settings.UserID)
+}
@@ -0,0 +1,281 @@
+package subtitles
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatSRT    Format = "srt"
+	FormatWebVTT Format = "vtt"
+)
+
+type Cue struct {
+	ID       string
+	Start    time.Duration
+	End      time.Duration
+	Settings string
+	Text     string
+}
+
+var ErrNoCues = errors.New("subtitle file contains no cues")
+
+var (
+	srtTimestamp = regexp.MustCompile(`^(\d{1,2}):(\d{2}):(\d{2})[,.](\d{3})$`)
+	vttTimestamp = regexp.MustCompile(`^(?:(\d{1,}):)?(\d{2}):(\d{2})\.(\d{3})$`)
+	fontTag      = regexp.MustCompile(`(?i)</?font[^>]*>`)
+)
+
+// DetectFormat guesses the subtitle format from the file contents.
+func DetectFormat(data []byte) Format {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if bytes.HasPrefix(data, []byte("WEBVTT")) {
+		return FormatWebVTT
+	}
+	return FormatSRT
+}
+
+// Parse detects the format of data and parses its cues.
+func Parse(data []byte) ([]Cue, error) {
+	switch DetectFormat(data) {
+	case FormatWebVTT:
+		return ParseVTT(bytes.NewReader(data))
+	default:
+		return ParseSRT(bytes.NewReader(data))
+	}
+}
+
+// ParseSRT parses SubRip cues. Formatting tags that WebVTT doesn't
+// understand (<font>) are stripped from the cue text.
+func ParseSRT(r io.Reader) ([]Cue, error) {
+	blocks, err := readBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cues := []Cue{}
+	for _, b := range blocks {
+		lines := b.lines
+		if len(lines) < 3 {
+			return nil, fmt.Errorf("line %d: cue must have an index, a timing line and text", b.line)
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(lines[0])); err != nil {
+			return nil, fmt.Errorf("line %d: invalid cue index %q", b.line, lines[0])
+		}
+
+		start, end, _, err := parseTiming(lines[1], srtTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", b.line+1, err)
+		}
+
+		text := fontTag.ReplaceAllString(strings.Join(lines[2:], "\n"), "")
+		cue := Cue{
+			ID:    strings.TrimSpace(lines[0]),
+			Start: start,
+			End:   end,
+			Text:  text,
+		}
+		if err := validateCue(cue); err != nil {
+			return nil, fmt.Errorf("line %d: %w", b.line, err)
+		}
+		cues = append(cues, cue)
+	}
+
+	if len(cues) == 0 {
+		return nil, ErrNoCues
+	}
+	return cues, nil
+}
+
+// ParseVTT parses WebVTT cues. NOTE, STYLE and REGION blocks are skipped.
+func ParseVTT(r io.Reader) ([]Cue, error) {
+	blocks, err := readBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 || !isVTTHeader(blocks[0].lines[0]) {
+		return nil, errors.New("line 1: missing WEBVTT header")
+	}
+
+	cues := []Cue{}
+	for _, b := range blocks[1:] {
+		lines := b.lines
+		first := lines[0]
+		if first == "NOTE" || strings.HasPrefix(first, "NOTE ") || strings.HasPrefix(first, "NOTE\t") ||
+			first == "STYLE" || first == "REGION" {
+			continue
+		}
+
+		line := b.line
+		var id string
+		if !strings.Contains(first, "-->") {
+			id = first
+			lines = lines[1:]
+			line++
+		}
+		if len(lines) < 2 {
+			return nil, fmt.Errorf("line %d: cue must have a timing line and text", b.line)
+		}
+
+		start, end, settings, err := parseTiming(lines[0], vttTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		cue := Cue{
+			ID:       id,
+			Start:    start,
+			End:      end,
+			Settings: settings,
+			Text:     strings.Join(lines[1:], "\n"),
+		}
+		if err := validateCue(cue); err != nil {
+			return nil, fmt.Errorf("line %d: %w", b.line, err)
+		}
+		cues = append(cues, cue)
+	}
+
+	if len(cues) == 0 {
+		return nil, ErrNoCues
+	}
+	return cues, nil
+}
+
+// WriteVTT writes cues as a WebVTT document.
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		bw.WriteString("\n")
+		if cue.ID != "" {
+			bw.WriteString(cue.ID + "\n")
+		}
+		bw.WriteString(formatVTTTimestamp(cue.Start) + " --> " + formatVTTTimestamp(cue.End))
+		if cue.Settings != "" {
+			bw.WriteString(" " + cue.Settings)
+		}
+		bw.WriteString("\n" + cue.Text + "\n")
+	}
+	return bw.Flush()
+}
+
+// Duration returns the end time of the last cue.
+func Duration(cues []Cue) time.Duration {
+	var d time.Duration
+	for _, cue := range cues {
+		if cue.End > d {
+			d = cue.End
+		}
+	}
+	return d
+}
+
+type block struct {
+	line  int
+	lines []string
+}
+
+func readBlocks(r io.Reader) ([]block, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	blocks := []block{}
+	var current *block
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.TrimSpace(line) == "" {
+			current = nil
+			continue
+		}
+		if current == nil {
+			blocks = append(blocks, block{line: lineNo})
+			current = &blocks[len(blocks)-1]
+		}
+		current.lines = append(current.lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+func isVTTHeader(line string) bool {
+	return line == "WEBVTT" || strings.HasPrefix(line, "WEBVTT ") || strings.HasPrefix(line, "WEBVTT\t")
+}
+
+func parseTiming(line string, timestamp *regexp.Regexp) (time.Duration, time.Duration, string, error) {
+	startStr, rest, ok := strings.Cut(line, "-->")
+	if !ok {
+		return 0, 0, "", fmt.Errorf("invalid timing line %q", line)
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return 0, 0, "", fmt.Errorf("missing end timestamp in %q", line)
+	}
+
+	start, err := parseTimestamp(strings.TrimSpace(startStr), timestamp)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	end, err := parseTimestamp(fields[0], timestamp)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return start, end, strings.Join(fields[1:], " "), nil
+}
+
+func parseTimestamp(s string, timestamp *regexp.Regexp) (time.Duration, error) {
+	m := timestamp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	hours, _ := strconv.Atoi(m[1])
+	minutes, _ := strconv.Atoi(m[2])
+	seconds, _ := strconv.Atoi(m[3])
+	millis, _ := strconv.Atoi(m[4])
+	if minutes > 59 || seconds > 59 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second +
+		time.Duration(millis)*time.Millisecond, nil
+}
+
+func validateCue(cue Cue) error {
+	if cue.End <= cue.Start {
+		return fmt.Errorf("cue ends at %s, before it starts at %s", formatVTTTimestamp(cue.End), formatVTTTimestamp(cue.Start))
+	}
+	if strings.Contains(cue.Text, "-->") {
+		return errors.New("cue text must not contain \"-->\"")
+	}
+	if strings.Contains(cue.ID, "-->") {
+		return errors.New("cue identifier must not contain \"-->\"")
+	}
+	return nil
+}
+
+func formatVTTTimestamp(d time.Duration) string {
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	ms := d / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
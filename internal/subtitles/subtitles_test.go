@@ -0,0 +1,138 @@
+package subtitles
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ms(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Cue
+		wantErr string
+	}{
+		{
+			name: "srt",
+			input: "1\n00:00:01,000 --> 00:00:02,500\nHello\n\n" +
+				"2\n00:00:03,000 --> 00:00:04,000\n<font color=\"red\">Two</font>\nlines\n",
+			want: []Cue{
+				{ID: "1", Start: ms(1000), End: ms(2500), Text: "Hello"},
+				{ID: "2", Start: ms(3000), End: ms(4000), Text: "Two\nlines"},
+			},
+		},
+		{
+			name:  "srt with byte order mark and CRLF",
+			input: "\ufeff1\r\n00:00:01.000 --> 00:00:02.000\r\nHello\r\n",
+			want:  []Cue{{ID: "1", Start: ms(1000), End: ms(2000), Text: "Hello"}},
+		},
+		{
+			name: "vtt",
+			input: "WEBVTT - a title\n\nNOTE a comment\n\nSTYLE\n::cue { color: red }\n\n" +
+				"intro\n00:01.000 --> 00:02.000 align:start\nHello\n\n" +
+				"01:00:00.000 --> 01:00:01.250\n<b>Bye</b>\n",
+			want: []Cue{
+				{ID: "intro", Start: ms(1000), End: ms(2000), Settings: "align:start", Text: "Hello"},
+				{Start: time.Hour, End: time.Hour + ms(1250), Text: "<b>Bye</b>"},
+			},
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: ErrNoCues.Error(),
+		},
+		{
+			name:    "vtt header only",
+			input:   "WEBVTT\n",
+			wantErr: ErrNoCues.Error(),
+		},
+		{
+			name:    "srt without index",
+			input:   "00:00:01,000 --> 00:00:02,000\nHello\nthere\n",
+			wantErr: `line 1: invalid cue index "00:00:01,000 --> 00:00:02,000"`,
+		},
+		{
+			name:    "srt without text",
+			input:   "1\n00:00:01,000 --> 00:00:02,000\n",
+			wantErr: "line 1: cue must have an index, a timing line and text",
+		},
+		{
+			name:    "invalid timestamp",
+			input:   "1\n00:00:01,000 --> 00:61:00,000\nHello\n",
+			wantErr: `line 2: invalid timestamp "00:61:00,000"`,
+		},
+		{
+			name:    "ends before it starts",
+			input:   "WEBVTT\n\n00:02.000 --> 00:01.000\nHello\n",
+			wantErr: "line 3: cue ends at 00:00:01.000, before it starts at 00:00:02.000",
+		},
+		{
+			name:    "arrow in text",
+			input:   "WEBVTT\n\n00:01.000 --> 00:02.000\na --> b\n",
+			wantErr: `line 3: cue text must not contain "-->"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteVTT(t *testing.T) {
+	cues := []Cue{
+		{ID: "1", Start: ms(1000), End: ms(2500), Text: "Hello"},
+		{Start: time.Hour + ms(61_001), End: 2 * time.Hour, Settings: "line:0", Text: "Two\nlines"},
+	}
+	want := "WEBVTT\n\n" +
+		"1\n00:00:01.000 --> 00:00:02.500\nHello\n\n" +
+		"01:01:01.001 --> 02:00:00.000 line:0\nTwo\nlines\n"
+
+	var b strings.Builder
+	err := WriteVTT(&b, cues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+
+	// What WriteVTT writes parses back to the same cues.
+	got, err := ParseVTT(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, cues) {
+		t.Errorf("round trip: got %+v, want %+v", got, cues)
+	}
+	if d := Duration(cues); d != 2*time.Hour {
+		t.Errorf("Duration = %s, want 2h", d)
+	}
+}
+
+func TestParseErrNoCues(t *testing.T) {
+	_, err := Parse([]byte("WEBVTT\n\nNOTE nothing here\n"))
+	if !errors.Is(err, ErrNoCues) {
+		t.Errorf("got %v, want ErrNoCues", err)
+	}
+}
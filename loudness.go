@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// EBU R128 targets used by the loudness normalization step and the default
+// range of the loudness report.
+const (
+	loudnessTargetLUFS     = -23.0
+	loudnessTruePeakDBTP   = -1.0
+	loudnessRangeLU        = 7.0
+	loudnessToleranceLU    = 1.0
+	normalizedAudioBitrate = "192k"
+)
+
+// The loudness report only accepts targets loudnorm could normalize to; -70
+// LUFS is the silence gate of EBU R128 and nothing is louder than full scale.
+const (
+	minLoudnessTargetLUFS = -70.0
+	maxLoudnessTargetLUFS = 0.0
+)
+
+type loudnormStats struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	OutputI      string `json:"output_i"`
+	TargetOffset string `json:"target_offset"`
+}
+
+func loudnormTargets() string {
+	return fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=%.1f", loudnessTargetLUFS, loudnessTruePeakDBTP, loudnessRangeLU)
+}
+
+// hasAudioStream reports whether ffprobe finds at least one audio stream.
+func hasAudioStream(filePath string) (bool, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "a", "-print_format", "json", "-show_streams", filePath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to run ffprobe: %w\n%s", err, string(output))
+	}
+
+	var ffprobeOutput FFProbeOutput
+	err = json.Unmarshal(output, &ffprobeOutput)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	return len(ffprobeOutput.Streams) > 0, nil
+}
+
+// measureLoudness runs the first loudnorm pass over the audio. It returns nil
+// stats for videos without sound.
+func measureLoudness(filePath string) (*loudnormStats, error) {
+	hasAudio, err := hasAudioStream(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAudio {
+		return nil, nil
+	}
+
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-i", filePath, "-vn",
+		"-af", loudnormTargets()+":print_format=json", "-f", "null", "-")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ffmpeg: %w\n%s", err, string(output))
+	}
+
+	stats, err := parseLoudnormOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// normalizeLoudness runs the second loudnorm pass using the first pass's
+// measurements and returns the path of the normalized video. The video
+// stream is copied untouched.
+func normalizeLoudness(filePath string, measured loudnormStats) (string, loudnormStats, error) {
+	outputPath := fmt.Sprintf("%s.loudnorm", filePath)
+	filter := fmt.Sprintf(
+		"%s:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true:print_format=json",
+		loudnormTargets(), measured.InputI, measured.InputTP, measured.InputLRA, measured.InputThresh, measured.TargetOffset,
+	)
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-i", filePath,
+		"-af", filter,
+		"-c:v", "copy", "-c:a", "aac", "-b:a", normalizedAudioBitrate,
+		"-movflags", "faststart", "-f", "mp4", outputPath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		// ffmpeg may have written part of the output before failing.
+		os.Remove(outputPath)
+		return "", loudnormStats{}, fmt.Errorf("failed to run ffmpeg: %w\n%s", err, string(output))
+	}
+
+	stats, err := parseLoudnormOutput(output)
+	if err != nil {
+		os.Remove(outputPath)
+		return "", loudnormStats{}, err
+	}
+	return outputPath, stats, nil
+}
+
+// parseLoudnormOutput extracts the JSON block loudnorm prints at the end of
+// ffmpeg's log output.
+func parseLoudnormOutput(output []byte) (loudnormStats, error) {
+	start := bytes.LastIndexByte(output, '{')
+	end := bytes.LastIndexByte(output, '}')
+	if start == -1 || end < start {
+		return loudnormStats{}, errors.New("no loudnorm statistics in ffmpeg output")
+	}
+
+	var stats loudnormStats
+	err := json.Unmarshal(output[start:end+1], &stats)
+	if err != nil {
+		return loudnormStats{}, fmt.Errorf("failed to parse loudnorm statistics: %w", err)
+	}
+	return stats, nil
+}
+
+// parseLUFS converts a loudnorm value to a number. Silence is reported as
+// "-inf", which has no useful integrated loudness and yields nil.
+func parseLUFS(s string) (*float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loudness value %q: %w", s, err)
+	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return nil, nil
+	}
+	return &v, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseLoudnormOutput(t *testing.T) {
+	const stats = `{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.06",
+	"input_thresh" : "-39.20",
+	"output_i" : "-22.98",
+	"output_tp" : "-1.00",
+	"output_lra" : "6.80",
+	"output_thresh" : "-33.20",
+	"normalization_type" : "dynamic",
+	"target_offset" : "-0.02"
+}
+`
+	want := loudnormStats{
+		InputI:       "-27.61",
+		InputTP:      "-4.47",
+		InputLRA:     "18.06",
+		InputThresh:  "-39.20",
+		OutputI:      "-22.98",
+		TargetOffset: "-0.02",
+	}
+
+	tests := []struct {
+		name    string
+		output  string
+		want    loudnormStats
+		wantErr bool
+	}{
+		{
+			name:   "statistics only",
+			output: stats,
+			want:   want,
+		},
+		{
+			name: "after the ffmpeg log",
+			output: "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':\n" +
+				"  Metadata: {major_brand: isom}\n" +
+				"[Parsed_loudnorm_0 @ 0x5581] \n" + stats,
+			want: want,
+		},
+		{
+			name:    "no statistics",
+			output:  "Output file is empty, nothing was encoded\n",
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			output:  "[Parsed_loudnorm_0 @ 0x5581] \n{\n\t\"input_i\" : \"-27.61\",\n",
+			wantErr: true,
+		},
+		{
+			name:    "not JSON",
+			output:  "{input_i: -27.61}",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLoudnormOutput([]byte(tt.output))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLUFS(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    *float64
+		wantErr bool
+	}{
+		{input: "-23.00", want: ptr(-23.0)},
+		{input: "0", want: ptr(0.0)},
+		{input: "-inf", want: nil},
+		{input: "inf", want: nil},
+		{input: "nan", want: nil},
+		{input: "", wantErr: true},
+		{input: "loud", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseLUFS(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLoudnessReportParams(t *testing.T) {
+	tests := []struct {
+		query         string
+		wantTarget    float64
+		wantTolerance float64
+		wantErr       bool
+	}{
+		{query: "", wantTarget: loudnessTargetLUFS, wantTolerance: loudnessToleranceLU},
+		{query: "target=-16&tolerance=2", wantTarget: -16, wantTolerance: 2},
+		{query: "target=-70&tolerance=0", wantTarget: -70, wantTolerance: 0},
+		{query: "target=0", wantTarget: 0, wantTolerance: loudnessToleranceLU},
+		{query: "target=-71", wantErr: true},
+		{query: "target=3", wantErr: true},
+		{query: "target=NaN", wantErr: true},
+		{query: "target=-Inf", wantErr: true},
+		{query: "target=1e308", wantErr: true},
+		{query: "target=loud", wantErr: true},
+		{query: "tolerance=-1", wantErr: true},
+		{query: "tolerance=NaN", wantErr: true},
+		{query: "tolerance=Inf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target, tolerance, err := parseLoudnessReportParams(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (target != tt.wantTarget || tolerance != tt.wantTolerance) {
+				t.Errorf("got target %v and tolerance %v, want %v and %v", target, tolerance, tt.wantTarget, tt.wantTolerance)
+			}
+		})
+	}
+}
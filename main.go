@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
 
@@ -11,11 +12,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"context"
-	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	db               database.Client
+	db               database.Store
 	jwtSecret        string
 	platform         string
 	filepathRoot     string
@@ -25,17 +25,28 @@ type apiConfig struct {
 	s3Region         string
 	s3CfDistribution string
 	port             string
+	watermark        watermarkConfig
+	trashRetention   time.Duration
+	versionsKept     int
 }
 
 func main() {
 	godotenv.Load(".env")
 
-	pathToDB := os.Getenv("DB_PATH")
-	if pathToDB == "" {
-		log.Fatal("DB_URL must be set")
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	db, err := database.NewClient(pathToDB)
+	databaseURL, err := getDatabaseURL()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := database.NewClient(databaseURL)
 	if err != nil {
 		log.Fatalf("Couldn't connect to database: %v", err)
 	}
@@ -80,6 +91,21 @@ func main() {
 		log.Fatal("PORT environment variable is not set")
 	}
 
+	watermark, err := loadWatermarkConfig()
+	if err != nil {
+		log.Fatalf("Couldn't load watermark config: %v", err)
+	}
+
+	trashRetention, err := loadTrashRetention()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	versionsKept, err := loadVideoVersionsKept()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	awsConfig, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(s3Region))
 	if err != nil {
 		log.Fatalf("Couldn't load AWS config: %v", err)
@@ -99,6 +125,9 @@ func main() {
 		s3Region:         s3Region,
 		s3CfDistribution: s3CfDistribution,
 		port:             port,
+		watermark:        watermark,
+		trashRetention:   trashRetention,
+		versionsKept:     versionsKept,
 	}
 
 	err = cfg.ensureAssetsDir()
@@ -106,6 +135,20 @@ func main() {
 		log.Fatalf("Couldn't create assets directory: %v", err)
 	}
 
+	go cfg.runTrashPurger(context.Background())
+
+	// Export jobs run in the server process, so any left unfinished died
+	// with the previous one.
+	failed, err := db.FailUnfinishedExports("Interrupted by a server restart; please try again")
+	if err != nil {
+		log.Fatalf("Couldn't fail unfinished exports: %v", err)
+	}
+	if failed > 0 {
+		log.Printf("Marked %d unfinished exports as failed", failed)
+	}
+	go cfg.runExportCleaner(context.Background())
+	go cfg.runRefreshTokenCleaner(context.Background())
+
 	mux := http.NewServeMux()
 	appHandler := http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))
 	mux.Handle("/app/", appHandler)
@@ -116,17 +159,69 @@ func main() {
 	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
 	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
+	mux.HandleFunc("GET /api/sessions", cfg.handlerSessionsList)
+	mux.HandleFunc("POST /api/sessions/revoke_others", cfg.handlerSessionsRevokeOthers)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.handlerSessionRevoke)
 
 	mux.HandleFunc("POST /api/users", cfg.handlerUsersCreate)
+	mux.HandleFunc("GET /api/users/me/watermark", cfg.handlerWatermarkGet)
+	mux.HandleFunc("PUT /api/users/me/watermark", cfg.handlerWatermarkUpdate)
+	mux.HandleFunc("POST /api/users/me/watermark/image", cfg.handlerWatermarkImageUpload)
+	mux.HandleFunc("GET /api/users/me/audit_events", cfg.handlerAuditEventsMine)
+	mux.HandleFunc("POST /api/users/me/export", cfg.handlerExportCreate)
+	mux.HandleFunc("GET /api/users/me/exports", cfg.handlerExportsList)
+	mux.HandleFunc("GET /api/users/me/exports/{exportID}", cfg.handlerExportGet)
+	mux.HandleFunc("POST /api/users/me/api_keys", cfg.handlerAPIKeyCreate)
+	mux.HandleFunc("GET /api/users/me/api_keys", cfg.handlerAPIKeysList)
+	mux.HandleFunc("DELETE /api/users/me/api_keys/{keyID}", cfg.handlerAPIKeyRevoke)
 
 	mux.HandleFunc("POST /api/videos", cfg.handlerVideoMetaCreate)
 	mux.HandleFunc("POST /api/thumbnail_upload/{videoID}", cfg.handlerUploadThumbnail)
 	mux.HandleFunc("POST /api/video_upload/{videoID}", cfg.handlerUploadVideo)
 	mux.HandleFunc("GET /api/videos", cfg.handlerVideosRetrieve)
+	mux.HandleFunc("GET /api/videos/loudness_report", cfg.handlerLoudnessReport)
+	mux.HandleFunc("GET /api/videos/search", cfg.handlerVideoSearch)
 	mux.HandleFunc("GET /api/videos/{videoID}", cfg.handlerVideoGet)
+	mux.HandleFunc("PATCH /api/videos/{videoID}", cfg.handlerVideoPatch)
 	mux.HandleFunc("DELETE /api/videos/{videoID}", cfg.handlerVideoMetaDelete)
 
+	mux.HandleFunc("POST /api/videos/{videoID}/clips", cfg.handlerClipCreate)
+
+	mux.HandleFunc("GET /api/videos/{videoID}/versions", cfg.handlerVideoVersionsList)
+	mux.HandleFunc("POST /api/videos/{videoID}/versions/{versionID}/activate", cfg.handlerVideoVersionActivate)
+	mux.HandleFunc("GET /api/videos/{videoID}/versions/{versionID}/download", cfg.handlerVideoVersionDownload)
+
+	mux.HandleFunc("POST /api/videos/{videoID}/subtitles", cfg.handlerSubtitleUpload)
+	mux.HandleFunc("GET /api/videos/{videoID}/subtitles", cfg.handlerSubtitlesList)
+	mux.HandleFunc("DELETE /api/videos/{videoID}/subtitles/{trackID}", cfg.handlerSubtitleDelete)
+
+	mux.HandleFunc("POST /api/videos/{videoID}/tags", cfg.handlerVideoTagsAdd)
+	mux.HandleFunc("DELETE /api/videos/{videoID}/tags/{tag}", cfg.handlerVideoTagDelete)
+	mux.HandleFunc("GET /api/tags", cfg.handlerTagSuggestions)
+
+	mux.HandleFunc("GET /api/catalog", cfg.handlerCatalog)
+
+	mux.HandleFunc("GET /api/trash", cfg.handlerTrashList)
+	mux.HandleFunc("POST /api/trash/{videoID}/restore", cfg.handlerTrashRestore)
+	mux.HandleFunc("DELETE /api/trash/{videoID}", cfg.handlerTrashPurge)
+
+	mux.HandleFunc("POST /api/playlists", cfg.handlerPlaylistCreate)
+	mux.HandleFunc("GET /api/playlists", cfg.handlerPlaylistsList)
+	mux.HandleFunc("GET /api/playlists/{playlistID}", cfg.handlerPlaylistGet)
+	mux.HandleFunc("PUT /api/playlists/{playlistID}", cfg.handlerPlaylistUpdate)
+	mux.HandleFunc("DELETE /api/playlists/{playlistID}", cfg.handlerPlaylistDelete)
+	mux.HandleFunc("POST /api/playlists/{playlistID}/items", cfg.handlerPlaylistItemAdd)
+	mux.HandleFunc("PUT /api/playlists/{playlistID}/items", cfg.handlerPlaylistReorder)
+	mux.HandleFunc("DELETE /api/playlists/{playlistID}/items/{itemID}", cfg.handlerPlaylistItemDelete)
+	mux.HandleFunc("POST /api/playlists/{playlistID}/items/{itemID}/move", cfg.handlerPlaylistItemMove)
+	mux.HandleFunc("GET /api/playlists/{playlistID}/playlist.m3u", cfg.handlerPlaylistM3U)
+	mux.HandleFunc("GET /api/playlists/{playlistID}/feed.json", cfg.handlerPlaylistJSONFeed)
+
 	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
+	mux.HandleFunc("GET /admin/audit_events", cfg.requireRole(database.RoleAdmin, cfg.handlerAuditEventsAll))
+	mux.HandleFunc("GET /admin/users", cfg.requireRole(database.RoleAdmin, cfg.handlerAdminUsersList))
+	mux.HandleFunc("PATCH /admin/users/{userID}", cfg.requireRole(database.RoleAdmin, cfg.handlerAdminUserUpdate))
+	mux.HandleFunc("GET /admin/videos/{videoID}", cfg.requireRole(database.RoleModerator, cfg.handlerAdminVideoGet))
 
 	srv := &http.Server{
 		Addr:    ":" + port,
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// newTestConfig returns an apiConfig backed by a scratch SQLite database.
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	db, err := database.NewClient(filepath.Join(t.TempDir(), "tubely.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &apiConfig{
+		db:        db,
+		jwtSecret: "test-secret",
+		platform:  "dev",
+	}
+}
+
+// testUser creates a user.
+func testUser(t *testing.T, cfg *apiConfig, email string) *database.User {
+	t.Helper()
+	user, err := cfg.db.CreateUser(database.CreateUserParams{Email: email, Password: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// testLogin starts a session for user, as handlerLogin would, and returns
+// the session and its access token.
+func testLogin(t *testing.T, cfg *apiConfig, user *database.User) (database.RefreshToken, string) {
+	t.Helper()
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := cfg.db.CreateRefreshToken(database.CreateRefreshTokenParams{
+		UserID:     user.ID,
+		Token:      refreshToken,
+		ExpiresAt:  time.Now().UTC().Add(refreshTokenLifetime),
+		DeviceName: fmt.Sprintf("device %s", refreshToken[:8]),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err := auth.MakeAccessJWT(
+		auth.Identity{UserID: user.ID, SessionID: session.FamilyID, Role: user.Role},
+		cfg.jwtSecret,
+		time.Hour,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session, accessToken
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// authorizedRequest returns a request carrying an access token.
+func authorizedRequest(method, target, accessToken string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return req
+}
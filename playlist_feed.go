@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// m3uContentType is the media type of the plain M3U files playlistM3U
+// writes. They aren't HLS playlists, so they don't use HLS's type.
+const m3uContentType = "audio/x-mpegurl"
+
+// playlistM3U renders a playlist as an extended M3U file, which players
+// such as VLC and mpv open as a queue. Items without uploaded media are
+// skipped.
+func playlistM3U(playlist database.Playlist) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#PLAYLIST:%s\n", m3uText(playlist.Title))
+	for _, item := range playlist.Items {
+		if item.Video.VideoURL == nil {
+			continue
+		}
+		duration := -1
+		if item.Video.DurationSeconds != nil {
+			duration = int(*item.Video.DurationSeconds + 0.5)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n%s\n", duration, m3uText(item.Video.Title), *item.Video.VideoURL)
+	}
+	return b.String()
+}
+
+// withoutPrivateVideos is a playlist as someone other than its owner sees
+// it: private videos are left out, and the cover comes from the first video
+// that is left.
+func withoutPrivateVideos(playlist database.Playlist) database.Playlist {
+	items := []database.PlaylistItem{}
+	for _, item := range playlist.Items {
+		if item.Video.Visibility != database.VisibilityPrivate {
+			items = append(items, item)
+		}
+	}
+	playlist.Items = items
+	playlist.ItemCount = len(items)
+	playlist.CoverURL = nil
+	if len(items) > 0 {
+		playlist.CoverURL = items[0].Video.ThumbnailURL
+	}
+	return playlist
+}
+
+// m3uText keeps a title on a single line.
+func m3uText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Icon        *string        `json:"icon,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	Title         string               `json:"title"`
+	ContentText   string               `json:"content_text"`
+	Image         *string              `json:"image,omitempty"`
+	DatePublished string               `json:"date_published"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL               string   `json:"url"`
+	MimeType          string   `json:"mime_type"`
+	DurationInSeconds *float64 `json:"duration_in_seconds,omitempty"`
+}
+
+// playlistJSONFeed renders a playlist as a JSON Feed 1.1 document
+// (https://www.jsonfeed.org/version/1.1/), items in playlist order.
+func playlistJSONFeed(playlist database.Playlist) jsonFeed {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       playlist.Title,
+		Description: playlist.Description,
+		Icon:        playlist.CoverURL,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range playlist.Items {
+		feedItem := jsonFeedItem{
+			ID:            item.Video.ID.String(),
+			Title:         item.Video.Title,
+			ContentText:   item.Video.Description,
+			Image:         item.Video.ThumbnailURL,
+			DatePublished: item.Video.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+			Tags:          item.Video.Tags,
+		}
+		if item.Video.VideoURL != nil {
+			mimeType := "video/mp4"
+			if strings.HasSuffix(*item.Video.VideoURL, ".m3u8") {
+				mimeType = hlsContentType
+			}
+			feedItem.Attachments = []jsonFeedAttachment{{
+				URL:               *item.Video.VideoURL,
+				MimeType:          mimeType,
+				DurationInSeconds: item.Video.DurationSeconds,
+			}}
+		}
+		feed.Items = append(feed.Items, feedItem)
+	}
+	return feed
+}
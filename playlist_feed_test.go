@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func feedVideo(title, visibility string, videoURL, thumbnailURL *string, duration *float64) database.Video {
+	return database.Video{
+		ID:              uuid.New(),
+		CreatedAt:       time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		VideoURL:        videoURL,
+		ThumbnailURL:    thumbnailURL,
+		DurationSeconds: duration,
+		CreateVideoParams: database.CreateVideoParams{
+			Title:      title,
+			Visibility: visibility,
+		},
+	}
+}
+
+func feedPlaylistOf(videos ...database.Video) database.Playlist {
+	playlist := database.Playlist{
+		CreatePlaylistParams: database.CreatePlaylistParams{Title: "Road\ntrip  mix", Description: "Songs"},
+		ItemCount:            len(videos),
+	}
+	for i, video := range videos {
+		playlist.Items = append(playlist.Items, database.PlaylistItem{ID: uuid.New(), Position: i, Video: video})
+	}
+	if len(videos) > 0 {
+		playlist.CoverURL = videos[0].ThumbnailURL
+	}
+	return playlist
+}
+
+func TestPlaylistM3U(t *testing.T) {
+	playlist := feedPlaylistOf(
+		feedVideo("First\nsong", database.VisibilityPublic, ptr("https://cdn.example.com/1.mp4"), nil, ptr(61.6)),
+		feedVideo("Draft", database.VisibilityPublic, nil, nil, nil),
+		feedVideo("Unknown length", database.VisibilityUnlisted, ptr("https://cdn.example.com/2.m3u8"), nil, nil),
+	)
+	want := "#EXTM3U\n" +
+		"#PLAYLIST:Road trip mix\n" +
+		"#EXTINF:62,First song\nhttps://cdn.example.com/1.mp4\n" +
+		"#EXTINF:-1,Unknown length\nhttps://cdn.example.com/2.m3u8\n"
+	if got := playlistM3U(playlist); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWithoutPrivateVideos(t *testing.T) {
+	tests := []struct {
+		name      string
+		playlist  database.Playlist
+		wantItems []string
+		wantCover *string
+	}{
+		{
+			name: "private cover",
+			playlist: feedPlaylistOf(
+				feedVideo("private", database.VisibilityPrivate, nil, ptr("private.png"), nil),
+				feedVideo("unlisted", database.VisibilityUnlisted, nil, ptr("unlisted.png"), nil),
+				feedVideo("public", database.VisibilityPublic, nil, nil, nil),
+			),
+			wantItems: []string{"unlisted", "public"},
+			wantCover: ptr("unlisted.png"),
+		},
+		{
+			name: "first visible video without thumbnail",
+			playlist: feedPlaylistOf(
+				feedVideo("public", database.VisibilityPublic, nil, nil, nil),
+				feedVideo("unlisted", database.VisibilityUnlisted, nil, ptr("unlisted.png"), nil),
+			),
+			wantItems: []string{"public", "unlisted"},
+		},
+		{
+			name: "only private videos",
+			playlist: feedPlaylistOf(
+				feedVideo("private", database.VisibilityPrivate, nil, ptr("private.png"), nil),
+			),
+			wantItems: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withoutPrivateVideos(tt.playlist)
+			titles := []string{}
+			for _, item := range got.Items {
+				titles = append(titles, item.Video.Title)
+			}
+			if strings.Join(titles, ",") != strings.Join(tt.wantItems, ",") {
+				t.Errorf("got items %q, want %q", titles, tt.wantItems)
+			}
+			if got.ItemCount != len(tt.wantItems) {
+				t.Errorf("got ItemCount %d, want %d", got.ItemCount, len(tt.wantItems))
+			}
+			if (got.CoverURL == nil) != (tt.wantCover == nil) || got.CoverURL != nil && *got.CoverURL != *tt.wantCover {
+				t.Errorf("got cover %v, want %v", got.CoverURL, tt.wantCover)
+			}
+		})
+	}
+}
+
+func TestPlaylistJSONFeed(t *testing.T) {
+	mp4 := feedVideo("MP4", database.VisibilityPublic, ptr("https://cdn.example.com/1.mp4"), ptr("1.png"), ptr(12.5))
+	mp4.Tags = []string{"road"}
+	hls := feedVideo("HLS", database.VisibilityPublic, ptr("https://cdn.example.com/2.m3u8"), nil, nil)
+	draft := feedVideo("Draft", database.VisibilityPublic, nil, nil, nil)
+
+	feed := playlistJSONFeed(feedPlaylistOf(mp4, hls, draft))
+	if feed.Version != "https://jsonfeed.org/version/1.1" || feed.Title != "Road\ntrip  mix" || feed.Icon == nil || *feed.Icon != "1.png" {
+		t.Errorf("got feed %+v", feed)
+	}
+	if len(feed.Items) != 3 {
+		t.Fatalf("got %d items, want 3", len(feed.Items))
+	}
+
+	first := feed.Items[0]
+	if first.ID != mp4.ID.String() || first.DatePublished != "2024-03-01T12:00:00Z" || len(first.Tags) != 1 {
+		t.Errorf("got first item %+v", first)
+	}
+	if len(first.Attachments) != 1 || first.Attachments[0].MimeType != "video/mp4" || *first.Attachments[0].DurationInSeconds != 12.5 {
+		t.Errorf("got first attachments %+v", first.Attachments)
+	}
+	if attachments := feed.Items[1].Attachments; len(attachments) != 1 || attachments[0].MimeType != hlsContentType {
+		t.Errorf("got HLS attachments %+v", attachments)
+	}
+	if attachments := feed.Items[2].Attachments; len(attachments) != 0 {
+		t.Errorf("got attachments %+v for a video without media", attachments)
+	}
+}
+
+func TestNormalizePlaylistTitle(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "  Road trip ", want: "Road trip"},
+		{input: strings.Repeat("é", maxPlaylistTitleLength), want: strings.Repeat("é", maxPlaylistTitleLength)},
+		{input: " ", wantErr: true},
+		{input: strings.Repeat("a", maxPlaylistTitleLength+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizePlaylistTitle(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizePlaylistTitle(%q) = %q, %v", tt.input, got, err)
+		}
+	}
+}
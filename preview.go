@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	previewWidth       = 320
+	previewFPS         = 10
+	previewSamples     = 5
+	previewSampleLen   = time.Second
+	maxPreviewDuration = 10 * time.Second
+)
+
+// previewOptions selects what goes into the animated preview. By default a
+// short sample is taken from evenly spaced points across the video; with
+// segment set, a single continuous stretch starting at start is used.
+type previewOptions struct {
+	segment  bool
+	start    time.Duration
+	duration time.Duration
+}
+
+// parsePreviewOptions reads the optional preview_start and preview_duration
+// form values of a video upload.
+func parsePreviewOptions(startValue, durationValue string) (previewOptions, error) {
+	if startValue == "" && durationValue == "" {
+		return previewOptions{}, nil
+	}
+
+	opts := previewOptions{segment: true, duration: 3 * time.Second}
+	if startValue != "" {
+		start, err := parseClipTimestamp(startValue)
+		if err != nil {
+			return previewOptions{}, fmt.Errorf("invalid preview_start: %w", err)
+		}
+		opts.start = start
+	}
+	if durationValue != "" {
+		duration, err := parseClipTimestamp(durationValue)
+		if err != nil {
+			return previewOptions{}, fmt.Errorf("invalid preview_duration: %w", err)
+		}
+		opts.duration = duration
+	}
+	if opts.duration <= 0 || opts.duration > maxPreviewDuration {
+		return previewOptions{}, fmt.Errorf("preview_duration must be between 0 and %s", maxPreviewDuration)
+	}
+	return opts, nil
+}
+
+// previewFilter builds the ffmpeg video filter and input seek arguments for
+// the preview.
+func previewFilter(videoDuration time.Duration, opts previewOptions) ([]string, string) {
+	scale := fmt.Sprintf("fps=%d,scale=%d:-2:flags=lanczos", previewFPS, previewWidth)
+
+	if opts.segment {
+		return []string{"-ss", formatFFmpegDuration(opts.start), "-t", formatFFmpegDuration(opts.duration)}, scale
+	}
+
+	interval := videoDuration / previewSamples
+	if interval <= previewSampleLen {
+		// Too short to sample, use the beginning of the video as is.
+		return []string{"-t", formatFFmpegDuration(previewSamples * previewSampleLen)}, scale
+	}
+	sample := fmt.Sprintf(
+		"select='lt(mod(t,%s),%s)',setpts=N/FRAME_RATE/TB",
+		strconv.FormatFloat(interval.Seconds(), 'f', 3, 64),
+		strconv.FormatFloat(previewSampleLen.Seconds(), 'f', 3, 64),
+	)
+	return nil, sample + "," + scale
+}
+
+// generatePreviews writes a silent animated WebP and a muted MP4 preview of
+// the video next to it and returns their paths.
+func generatePreviews(filePath string, opts previewOptions) (string, string, error) {
+	duration, err := getVideoDuration(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	if opts.segment && opts.start >= duration {
+		return "", "", fmt.Errorf("preview starts at %s but the video is only %s long", opts.start, duration)
+	}
+
+	seek, filter := previewFilter(duration, opts)
+
+	webpPath := fmt.Sprintf("%s.preview.webp", filePath)
+	args := append(append([]string{"-y"}, seek...), "-i", filePath, "-vf", filter, "-an",
+		"-c:v", "libwebp", "-loop", "0", "-q:v", "60", "-f", "webp", webpPath)
+	if err := runFFmpeg(args...); err != nil {
+		return "", "", err
+	}
+
+	mp4Path := fmt.Sprintf("%s.preview.mp4", filePath)
+	args = append(append([]string{"-y"}, seek...), "-i", filePath, "-vf", filter, "-an",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "28", "-pix_fmt", "yuv420p",
+		"-movflags", "faststart", "-f", "mp4", mp4Path)
+	if err := runFFmpeg(args...); err != nil {
+		os.Remove(webpPath)
+		return "", "", err
+	}
+
+	return webpPath, mp4Path, nil
+}
+
+func runFFmpeg(args ...string) error {
+	cmd := exec.Command("ffmpeg", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to run ffmpeg: %w\n%s", err, string(output))
+	}
+	return nil
+}
+
+// uploadPreviews generates the previews for a processed video and stores
+// them, returning the WebP and MP4 URLs.
+func (cfg *apiConfig) uploadPreviews(ctx context.Context, filePath string, opts previewOptions) (string, string, error) {
+	webpPath, mp4Path, err := generatePreviews(filePath, opts)
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(webpPath)
+	defer os.Remove(mp4Path)
+
+	fileName, err := randomAssetName()
+	if err != nil {
+		return "", "", err
+	}
+
+	urls := []string{}
+	// The previews are only used as a pair, so one that was stored without
+	// the other is deleted again.
+	fail := func(err error) (string, string, error) {
+		for _, url := range urls {
+			cfg.deleteObjectsAt(context.WithoutCancel(ctx), &url)
+		}
+		return "", "", err
+	}
+	for _, p := range []struct {
+		path, ext, contentType string
+	}{
+		{webpPath, "webp", "image/webp"},
+		{mp4Path, "mp4", "video/mp4"},
+	} {
+		f, err := os.Open(p.path)
+		if err != nil {
+			return fail(err)
+		}
+		key := fmt.Sprintf("previews/%s.%s", fileName, p.ext)
+		err = cfg.putObject(ctx, key, f, p.contentType)
+		f.Close()
+		if err != nil {
+			return fail(err)
+		}
+		urls = append(urls, cfg.objectURL(key))
+	}
+
+	return urls[0], urls[1], nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParsePreviewOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		duration string
+		want     previewOptions
+		wantErr  bool
+	}{
+		{name: "sampled", want: previewOptions{}},
+		{
+			name:  "start only",
+			start: "1:30",
+			want:  previewOptions{segment: true, start: 90 * time.Second, duration: 3 * time.Second},
+		},
+		{
+			name:     "duration only",
+			duration: "2.5",
+			want:     previewOptions{segment: true, duration: 2500 * time.Millisecond},
+		},
+		{
+			name:     "longest duration",
+			start:    "5",
+			duration: "10",
+			want:     previewOptions{segment: true, start: 5 * time.Second, duration: 10 * time.Second},
+		},
+		{name: "invalid start", start: "soon", wantErr: true},
+		{name: "invalid duration", duration: "-1", wantErr: true},
+		{name: "zero duration", duration: "0", wantErr: true},
+		{name: "duration too long", duration: "10.001", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePreviewOptions(tt.start, tt.duration)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewFilter(t *testing.T) {
+	const scale = "fps=10,scale=320:-2:flags=lanczos"
+
+	tests := []struct {
+		name       string
+		duration   time.Duration
+		opts       previewOptions
+		wantSeek   []string
+		wantFilter string
+	}{
+		{
+			name:       "sampled",
+			duration:   time.Minute,
+			wantFilter: "select='lt(mod(t,12.000),1.000)',setpts=N/FRAME_RATE/TB," + scale,
+		},
+		{
+			name:       "too short to sample",
+			duration:   5 * time.Second,
+			wantSeek:   []string{"-t", "5.000"},
+			wantFilter: scale,
+		},
+		{
+			name:       "segment",
+			duration:   time.Minute,
+			opts:       previewOptions{segment: true, start: 1500 * time.Millisecond, duration: 3 * time.Second},
+			wantSeek:   []string{"-ss", "1.500", "-t", "3.000"},
+			wantFilter: scale,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seek, filter := previewFilter(tt.duration, tt.opts)
+			if !slices.Equal(seek, tt.wantSeek) {
+				t.Errorf("got seek %q, want %q", seek, tt.wantSeek)
+			}
+			if filter != tt.wantFilter {
+				t.Errorf("got filter %q, want %q", filter, tt.wantFilter)
+			}
+		})
+	}
+}
@@ -1,7 +1,13 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
 
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// handlerReset deletes all data except the audit log, which records the
+// reset.
 func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
 		w.WriteHeader(http.StatusForbidden)
@@ -14,6 +20,10 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't reset database", err)
 		return
 	}
+	cfg.audit(r, database.CreateAuditEventParams{
+		Action:  auditDatabaseReset,
+		Outcome: database.AuditOutcomeSuccess,
+	})
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Database reset to initial state"))
 }
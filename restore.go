@@ -0,0 +1,305 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// runRestore unpacks a backup next to the live database and assets,
+// verifies every checksum, and only then swaps the restored copies in. The
+// files it replaces are kept with a .pre-restore suffix.
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	checkObjects := flags.Bool("check-objects", false, "fail if objects listed in the backup are missing from the bucket")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+	archivePath := flags.Arg(0)
+
+	databaseURL, err := getDatabaseURL()
+	if err != nil {
+		return err
+	}
+	dbPath, err := database.SQLitePath(databaseURL)
+	if err != nil {
+		return err
+	}
+	assetsRoot := os.Getenv("ASSETS_ROOT")
+	if assetsRoot == "" {
+		return errors.New("ASSETS_ROOT environment variable is not set")
+	}
+	assetsRoot = filepath.Clean(assetsRoot)
+
+	// Staging next to the targets keeps the final swap a rename on the same
+	// filesystem.
+	dbStage, err := os.MkdirTemp(filepath.Dir(dbPath), ".tubely-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dbStage)
+	assetsStage, err := os.MkdirTemp(filepath.Dir(assetsRoot), ".tubely-restore-assets-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(assetsStage)
+
+	manifest, err := extractBackup(archivePath, dbStage, assetsStage)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("verified %d files from backup taken %s\n", len(manifest.Files), manifest.CreatedAt.Format(time.RFC3339))
+
+	stagedDB := filepath.Join(dbStage, backupDatabaseName)
+	db, err := database.Open(stagedDB)
+	if err != nil {
+		return fmt.Errorf("restored database is unusable: %w", err)
+	}
+	_, err = db.MigrationStatus()
+	db.Close()
+	if err != nil {
+		return fmt.Errorf("restored database is unusable: %w", err)
+	}
+
+	if *checkObjects {
+		err = checkBackupObjects(context.Background(), filepath.Join(dbStage, backupObjectsName))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Chmod(assetsStage, 0o755)
+	if err != nil {
+		return err
+	}
+	return swapInRestore(stagedDB, dbPath, assetsStage, assetsRoot)
+}
+
+// extractBackup unpacks an archive, the database and object listing into
+// dbStage and the assets into assetsStage, and checks them against the
+// manifest.
+func extractBackup(archivePath, dbStage, assetsStage string) (backupManifest, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return backupManifest{}, err
+	}
+	defer archive.Close()
+
+	zstd := exec.Command("zstd", "-d", "-q", "-c")
+	zstd.Stdin = archive
+	zstd.Stderr = os.Stderr
+	zstdOut, err := zstd.StdoutPipe()
+	if err != nil {
+		return backupManifest{}, err
+	}
+	err = zstd.Start()
+	if err != nil {
+		return backupManifest{}, fmt.Errorf("couldn't run zstd: %w", err)
+	}
+
+	manifest, extracted, err := readBackupEntries(tar.NewReader(zstdOut), dbStage, assetsStage)
+	// Drain so zstd doesn't block writing output nobody reads.
+	io.Copy(io.Discard, zstdOut)
+	if waitErr := zstd.Wait(); err == nil && waitErr != nil {
+		err = fmt.Errorf("zstd failed: %w", waitErr)
+	}
+	if err != nil {
+		return backupManifest{}, err
+	}
+
+	if manifest == nil {
+		return backupManifest{}, errors.New("backup has no manifest")
+	}
+	if manifest.FormatVersion != backupFormatVersion {
+		return backupManifest{}, fmt.Errorf("unsupported backup format version %d", manifest.FormatVersion)
+	}
+	for _, want := range manifest.Files {
+		got, ok := extracted[want.Path]
+		if !ok {
+			return backupManifest{}, fmt.Errorf("%s is listed in the manifest but missing from the backup", want.Path)
+		}
+		if got != want {
+			return backupManifest{}, fmt.Errorf("%s doesn't match its checksum", want.Path)
+		}
+		delete(extracted, want.Path)
+	}
+	for name := range extracted {
+		return backupManifest{}, fmt.Errorf("%s is in the backup but not in its manifest", name)
+	}
+	return *manifest, nil
+}
+
+func readBackupEntries(tr *tar.Reader, dbStage, assetsStage string) (*backupManifest, map[string]backupFile, error) {
+	var manifest *backupManifest
+	extracted := map[string]backupFile{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return manifest, extracted, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't read backup: %w", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("unexpected entry %s in backup", header.Name)
+		}
+
+		name := header.Name
+		if name != path.Clean(name) || path.IsAbs(name) || strings.HasPrefix(name, "../") {
+			return nil, nil, fmt.Errorf("unsafe path %s in backup", name)
+		}
+		if _, ok := extracted[name]; ok {
+			return nil, nil, fmt.Errorf("%s appears twice in the backup", name)
+		}
+
+		var dest string
+		switch {
+		case name == backupManifestName:
+			if manifest != nil {
+				return nil, nil, fmt.Errorf("%s appears twice in the backup", name)
+			}
+			manifest = &backupManifest{}
+			err = json.NewDecoder(tr).Decode(manifest)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+			continue
+		case name == backupDatabaseName || name == backupObjectsName:
+			dest = filepath.Join(dbStage, name)
+		case strings.HasPrefix(name, backupAssetsDir+"/"):
+			dest = filepath.Join(assetsStage, filepath.FromSlash(strings.TrimPrefix(name, backupAssetsDir+"/")))
+		default:
+			return nil, nil, fmt.Errorf("unexpected entry %s in backup", name)
+		}
+
+		file, err := extractBackupFile(tr, dest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't extract %s: %w", name, err)
+		}
+		file.Path = name
+		extracted[name] = file
+	}
+}
+
+func extractBackupFile(r io.Reader, dest string) (backupFile, error) {
+	err := os.MkdirAll(filepath.Dir(dest), 0o755)
+	if err != nil {
+		return backupFile{}, err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return backupFile{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), r)
+	if err != nil {
+		return backupFile{}, err
+	}
+	err = f.Sync()
+	if err != nil {
+		return backupFile{}, err
+	}
+	return backupFile{Size: n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// checkBackupObjects makes sure every object in a backup's listing still
+// exists in the bucket.
+func checkBackupObjects(ctx context.Context, listingPath string) error {
+	dat, err := os.ReadFile(listingPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("backup has no object listing; it was taken without -objects")
+	}
+	if err != nil {
+		return err
+	}
+	var objects []backupObject
+	err = json.Unmarshal(dat, &objects)
+	if err != nil {
+		return fmt.Errorf("invalid object listing: %w", err)
+	}
+
+	client, bucket, err := loadBucket(ctx)
+	if err != nil {
+		return err
+	}
+	missing := 0
+	for _, object := range objects {
+		_, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &bucket, Key: &object.Key})
+		if err != nil {
+			fmt.Printf("missing object %s: %v\n", object.Key, err)
+			missing++
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d of %d objects in the backup are missing from the bucket", missing, len(objects))
+	}
+	fmt.Printf("all %d objects are in the bucket\n", len(objects))
+	return nil
+}
+
+// swapInRestore moves the live database and assets aside and the restored
+// ones into place, putting the live ones back if anything fails.
+func swapInRestore(stagedDB, dbPath, stagedAssets, assetsRoot string) error {
+	suffix := ".pre-restore-" + time.Now().Format("20060102150405")
+
+	type move struct{ from, to string }
+	done := []move{}
+	rename := func(from, to string) error {
+		err := os.Rename(from, to)
+		if err != nil {
+			return err
+		}
+		done = append(done, move{from, to})
+		return nil
+	}
+	undo := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			os.Rename(done[i].to, done[i].from)
+		}
+	}
+
+	// A leftover WAL would be replayed into the restored database.
+	for _, p := range []string{dbPath, dbPath + "-wal", dbPath + "-shm", assetsRoot} {
+		if _, err := os.Lstat(p); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := rename(p, p+suffix); err != nil {
+			undo()
+			return fmt.Errorf("couldn't move %s aside: %w", p, err)
+		}
+		fmt.Printf("moved %s to %s\n", p, p+suffix)
+	}
+	if err := rename(stagedDB, dbPath); err != nil {
+		undo()
+		return fmt.Errorf("couldn't restore database: %w", err)
+	}
+	if err := rename(stagedAssets, assetsRoot); err != nil {
+		undo()
+		return fmt.Errorf("couldn't restore assets: %w", err)
+	}
+	fmt.Printf("restored %s and %s\n", dbPath, assetsRoot)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// roleRanks orders roles from least to most privileged. Each role can do
+// everything the ones below it can.
+var roleRanks = map[string]int{
+	database.RoleUser:      0,
+	database.RoleModerator: 1,
+	database.RoleAdmin:     2,
+}
+
+func validRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// hasRole reports whether role grants at least the privileges of minimum.
+func hasRole(role, minimum string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[minimum]
+}
+
+type contextKey int
+
+const requestUserKey contextKey = iota
+
+// requireRole lets through only requests from users with at least role.
+// The access token's role claim turns most callers away without a query.
+// The rest are checked against the database as well, since the tokens of a
+// user who was demoted or disabled keep their old claims until they expire.
+func (cfg *apiConfig) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+			return
+		}
+		identity, err := auth.ValidateAccessJWT(token, cfg.jwtSecret)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+			return
+		}
+		forbidden := fmt.Sprintf("Only users with the %s role can do this", role)
+		if !hasRole(identity.Role, role) {
+			respondWithError(w, http.StatusForbidden, forbidden, nil)
+			return
+		}
+		if !cfg.requireActiveSession(w, identity) {
+			return
+		}
+
+		user, err := cfg.db.GetUser(identity.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+			return
+		}
+		if user == nil || user.DisabledAt != nil || !hasRole(user.Role, role) {
+			respondWithError(w, http.StatusForbidden, forbidden, nil)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), requestUserKey, *user)))
+	}
+}
+
+// requestUser returns the user requireRole let through.
+func requestUser(r *http.Request) database.User {
+	user, _ := r.Context().Value(requestUserKey).(database.User)
+	return user
+}
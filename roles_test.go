@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role    string
+		minimum string
+		want    bool
+	}{
+		{role: database.RoleUser, minimum: database.RoleUser, want: true},
+		{role: database.RoleUser, minimum: database.RoleModerator, want: false},
+		{role: database.RoleModerator, minimum: database.RoleUser, want: true},
+		{role: database.RoleModerator, minimum: database.RoleAdmin, want: false},
+		{role: database.RoleAdmin, minimum: database.RoleModerator, want: true},
+		{role: database.RoleAdmin, minimum: database.RoleAdmin, want: true},
+		// Tokens from before roles existed have no role claim.
+		{role: "", minimum: database.RoleUser, want: false},
+		{role: "superuser", minimum: database.RoleUser, want: false},
+	}
+	for _, tt := range tests {
+		if got := hasRole(tt.role, tt.minimum); got != tt.want {
+			t.Errorf("hasRole(%q, %q) = %v, want %v", tt.role, tt.minimum, got, tt.want)
+		}
+		if got, want := validRole(tt.role), tt.role != "" && tt.role != "superuser"; got != want {
+			t.Errorf("validRole(%q) = %v, want %v", tt.role, got, want)
+		}
+	}
+}
+
+// withRole makes user's role role.
+func withRole(t *testing.T, cfg *apiConfig, user *database.User, role string) *database.User {
+	t.Helper()
+	err := cfg.db.SetUserRole(user.ID, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Role = role
+	return user
+}
+
+func TestRequireRole(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	tests := []struct {
+		name string
+		// token returns the access token the request carries.
+		token      func(t *testing.T) string
+		wantStatus int
+	}{
+		{
+			name: "admin",
+			token: func(t *testing.T) string {
+				_, token := testLogin(t, cfg, withRole(t, cfg, testUser(t, cfg, "admin@example.com"), database.RoleAdmin))
+				return token
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "moderator",
+			token: func(t *testing.T) string {
+				_, token := testLogin(t, cfg, withRole(t, cfg, testUser(t, cfg, "moderator@example.com"), database.RoleModerator))
+				return token
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			// The token still claims admin, but the database has the final say.
+			name: "demoted admin",
+			token: func(t *testing.T) string {
+				user := withRole(t, cfg, testUser(t, cfg, "demoted@example.com"), database.RoleAdmin)
+				_, token := testLogin(t, cfg, user)
+				withRole(t, cfg, user, database.RoleUser)
+				return token
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "admin logged out",
+			token: func(t *testing.T) string {
+				user := withRole(t, cfg, testUser(t, cfg, "loggedout@example.com"), database.RoleAdmin)
+				session, token := testLogin(t, cfg, user)
+				err := cfg.db.RevokeRefreshTokenFamily(session.FamilyID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "token signed with another secret",
+			token: func(t *testing.T) string {
+				user := testUser(t, cfg, "forged@example.com")
+				token, err := auth.MakeAccessJWT(auth.Identity{UserID: user.ID, Role: database.RoleAdmin}, "other-secret", time.Hour)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "no token",
+			token:      func(t *testing.T) string { return "" },
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got database.User
+			handler := cfg.requireRole(database.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+				got = requestUser(r)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+			if token := tt.token(t); token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusOK && got.Role != database.RoleAdmin {
+				t.Errorf("handler got user %+v", got)
+			}
+		})
+	}
+}
+
+func TestHandlerAdminUserUpdate(t *testing.T) {
+	cfg := newTestConfig(t)
+	admin := withRole(t, cfg, testUser(t, cfg, "admin@example.com"), database.RoleAdmin)
+	_, adminToken := testLogin(t, cfg, admin)
+	user := testUser(t, cfg, "user@example.com")
+
+	tests := []struct {
+		name         string
+		userID       string
+		body         string
+		wantStatus   int
+		wantRole     string
+		wantDisabled bool
+	}{
+		{name: "promote", userID: user.ID.String(), body: `{"role": "moderator"}`, wantStatus: http.StatusOK, wantRole: database.RoleModerator},
+		{name: "disable", userID: user.ID.String(), body: `{"disabled": true}`, wantStatus: http.StatusOK, wantRole: database.RoleModerator, wantDisabled: true},
+		{name: "enable", userID: user.ID.String(), body: `{"disabled": false}`, wantStatus: http.StatusOK, wantRole: database.RoleModerator},
+		{name: "unknown role", userID: user.ID.String(), body: `{"role": "owner"}`, wantStatus: http.StatusBadRequest},
+		{name: "demote self", userID: admin.ID.String(), body: `{"role": "user"}`, wantStatus: http.StatusForbidden},
+		{name: "disable self", userID: admin.ID.String(), body: `{"disabled": true}`, wantStatus: http.StatusForbidden},
+		{name: "unknown user", userID: uuid.NewString(), body: `{"role": "user"}`, wantStatus: http.StatusNotFound},
+		{name: "invalid ID", userID: "abc", body: `{}`, wantStatus: http.StatusBadRequest},
+	}
+
+	handler := cfg.requireRole(database.RoleAdmin, cfg.handlerAdminUserUpdate)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := authorizedRequest(http.MethodPatch, "/admin/users/"+tt.userID, adminToken, strings.NewReader(tt.body))
+			req.SetPathValue("userID", tt.userID)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			got, err := cfg.db.GetUser(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Role != tt.wantRole || (got.DisabledAt != nil) != tt.wantDisabled {
+				t.Errorf("got role %q and disabled at %v", got.Role, got.DisabledAt)
+			}
+		})
+	}
+
+	self, err := cfg.db.GetUser(admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if self.Role != database.RoleAdmin || self.DisabledAt != nil {
+		t.Errorf("admin changed themselves: %+v", self)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func (cfg *apiConfig) putObject(ctx context.Context, key string, body io.Reader, contentType string) error {
+	_, err := cfg.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &cfg.s3Bucket,
+		Key:         &key,
+		Body:        body,
+		ContentType: &contentType,
+	})
+	return err
+}
+
+func (cfg *apiConfig) getObject(ctx context.Context, key string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := cfg.downloadObject(ctx, key, &buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (cfg *apiConfig) downloadObject(ctx context.Context, key string, dst io.Writer) error {
+	out, err := cfg.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &cfg.s3Bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return err
+	}
+	defer out.Body.Close()
+	_, err = io.Copy(dst, out.Body)
+	return err
+}
+
+func (cfg *apiConfig) deleteObject(ctx context.Context, key string) error {
+	_, err := cfg.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &cfg.s3Bucket,
+		Key:    &key,
+	})
+	return err
+}
+
+func (cfg *apiConfig) objectURL(key string) string {
+	return fmt.Sprintf("https://%s/%s", cfg.s3CfDistribution, key)
+}
+
+// objectKey returns the bucket key behind a URL built by objectURL.
+func (cfg *apiConfig) objectKey(url string) (string, bool) {
+	prefix := fmt.Sprintf("https://%s/", cfg.s3CfDistribution)
+	if !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, prefix), true
+}
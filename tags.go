@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTagLength    = 50
+	maxTagsPerVideo = 30
+)
+
+// normalizeTag lowercases a tag and collapses runs of whitespace to a single
+// space, so "  Cooking  Tips" and "cooking tips" are the same tag.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	if tag == "" {
+		return "", errors.New("tags can't be empty")
+	}
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return "", fmt.Errorf("tags can't be longer than %d characters", maxTagLength)
+	}
+	return tag, nil
+}
+
+// normalizeTags normalizes every tag and drops duplicates, keeping the
+// first occurrence's position.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "cooking", want: "cooking"},
+		{input: "  Cooking  Tips", want: "cooking tips"},
+		{input: "Tab\tand\nnewline", want: "tab and newline"},
+		{input: "ÉTÉ", want: "été"},
+		{input: strings.Repeat("é", maxTagLength), want: strings.Repeat("é", maxTagLength)},
+		{input: "", wantErr: true},
+		{input: " \t ", wantErr: true},
+		{input: strings.Repeat("a", maxTagLength+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := normalizeTag(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none", input: nil, want: []string{}},
+		{name: "keeps order", input: []string{"b", "a"}, want: []string{"b", "a"}},
+		{name: "drops duplicates", input: []string{"Go", "cats", " go ", "CATS"}, want: []string{"go", "cats"}},
+		{name: "invalid tag", input: []string{"go", ""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour
+	trashPurgeInterval    = time.Hour
+)
+
+// loadTrashRetention reads TRASH_RETENTION_DAYS, how long deleted videos
+// stay restorable.
+func loadTrashRetention() (time.Duration, error) {
+	value := os.Getenv("TRASH_RETENTION_DAYS")
+	if value == "" {
+		return defaultTrashRetention, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid TRASH_RETENTION_DAYS %q", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// runTrashPurger purges expired videos from the trash now and then every
+// trashPurgeInterval until ctx is done.
+func (cfg *apiConfig) runTrashPurger(ctx context.Context) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := cfg.purgeTrash(ctx, time.Now().Add(-cfg.trashRetention))
+		if err != nil {
+			log.Printf("Error purging trash: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("Purged %d videos from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash permanently deletes videos trashed before cutoff, stored
+// objects first so a failure leaves the row behind to retry. A video that
+// fails doesn't hold up the rest; their errors are returned together.
+func (cfg *apiConfig) purgeTrash(ctx context.Context, cutoff time.Time) (int, error) {
+	videos, err := cfg.db.GetVideosDeletedBefore(cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var errs []error
+	for _, video := range videos {
+		err := cfg.purgeVideo(ctx, video)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("video %s: %w", video.ID, err))
+			continue
+		}
+		cfg.recordAuditEvent(database.CreateAuditEventParams{
+			Action:     auditVideoPurge,
+			TargetType: auditTargetVideo,
+			TargetID:   video.ID.String(),
+			Outcome:    database.AuditOutcomeSuccess,
+			Detail:     "retention expired",
+		})
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+// purgeVideo deletes a video's row and every object stored for it,
+// including the media of every version.
+func (cfg *apiConfig) purgeVideo(ctx context.Context, video database.Video) error {
+	versions, err := cfg.db.GetVideoVersions(video.ID)
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		err := cfg.deleteObjectsAt(ctx, &version.VideoURL, version.PreviewURL, version.PreviewVideoURL)
+		if err != nil {
+			return err
+		}
+	}
+	for _, track := range video.SubtitleTracks {
+		cfg.deleteSubtitleObjects(ctx, track)
+	}
+
+	if video.ThumbnailURL != nil {
+		// Clips reuse their source's thumbnail.
+		users, err := cfg.db.CountVideosWithThumbnail(*video.ThumbnailURL)
+		if err != nil {
+			return err
+		}
+		if users <= 1 {
+			err = cfg.deleteThumbnail(*video.ThumbnailURL)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return cfg.db.DeleteVideo(video.ID)
+}
+
+// deleteThumbnail removes a thumbnail stored under assetsRoot. Thumbnails
+// stored elsewhere are left alone.
+func (cfg *apiConfig) deleteThumbnail(thumbnailURL string) error {
+	path, ok := cfg.localAssetPath(thumbnailURL)
+	if !ok {
+		return nil
+	}
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestLoadTrashRetention(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: defaultTrashRetention},
+		{value: "0", want: 0},
+		{value: "7", want: 7 * 24 * time.Hour},
+		{value: "-1", wantErr: true},
+		{value: "a week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("TRASH_RETENTION_DAYS", tt.value)
+			got, err := loadTrashRetention()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.assetsRoot = t.TempDir()
+	cfg.port = "8091"
+	user := testUser(t, cfg, "trash@example.com")
+
+	thumbnail := func(name string) *string {
+		return ptr("http://localhost:8091/assets/" + name)
+	}
+	writeAsset := func(name string) string {
+		path := filepath.Join(cfg.assetsRoot, name)
+		err := os.WriteFile(path, []byte("png"), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	createVideo := func(title string, thumbnailURL *string, trashed bool) database.Video {
+		video, err := cfg.db.CreateVideo(database.CreateVideoParams{Title: title, UserID: user.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		video.ThumbnailURL = thumbnailURL
+		err = cfg.db.UpdateVideo(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trashed {
+			err = cfg.db.TrashVideo(video.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return video
+	}
+
+	plain := createVideo("plain", nil, true)
+	ownPath := writeAsset("own.png")
+	own := createVideo("own thumbnail", thumbnail("own.png"), true)
+	sharedPath := writeAsset("shared.png")
+	shared := createVideo("clip", thumbnail("shared.png"), true)
+	kept := createVideo("source", thumbnail("shared.png"), false)
+	// A thumbnail that can't be removed makes its video fail to purge.
+	brokenPath := filepath.Join(cfg.assetsRoot, "broken.png")
+	err := os.MkdirAll(filepath.Join(brokenPath, "child"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := createVideo("broken", thumbnail("broken.png"), true)
+
+	purged, err := cfg.purgeTrash(context.Background(), time.Now().Add(time.Minute))
+	if err == nil || !strings.Contains(err.Error(), broken.ID.String()) {
+		t.Errorf("got error %v, want one for video %s", err, broken.ID)
+	}
+	if purged != 3 {
+		t.Errorf("purged %d videos, want 3", purged)
+	}
+
+	for _, tt := range []struct {
+		video       database.Video
+		wantTrashed bool
+	}{
+		{plain, false},
+		{own, false},
+		{shared, false},
+		{broken, true},
+	} {
+		video, err := cfg.db.GetDeletedVideo(tt.video.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trashed := video.ID != uuid.Nil; trashed != tt.wantTrashed {
+			t.Errorf("video %q in the trash: %v, want %v", tt.video.Title, trashed, tt.wantTrashed)
+		}
+	}
+	video, err := cfg.db.GetVideo(kept.ID)
+	if err != nil || video.ID != kept.ID {
+		t.Errorf("video outside the trash was purged: %v", err)
+	}
+
+	if _, err := os.Stat(ownPath); !os.IsNotExist(err) {
+		t.Errorf("thumbnail of a purged video still exists: %v", err)
+	}
+	if _, err := os.Stat(sharedPath); err != nil {
+		t.Errorf("thumbnail still used by another video was removed: %v", err)
+	}
+
+	// Once the thumbnail can be removed, the next run finishes the job.
+	err = os.RemoveAll(filepath.Join(brokenPath, "child"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	purged, err = cfg.purgeTrash(context.Background(), time.Now().Add(time.Minute))
+	if err != nil || purged != 1 {
+		t.Errorf("retry purged %d videos with error %v, want 1 and none", purged, err)
+	}
+}
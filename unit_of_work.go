@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+// Upload handlers store files before recording them. Each runs in a
+// database.UnitOfWork so a failure partway leaves neither rows nor stored
+// files behind:
+//
+//	uow, err := cfg.db.BeginTx(r.Context())
+//	...
+//	defer rollback(uow)
+//
+// and then stages what it stores and commits once everything is recorded.
+
+// stageObject stores an object that uow deletes again unless it commits.
+func (cfg *apiConfig) stageObject(ctx context.Context, uow *database.UnitOfWork, key string, body io.Reader, contentType string) error {
+	err := cfg.putObject(ctx, key, body, contentType)
+	if err != nil {
+		return err
+	}
+	cfg.stageObjectsAt(ctx, uow, cfg.objectURL(key))
+	return nil
+}
+
+// stageObjectsAt makes uow delete the already stored objects behind urls
+// unless it commits.
+func (cfg *apiConfig) stageObjectsAt(ctx context.Context, uow *database.UnitOfWork, urls ...string) {
+	// Undoing matters most when the client went away, which cancels the
+	// request's context.
+	ctx = context.WithoutCancel(ctx)
+	uow.Compensate(func() error {
+		for _, url := range urls {
+			err := cfg.deleteObjectsAt(ctx, &url)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// stageFile makes uow remove a file written under the assets root unless
+// it commits.
+func stageFile(uow *database.UnitOfWork, path string) {
+	uow.Compensate(func() error {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	})
+}
+
+// rollback undoes a unit of work that wasn't committed. Whatever can't be
+// undone is only logged, since the request has failed already.
+func rollback(uow *database.UnitOfWork) {
+	err := uow.Rollback()
+	if err != nil {
+		log.Printf("Couldn't fully undo failed upload: %v", err)
+	}
+}
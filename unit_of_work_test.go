@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStageFile(t *testing.T) {
+	tests := []struct {
+		name string
+		// removed deletes the file before the unit finishes.
+		removed  bool
+		commit   bool
+		wantFile bool
+	}{
+		{name: "committed", commit: true, wantFile: true},
+		{name: "rolled back"},
+		{name: "already gone", removed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			path := filepath.Join(t.TempDir(), "thumbnail.png")
+			err := os.WriteFile(path, []byte("png"), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			uow, err := cfg.db.BeginTx(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			stageFile(uow, path)
+			if tt.removed {
+				err = os.Remove(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.commit {
+				err = uow.Commit()
+			} else {
+				err = uow.Rollback()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = os.Stat(path)
+			if exists := err == nil; exists != tt.wantFile {
+				t.Errorf("file exists: %v, want %v", exists, tt.wantFile)
+			}
+		})
+	}
+}
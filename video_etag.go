@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxVideoSaveAttempts bounds how often saveVideo reapplies a change after
+// losing a race with another request.
+const maxVideoSaveAttempts = 3
+
+// videoETag is a strong validator for a video's JSON, which changes with
+// every revision.
+func videoETag(video database.Video) string {
+	return `"` + strconv.Itoa(video.Revision) + `"`
+}
+
+// respondWithVideo writes a video along with its ETag.
+func respondWithVideo(w http.ResponseWriter, code int, video database.Video) {
+	w.Header().Set("ETag", videoETag(video))
+	respondWithJSON(w, code, video)
+}
+
+// checkIfMatch enforces an If-Match precondition on a video a request is
+// about to change. It writes a 412 and returns false if the client's copy is
+// stale. Requests without If-Match always pass.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, video database.Video) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagListContains(header, videoETag(video)) {
+		return true
+	}
+	w.Header().Set("ETag", videoETag(video))
+	respondWithError(w, http.StatusPreconditionFailed, "Video was modified since you loaded it", nil)
+	return false
+}
+
+// etagListContains reports whether an If-Match or If-None-Match header
+// lists etag or is "*". Weak tags never match, since If-Match requires strong
+// comparison and videos only have strong tags.
+func etagListContains(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// saveVideo applies a change to video and stores it in store, the database
+// or a unit of work. A request without If-Match only cares about the fields
+// it sets, so when another request saved the video first the change is
+// reapplied to the latest copy. With If-Match the client vouched for the
+// copy it had, so the conflict is returned as database.ErrVideoModified.
+func saveVideo(store database.TxStore, r *http.Request, video *database.Video, apply func(*database.Video)) error {
+	apply(video)
+	for attempt := 1; ; attempt++ {
+		err := store.UpdateVideo(*video)
+		if err == nil {
+			video.Revision++
+			return nil
+		}
+		if !errors.Is(err, database.ErrVideoModified) || r.Header.Get("If-Match") != "" || attempt == maxVideoSaveAttempts {
+			return err
+		}
+
+		latest, err := store.GetVideo(video.ID)
+		if err != nil {
+			return err
+		}
+		if latest.ID == uuid.Nil {
+			// Trashed in the meantime.
+			return database.ErrVideoModified
+		}
+		apply(&latest)
+		*video = latest
+	}
+}
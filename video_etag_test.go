@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func TestEtagListContains(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: `"3"`, want: true},
+		{header: `"1", "3"`, want: true},
+		{header: `"1","3"`, want: true},
+		{header: `*`, want: true},
+		{header: `"4"`, want: false},
+		{header: `W/"3"`, want: false},
+		{header: `3`, want: false},
+		{header: `"33"`, want: false},
+	}
+	for _, tt := range tests {
+		if got := etagListContains(tt.header, `"3"`); got != tt.want {
+			t.Errorf("etagListContains(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	video := database.Video{Revision: 2}
+	tests := []struct {
+		ifMatch    string
+		want       bool
+		wantStatus int
+	}{
+		{ifMatch: "", want: true},
+		{ifMatch: `"2"`, want: true},
+		{ifMatch: `"1"`, wantStatus: http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		if tt.ifMatch != "" {
+			req.Header.Set("If-Match", tt.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		if got := checkIfMatch(rec, req, video); got != tt.want {
+			t.Errorf("If-Match %q: got %v, want %v", tt.ifMatch, got, tt.want)
+		}
+		if !tt.want && (rec.Code != tt.wantStatus || rec.Header().Get("ETag") != `"2"`) {
+			t.Errorf("If-Match %q: got status %d and ETag %q", tt.ifMatch, rec.Code, rec.Header().Get("ETag"))
+		}
+	}
+}
+
+// TestSaveVideo checks that a save from a stale copy is only reapplied to the
+// latest one when the client didn't send If-Match.
+func TestSaveVideo(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch bool
+		wantErr error
+	}{
+		{name: "without If-Match"},
+		{name: "with If-Match", ifMatch: true, wantErr: database.ErrVideoModified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			user := testUser(t, cfg, "etag@example.com")
+			video, err := cfg.db.CreateVideo(database.CreateVideoParams{Title: "Before", UserID: user.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stale := video
+			first := video.Revision
+
+			// Another request changes the description first.
+			err = saveVideo(cfg.db, httptest.NewRequest(http.MethodPut, "/", nil), &video, func(v *database.Video) {
+				v.Description = "Theirs"
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.ifMatch {
+				req.Header.Set("If-Match", videoETag(stale))
+			}
+			err = saveVideo(cfg.db, req, &stale, func(v *database.Video) {
+				v.Title = "After"
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			saved, err := cfg.db.GetVideo(video.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Description != "Theirs" {
+				t.Errorf("the other request's change was lost: %q", saved.Description)
+			}
+			if tt.wantErr != nil {
+				if saved.Title != "Before" || saved.Revision != first+1 {
+					t.Errorf("conflicting save went through: %q at revision %d", saved.Title, saved.Revision)
+				}
+				return
+			}
+			if saved.Title != "After" || saved.Revision != first+2 || stale.Revision != saved.Revision {
+				t.Errorf("got %q at revision %d (caller has %d), want \"After\" at %d", saved.Title, saved.Revision, stale.Revision, first+2)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+const (
+	defaultVideoPageSize = 50
+	maxVideoPageSize     = 100
+)
+
+// parseListVideosParams reads the pagination, sorting and filtering query
+// parameters of GET /api/videos and GET /api/catalog.
+func parseListVideosParams(query url.Values) (database.ListVideosParams, error) {
+	params := database.ListVideosParams{
+		Limit: defaultVideoPageSize,
+		Sort:  database.VideoSortCreated,
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxVideoPageSize {
+			return params, fmt.Errorf("limit must be between 1 and %d", maxVideoPageSize)
+		}
+		params.Limit = limit
+	}
+
+	switch sort := database.VideoSort(query.Get("sort")); sort {
+	case "":
+	case database.VideoSortCreated, database.VideoSortUpdated, database.VideoSortTitle, database.VideoSortDuration:
+		params.Sort = sort
+	default:
+		return params, fmt.Errorf("sort must be one of created, updated, title or duration")
+	}
+
+	// Titles read naturally A to Z, everything else newest or longest first.
+	params.Descending = params.Sort != database.VideoSortTitle
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		params.Descending = false
+	case "desc":
+		params.Descending = true
+	default:
+		return params, fmt.Errorf("order must be asc or desc")
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := database.DecodeVideoCursor(value)
+		if err != nil {
+			return params, err
+		}
+		params.After = &cursor
+	}
+
+	switch status := query.Get("status"); status {
+	case "", database.VideoStatusDraft, database.VideoStatusIncomplete, database.VideoStatusReady:
+		params.Status = status
+	default:
+		return params, fmt.Errorf("status must be one of draft, incomplete or ready")
+	}
+
+	if value := query.Get("has_video"); value != "" {
+		hasVideo, err := strconv.ParseBool(value)
+		if err != nil {
+			return params, fmt.Errorf("has_video must be true or false")
+		}
+		params.HasVideo = &hasVideo
+	}
+
+	for _, bound := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"created_after", &params.CreatedAfter},
+		{"created_before", &params.CreatedBefore},
+	} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseDateParam(value)
+		if err != nil {
+			return params, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", bound.name)
+		}
+		*bound.dest = &t
+	}
+
+	switch aspectRatio := query.Get("aspect_ratio"); aspectRatio {
+	case "", "landscape", "portrait", "other":
+		params.AspectRatio = aspectRatio
+	default:
+		return params, fmt.Errorf("aspect_ratio must be one of landscape, portrait or other")
+	}
+
+	if visibility := query.Get("visibility"); visibility != "" {
+		if !validVisibility(visibility) {
+			return params, fmt.Errorf("visibility must be one of private, unlisted or public")
+		}
+		params.Visibility = visibility
+	}
+
+	tags, err := normalizeTags(query["tag"])
+	if err != nil {
+		return params, err
+	}
+	params.Tags = tags
+
+	return params, nil
+}
+
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// setNextPageHeaders advertises the next page both as a bare cursor and as
+// an RFC 8288 Link that repeats the current query with the new cursor.
+func setNextPageHeaders(w http.ResponseWriter, r *http.Request, cursor string) {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+
+	w.Header().Set("X-Next-Cursor", cursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
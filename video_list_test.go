@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestParseListVideosParams(t *testing.T) {
+	cursor := database.VideoCursor{Sort: database.VideoSortTitle, Value: "b", ID: uuid.New()}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	instant := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    database.ListVideosParams
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			want:  database.ListVideosParams{Limit: 50, Sort: database.VideoSortCreated, Descending: true, Tags: []string{}},
+		},
+		{
+			name:  "title sorts ascending",
+			query: "sort=title&limit=100",
+			want:  database.ListVideosParams{Limit: 100, Sort: database.VideoSortTitle, Tags: []string{}},
+		},
+		{
+			name:  "explicit order",
+			query: "sort=duration&order=asc",
+			want:  database.ListVideosParams{Limit: 50, Sort: database.VideoSortDuration, Tags: []string{}},
+		},
+		{
+			name:  "cursor",
+			query: "sort=title&cursor=" + cursor.Encode(),
+			want:  database.ListVideosParams{Limit: 50, Sort: database.VideoSortTitle, After: &cursor, Tags: []string{}},
+		},
+		{
+			name:  "filters",
+			query: "status=ready&has_video=false&created_after=2024-03-01&created_before=2024-03-01T12:30:00Z&aspect_ratio=portrait&visibility=public&tag=Go&tag=go&tag=Cats",
+			want: database.ListVideosParams{
+				Limit:         50,
+				Sort:          database.VideoSortCreated,
+				Descending:    true,
+				Status:        database.VideoStatusReady,
+				HasVideo:      ptr(false),
+				CreatedAfter:  &day,
+				CreatedBefore: &instant,
+				AspectRatio:   "portrait",
+				Visibility:    database.VisibilityPublic,
+				Tags:          []string{"go", "cats"},
+			},
+		},
+		{name: "limit zero", query: "limit=0", wantErr: true},
+		{name: "limit too large", query: "limit=101", wantErr: true},
+		{name: "unknown sort", query: "sort=views", wantErr: true},
+		{name: "unknown order", query: "order=random", wantErr: true},
+		{name: "invalid cursor", query: "cursor=abc", wantErr: true},
+		{name: "unknown status", query: "status=published", wantErr: true},
+		{name: "invalid has_video", query: "has_video=maybe", wantErr: true},
+		{name: "invalid date", query: "created_after=yesterday", wantErr: true},
+		{name: "unknown aspect ratio", query: "aspect_ratio=square", wantErr: true},
+		{name: "unknown visibility", query: "visibility=secret", wantErr: true},
+		{name: "invalid tag", query: "tag=%20", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseListVideosParams(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetNextPageHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/videos?sort=title&cursor=old&tag=go", nil)
+	w := httptest.NewRecorder()
+	setNextPageHeaders(w, r, "new")
+
+	if got := w.Header().Get("X-Next-Cursor"); got != "new" {
+		t.Errorf("X-Next-Cursor = %q, want %q", got, "new")
+	}
+	want := `</api/videos?cursor=new&sort=title&tag=go>; rel="next"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+const defaultVideoVersionsKept = 3
+
+// loadVideoVersionsKept reads VIDEO_VERSIONS_KEEP, how many versions
+// besides the current one are kept when a video is re-uploaded. Older
+// versions are deleted along with their stored objects.
+func loadVideoVersionsKept() (int, error) {
+	value := os.Getenv("VIDEO_VERSIONS_KEEP")
+	if value == "" {
+		return defaultVideoVersionsKept, nil
+	}
+	kept, err := strconv.Atoi(value)
+	if err != nil || kept < 0 {
+		return 0, fmt.Errorf("invalid VIDEO_VERSIONS_KEEP %q", value)
+	}
+	return kept, nil
+}
+
+// fileChecksum returns the hex SHA-256 and size of a file.
+func fileChecksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// getVideoDimensions returns the width and height of the first video
+// stream.
+func getVideoDimensions(filePath string) (int, int, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-print_format", "json", "-show_streams", filePath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to run ffprobe: %w\n%s", err, string(output))
+	}
+
+	var ffprobeOutput FFProbeOutput
+	err = json.Unmarshal(output, &ffprobeOutput)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(ffprobeOutput.Streams) == 0 {
+		return 0, 0, fmt.Errorf("no video streams found in file: %s", filePath)
+	}
+	return ffprobeOutput.Streams[0].Width, ffprobeOutput.Streams[0].Height, nil
+}
+
+// probeVideoVersion fills in the size, checksum and probe data of the
+// media file about to be stored as a version.
+func probeVideoVersion(path string, params *database.CreateVideoVersionParams) error {
+	checksum, size, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	width, height, err := getVideoDimensions(path)
+	if err != nil {
+		return err
+	}
+	duration, err := getVideoDuration(path)
+	if err != nil {
+		return err
+	}
+
+	seconds := duration.Seconds()
+	params.SizeBytes = &size
+	params.ChecksumSHA256 = &checksum
+	params.Width = &width
+	params.Height = &height
+	params.DurationSeconds = &seconds
+	return nil
+}
+
+// setCurrentVersion copies a version's media onto the video. The caller
+// saves the video.
+func setCurrentVersion(video *database.Video, version database.VideoVersion) {
+	video.CurrentVersionID = &version.ID
+	video.VideoURL = &version.VideoURL
+	video.PreviewURL = version.PreviewURL
+	video.PreviewVideoURL = version.PreviewVideoURL
+	video.DurationSeconds = version.DurationSeconds
+	video.AspectRatio = version.AspectRatio
+	video.IntegratedLoudness = version.IntegratedLoudness
+}
+
+// pruneVideoVersions deletes the versions of a video beyond the newest
+// cfg.versionsKept ones that aren't current. Failures are only logged:
+// the new upload already succeeded.
+func (cfg *apiConfig) pruneVideoVersions(ctx context.Context, video database.Video) {
+	versions, err := cfg.db.GetVideoVersions(video.ID)
+	if err != nil {
+		log.Printf("Couldn't list versions of video %s: %v", video.ID, err)
+		return
+	}
+
+	kept := 0
+	for _, version := range versions {
+		if video.CurrentVersionID != nil && version.ID == *video.CurrentVersionID {
+			continue
+		}
+		if kept < cfg.versionsKept {
+			kept++
+			continue
+		}
+		err := cfg.deleteVideoVersion(ctx, version)
+		if err != nil {
+			log.Printf("Couldn't delete version %d of video %s: %v", version.Version, video.ID, err)
+		}
+	}
+}
+
+// deleteVideoVersion deletes a version's stored objects and then its row.
+func (cfg *apiConfig) deleteVideoVersion(ctx context.Context, version database.VideoVersion) error {
+	err := cfg.deleteObjectsAt(ctx, &version.VideoURL, version.PreviewURL, version.PreviewVideoURL)
+	if err != nil {
+		return err
+	}
+	return cfg.db.DeleteVideoVersion(version.ID)
+}
+
+// deleteObjectsAt deletes the bucket objects behind the given URLs,
+// skipping nil URLs and URLs that don't point into the bucket.
+func (cfg *apiConfig) deleteObjectsAt(ctx context.Context, urls ...*string) error {
+	for _, url := range urls {
+		if url == nil {
+			continue
+		}
+		key, ok := cfg.objectKey(*url)
+		if !ok {
+			continue
+		}
+		err := cfg.deleteObject(ctx, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func TestLoadVideoVersionsKept(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: defaultVideoVersionsKept},
+		{value: "0", want: 0},
+		{value: "10", want: 10},
+		{value: "-1", wantErr: true},
+		{value: "all", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("VIDEO_VERSIONS_KEEP", tt.value)
+			got, err := loadVideoVersionsKept()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	err := os.WriteFile(path, []byte("hello"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum, size, err := fileChecksum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; checksum != want || size != 5 {
+		t.Errorf("got %s and %d bytes, want %s and 5 bytes", checksum, size, want)
+	}
+
+	_, _, err = fileChecksum(filepath.Join(t.TempDir(), "missing.mp4"))
+	if err == nil {
+		t.Error("got no error for a missing file")
+	}
+}
+
+func TestPruneVideoVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		kept    int
+		current int
+		want    []int
+	}{
+		{name: "newest current", kept: 2, current: 5, want: []int{5, 4, 3}},
+		{name: "rolled back", kept: 2, current: 2, want: []int{5, 4, 2}},
+		{name: "only the current one", kept: 0, current: 3, want: []int{3}},
+		{name: "fewer than kept", kept: 10, current: 5, want: []int{5, 4, 3, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.s3CfDistribution = "cdn.example.com"
+			cfg.versionsKept = tt.kept
+			user := testUser(t, cfg, "versions@example.com")
+
+			video, err := cfg.db.CreateVideo(database.CreateVideoParams{Title: "Versions", UserID: user.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= 5; i++ {
+				// Media stored outside the bucket has no objects to delete.
+				version, err := cfg.db.CreateVideoVersion(database.CreateVideoVersionParams{
+					VideoID:  video.ID,
+					VideoURL: fmt.Sprintf("https://elsewhere.example.com/v%d.mp4", i),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version.Version != i {
+					t.Fatalf("got version %d, want %d", version.Version, i)
+				}
+				if i == tt.current {
+					setCurrentVersion(&video, version)
+				}
+			}
+			err = cfg.db.UpdateVideo(video)
+			if err != nil {
+				t.Fatal(err)
+			}
+			video, err = cfg.db.GetVideo(video.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg.pruneVideoVersions(context.Background(), video)
+
+			versions, err := cfg.db.GetVideoVersions(video.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []int{}
+			for _, version := range versions {
+				got = append(got, version.Version)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("kept versions %v, want %v", got, tt.want)
+			}
+			if *video.VideoURL != fmt.Sprintf("https://elsewhere.example.com/v%d.mp4", tt.current) {
+				t.Errorf("video plays %s, want version %d", *video.VideoURL, tt.current)
+			}
+		})
+	}
+}
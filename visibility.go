@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/google/uuid"
+)
+
+func validVisibility(visibility string) bool {
+	switch visibility {
+	case database.VisibilityPrivate, database.VisibilityUnlisted, database.VisibilityPublic:
+		return true
+	}
+	return false
+}
+
+// viewableVideo loads the video in the request path for routes anyone can
+// call. Private videos are only shown to their owner, since videos don't
+// have collaborators yet; to anyone else they are reported as not found,
+// so their IDs can't be probed. If the video can't be shown, it writes the
+// error response and returns false.
+func (cfg *apiConfig) viewableVideo(w http.ResponseWriter, r *http.Request) (database.Video, bool) {
+	videoID, err := uuid.Parse(r.PathValue("videoID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid video ID", err)
+		return database.Video{}, false
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return database.Video{}, false
+	}
+	// Only private videos need to know who is asking.
+	if video.ID == uuid.Nil ||
+		(video.Visibility == database.VisibilityPrivate && video.UserID != cfg.viewer(r, scopeVideosRead)) {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return database.Video{}, false
+	}
+	return video, true
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func TestValidVisibility(t *testing.T) {
+	tests := []struct {
+		visibility string
+		want       bool
+	}{
+		{visibility: database.VisibilityPrivate, want: true},
+		{visibility: database.VisibilityUnlisted, want: true},
+		{visibility: database.VisibilityPublic, want: true},
+		{visibility: "", want: false},
+		{visibility: "Public", want: false},
+		{visibility: "friends", want: false},
+	}
+	for _, tt := range tests {
+		if got := validVisibility(tt.visibility); got != tt.want {
+			t.Errorf("validVisibility(%q) = %v, want %v", tt.visibility, got, tt.want)
+		}
+	}
+}
+
+func TestHandlerVideoGetVisibility(t *testing.T) {
+	cfg := newTestConfig(t)
+	owner := testUser(t, cfg, "owner@example.com")
+	_, ownerToken := testLogin(t, cfg, owner)
+	_, otherToken := testLogin(t, cfg, testUser(t, cfg, "other@example.com"))
+
+	videos := map[string]database.Video{}
+	for _, visibility := range []string{database.VisibilityPrivate, database.VisibilityUnlisted, database.VisibilityPublic} {
+		video, err := cfg.db.CreateVideo(database.CreateVideoParams{Title: visibility, UserID: owner.ID, Visibility: visibility})
+		if err != nil {
+			t.Fatal(err)
+		}
+		videos[visibility] = video
+	}
+
+	tests := []struct {
+		name       string
+		visibility string
+		token      string
+		wantStatus int
+	}{
+		{name: "private, anonymous", visibility: database.VisibilityPrivate, wantStatus: http.StatusNotFound},
+		{name: "private, another user", visibility: database.VisibilityPrivate, token: otherToken, wantStatus: http.StatusNotFound},
+		{name: "private, owner", visibility: database.VisibilityPrivate, token: ownerToken, wantStatus: http.StatusOK},
+		{name: "unlisted, anonymous", visibility: database.VisibilityUnlisted, wantStatus: http.StatusOK},
+		{name: "public, another user", visibility: database.VisibilityPublic, token: otherToken, wantStatus: http.StatusOK},
+		// A bad token makes the request anonymous rather than failing it.
+		{name: "public, invalid token", visibility: database.VisibilityPublic, token: "not-a-jwt", wantStatus: http.StatusOK},
+		{name: "private, invalid token", visibility: database.VisibilityPrivate, token: "not-a-jwt", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video := videos[tt.visibility]
+			req := httptest.NewRequest(http.MethodGet, "/api/videos/"+video.ID.String(), nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			req.SetPathValue("videoID", video.ID.String())
+			rec := httptest.NewRecorder()
+			cfg.handlerVideoGet(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandlerCatalog(t *testing.T) {
+	cfg := newTestConfig(t)
+	alice := testUser(t, cfg, "alice@example.com")
+	bob := testUser(t, cfg, "bob@example.com")
+	_, aliceToken := testLogin(t, cfg, alice)
+
+	for _, params := range []struct {
+		database.CreateVideoParams
+		uploaded bool
+	}{
+		{database.CreateVideoParams{Title: "alice public", UserID: alice.ID, Visibility: database.VisibilityPublic}, true},
+		{database.CreateVideoParams{Title: "bob public", UserID: bob.ID, Visibility: database.VisibilityPublic}, true},
+		{database.CreateVideoParams{Title: "alice unlisted", UserID: alice.ID, Visibility: database.VisibilityUnlisted}, true},
+		{database.CreateVideoParams{Title: "alice private", UserID: alice.ID, Visibility: database.VisibilityPrivate}, true},
+		{database.CreateVideoParams{Title: "bob not uploaded", UserID: bob.ID, Visibility: database.VisibilityPublic}, false},
+	} {
+		video, err := cfg.db.CreateVideo(params.CreateVideoParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if params.uploaded {
+			video.VideoURL = ptr("https://cdn.example.com/" + video.ID.String() + ".mp4")
+			err = cfg.db.UpdateVideo(video)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		token      string
+		wantStatus int
+		want       []string
+	}{
+		{name: "anonymous", wantStatus: http.StatusOK, want: []string{"alice public", "bob public"}},
+		// Being logged in doesn't add the user's own videos.
+		{name: "owner", token: aliceToken, wantStatus: http.StatusOK, want: []string{"alice public", "bob public"}},
+		{name: "asking for public", query: "visibility=public", wantStatus: http.StatusOK, want: []string{"alice public", "bob public"}},
+		{name: "asking for private", query: "visibility=private", wantStatus: http.StatusBadRequest},
+		{name: "invalid cursor", query: "cursor=abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/catalog?"+tt.query, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			cfg.handlerCatalog(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var videos []database.Video
+			err := json.Unmarshal(rec.Body.Bytes(), &videos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, video := range videos {
+				got = append(got, video.Title)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatermarkForVideo(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.watermark = watermarkConfig{imagePath: "logo.png", position: "bottom-right", opacity: 0.8, scale: 0.15}
+	user := testUser(t, cfg, "watermark@example.com")
+
+	tests := []struct {
+		visibility string
+		want       bool
+	}{
+		{visibility: database.VisibilityPrivate, want: false},
+		{visibility: database.VisibilityUnlisted, want: false},
+		{visibility: database.VisibilityPublic, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.visibility, func(t *testing.T) {
+			var video database.Video
+			video.UserID, video.Visibility = user.ID, tt.visibility
+			wm, ok, err := cfg.watermarkForVideo(video)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want || ok && wm.imagePath != "logo.png" {
+				t.Errorf("got %+v and %v, want a watermark: %v", wm, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+const watermarkMargin = 10
+
+var watermarkPositions = map[string]string{
+	"top-left":     fmt.Sprintf("%d:%d", watermarkMargin, watermarkMargin),
+	"top-right":    fmt.Sprintf("W-w-%d:%d", watermarkMargin, watermarkMargin),
+	"bottom-left":  fmt.Sprintf("%d:H-h-%d", watermarkMargin, watermarkMargin),
+	"bottom-right": fmt.Sprintf("W-w-%d:H-h-%d", watermarkMargin, watermarkMargin),
+	"center":       "(W-w)/2:(H-h)/2",
+}
+
+// watermarkConfig describes an image overlay burned into transcoded videos.
+// scale is the watermark width as a fraction of the video width.
+type watermarkConfig struct {
+	imagePath string
+	position  string
+	opacity   float64
+	scale     float64
+}
+
+// loadWatermarkConfig reads the deployment-wide watermark from the
+// environment. WATERMARK_IMAGE is optional; without it only users who
+// uploaded their own image get a watermark.
+func loadWatermarkConfig() (watermarkConfig, error) {
+	wm := watermarkConfig{
+		imagePath: os.Getenv("WATERMARK_IMAGE"),
+		position:  "bottom-right",
+		opacity:   0.8,
+		scale:     0.15,
+	}
+
+	if position := os.Getenv("WATERMARK_POSITION"); position != "" {
+		wm.position = position
+	}
+	if opacity := os.Getenv("WATERMARK_OPACITY"); opacity != "" {
+		v, err := strconv.ParseFloat(opacity, 64)
+		if err != nil {
+			return watermarkConfig{}, fmt.Errorf("invalid WATERMARK_OPACITY: %w", err)
+		}
+		wm.opacity = v
+	}
+	if scale := os.Getenv("WATERMARK_SCALE"); scale != "" {
+		v, err := strconv.ParseFloat(scale, 64)
+		if err != nil {
+			return watermarkConfig{}, fmt.Errorf("invalid WATERMARK_SCALE: %w", err)
+		}
+		wm.scale = v
+	}
+
+	return wm, validateWatermark(wm.position, wm.opacity, wm.scale)
+}
+
+func validateWatermark(position string, opacity, scale float64) error {
+	if _, ok := watermarkPositions[position]; !ok {
+		return fmt.Errorf("unknown watermark position %q", position)
+	}
+	if opacity <= 0 || opacity > 1 {
+		return fmt.Errorf("watermark opacity must be in (0, 1], got %v", opacity)
+	}
+	if scale <= 0 || scale > 1 {
+		return fmt.Errorf("watermark scale must be in (0, 1], got %v", scale)
+	}
+	return nil
+}
+
+// mergeWatermark applies a user's overrides on top of the deployment
+// watermark. The second return value is false when no watermark should be
+// applied, either because the user opted out or no image is configured.
+func mergeWatermark(deployment watermarkConfig, settings database.WatermarkSettings) (watermarkConfig, bool) {
+	if !settings.Enabled {
+		return watermarkConfig{}, false
+	}
+
+	wm := deployment
+	if settings.ImagePath != nil {
+		wm.imagePath = *settings.ImagePath
+	}
+	if settings.Position != nil {
+		wm.position = *settings.Position
+	}
+	if settings.Opacity != nil {
+		wm.opacity = *settings.Opacity
+	}
+	if settings.Scale != nil {
+		wm.scale = *settings.Scale
+	}
+
+	return wm, wm.imagePath != ""
+}
+
+// watermarkForVideo returns the watermark to burn into an upload of video.
+// Only public videos are watermarked. It's decided when the file is
+// processed, so making a video public later doesn't add one until the
+// video is uploaded again.
+func (cfg *apiConfig) watermarkForVideo(video database.Video) (watermarkConfig, bool, error) {
+	if video.Visibility != database.VisibilityPublic {
+		return watermarkConfig{}, false, nil
+	}
+	settings, err := cfg.db.GetWatermarkSettings(video.UserID)
+	if err != nil {
+		return watermarkConfig{}, false, err
+	}
+	wm, ok := mergeWatermark(cfg.watermark, settings)
+	return wm, ok, nil
+}
+
+func (wm watermarkConfig) filterGraph() string {
+	return fmt.Sprintf(
+		"[1:v][0:v]scale2ref=w=iw*%.3f:h=ow/mdar[wm][base];[wm]format=rgba,colorchannelmixer=aa=%.2f[wmo];[base][wmo]overlay=%s:format=auto[out]",
+		wm.scale, wm.opacity, watermarkPositions[wm.position],
+	)
+}
+
+// processVideoWithWatermark transcodes the video with the watermark burned
+// in. Like processVideoForFastStart, the output has its moov atom up front.
+func processVideoWithWatermark(filePath string, wm watermarkConfig) (string, error) {
+	outputPath := fmt.Sprintf("%s.processing", filePath)
+	cmd := exec.Command(
+		"ffmpeg", "-i", filePath, "-i", wm.imagePath,
+		"-filter_complex", wm.filterGraph(),
+		"-map", "[out]", "-map", "0:a?",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "23",
+		"-c:a", "copy",
+		"-movflags", "faststart", "-f", "mp4", outputPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		// ffmpeg may have written part of the output before failing.
+		os.Remove(outputPath)
+		return "", fmt.Errorf("failed to run ffmpeg: %w\n%s", err, string(output))
+	}
+
+	return outputPath, nil
+}
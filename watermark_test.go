@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+)
+
+func TestLoadWatermarkConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    watermarkConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: watermarkConfig{position: "bottom-right", opacity: 0.8, scale: 0.15},
+		},
+		{
+			name: "overrides",
+			env: map[string]string{
+				"WATERMARK_IMAGE":    "logo.png",
+				"WATERMARK_POSITION": "top-left",
+				"WATERMARK_OPACITY":  "0.5",
+				"WATERMARK_SCALE":    "1",
+			},
+			want: watermarkConfig{imagePath: "logo.png", position: "top-left", opacity: 0.5, scale: 1},
+		},
+		{
+			name:    "unknown position",
+			env:     map[string]string{"WATERMARK_POSITION": "middle"},
+			wantErr: true,
+		},
+		{
+			name:    "opacity not a number",
+			env:     map[string]string{"WATERMARK_OPACITY": "half"},
+			wantErr: true,
+		},
+		{
+			name:    "zero opacity",
+			env:     map[string]string{"WATERMARK_OPACITY": "0"},
+			wantErr: true,
+		},
+		{
+			name:    "scale too large",
+			env:     map[string]string{"WATERMARK_SCALE": "1.5"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"WATERMARK_IMAGE", "WATERMARK_POSITION", "WATERMARK_OPACITY", "WATERMARK_SCALE"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := loadWatermarkConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeWatermark(t *testing.T) {
+	deployment := watermarkConfig{imagePath: "logo.png", position: "bottom-right", opacity: 0.8, scale: 0.15}
+	userImage := "user.png"
+	center := "center"
+	opacity := 0.3
+
+	tests := []struct {
+		name       string
+		deployment watermarkConfig
+		settings   database.WatermarkSettings
+		want       watermarkConfig
+		wantOK     bool
+	}{
+		{
+			name:       "deployment watermark",
+			deployment: deployment,
+			settings:   database.WatermarkSettings{Enabled: true},
+			want:       deployment,
+			wantOK:     true,
+		},
+		{
+			name:       "opted out",
+			deployment: deployment,
+			settings:   database.WatermarkSettings{Enabled: false, ImagePath: &userImage},
+		},
+		{
+			name:       "overrides",
+			deployment: deployment,
+			settings:   database.WatermarkSettings{Enabled: true, ImagePath: &userImage, Position: &center, Opacity: &opacity},
+			want:       watermarkConfig{imagePath: "user.png", position: "center", opacity: 0.3, scale: 0.15},
+			wantOK:     true,
+		},
+		{
+			name:       "no image anywhere",
+			deployment: watermarkConfig{position: "bottom-right", opacity: 0.8, scale: 0.15},
+			settings:   database.WatermarkSettings{Enabled: true, Position: &center},
+			want:       watermarkConfig{position: "center", opacity: 0.8, scale: 0.15},
+		},
+		{
+			name:       "own image without deployment image",
+			deployment: watermarkConfig{position: "bottom-right", opacity: 0.8, scale: 0.15},
+			settings:   database.WatermarkSettings{Enabled: true, ImagePath: &userImage},
+			want:       watermarkConfig{imagePath: "user.png", position: "bottom-right", opacity: 0.8, scale: 0.15},
+			wantOK:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mergeWatermark(tt.deployment, tt.settings)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatermarkFilterGraph(t *testing.T) {
+	wm := watermarkConfig{imagePath: "logo.png", position: "top-right", opacity: 0.5, scale: 0.2}
+	want := "[1:v][0:v]scale2ref=w=iw*0.200:h=ow/mdar[wm][base];[wm]format=rgba,colorchannelmixer=aa=0.50[wmo];[base][wmo]overlay=W-w-10:10:format=auto[out]"
+	if got := wm.filterGraph(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}